@@ -0,0 +1,58 @@
+package pitemp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+// serveState serves the state and sources as JSON in the wire format,
+// supporting conditional requests
+func (s *Server) serveState(w http.ResponseWriter, r *http.Request) {
+	st, sources := state.Get(), state.Sources()
+	lastModified := st.LastSensorUpdate
+
+	resp := struct {
+		wire.State
+		Stale   bool                  `json:"stale"`
+		Sources map[string]wire.State `json:"sources,omitempty"`
+	}{s.wireState(st), st.IsStale(state.StaleAfter()), nil}
+	for name, src := range sources {
+		if resp.Sources == nil {
+			resp.Sources = map[string]wire.State{}
+		}
+		resp.Sources[name] = wire.FromState(src)
+		if src.LastSensorUpdate.After(lastModified) {
+			lastModified = src.LastSensorUpdate
+		}
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	// The ETag leaves out the uptime, which changes every second, so that
+	// polling clients get a 304 while nothing else changed; so it's weak
+	stable := resp
+	stable.UptimeSeconds = 0
+	tagged, err := json.Marshal(stable)
+	if err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := fnv.New64a()
+	h.Write(tagged)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", fmt.Sprintf(`W/"%x"`, h.Sum64()))
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
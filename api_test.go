@@ -0,0 +1,60 @@
+package pitemp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp"
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+func TestServeStateConditional(t *testing.T) {
+	state.Update(func(s *state.State) {
+		s.SetReading("temperature", state.Reading{Value: 21.5, Unit: "celsius", Sensor: "dht11", MeasuredAt: time.Now()})
+	})
+	start := time.Now()
+	h := pitemp.NewServer(pitemp.WithWireState(func(s state.State) wire.State {
+		w := wire.FromState(s)
+		w.UptimeSeconds = int64(time.Since(start).Seconds())
+		return w
+	})).Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" {
+		t.Fatalf("Got status %d with ETag %q, want 200 with an ETag", w.Code, tag)
+	}
+
+	// Long enough for the uptime served to change
+	time.Sleep(1100 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("If-None-Match", tag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Got status %d for an unchanged state, want 304", w.Code)
+	}
+}
+
+func BenchmarkServeState(b *testing.B) {
+	state.Update(func(s *state.State) {
+		now := time.Now()
+		s.SetReading("temperature", state.Reading{Value: 21.5, Unit: "celsius", Sensor: "dht11", MeasuredAt: now})
+		s.SetReading("humidity", state.Reading{Value: 40, Unit: "percent", Sensor: "dht11", MeasuredAt: now})
+	})
+	h := pitemp.NewServer().Handler()
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("Got status %d", w.Code)
+		}
+	}
+}
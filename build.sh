@@ -10,7 +10,11 @@ export CC=arm-linux-gnueabi-gcc CGO_ENABLED=1 GOOS=linux GOARM=6 GOARCH=arm
 # go build is cached, so rebuilding is cheap even if few files
 # changed. Use "go clean -cache" for a full rebuild if necessary
 
+version=$(git describe --always --dirty 2>/dev/null || echo dev)
+build_date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+
 for i in cmd/*; do
 	echo "$i -> build/$(basename $i).arm"
-	go build -o "build/$(basename $i).arm" ${i}/main.go
+	go build -ldflags "-X github.com/lutzky/pitemp/internal/version.Version=${version} -X github.com/lutzky/pitemp/internal/version.BuildDate=${build_date}" \
+		-o "build/$(basename $i).arm" ./${i}
 done
\ No newline at end of file
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+var (
+	aggregate          = flag.String("aggregate", "", "Comma-separated list of LOCATION=URL remote pitemp servers to poll and re-export (e.g. attic=http://attic:8080)")
+	aggregateInterval  = flag.Duration("aggregate_interval", time.Minute, "Frequency of polling remote pitemp servers")
+	aggregateTokenFile = flag.String("aggregate_token_file", "", "File containing a bearer token for remote pitemp servers requiring authentication")
+)
+
+// aggregateToken is sent to remote servers as a bearer token, if set
+var aggregateToken string
+
+type remote struct {
+	location, url string
+}
+
+func parseAggregate(s string) ([]remote, error) {
+	var remotes []remote
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid --aggregate entry %q, expected LOCATION=URL", entry)
+		}
+		remotes = append(remotes, remote{parts[0], strings.TrimSuffix(parts[1], "/")})
+	}
+	return remotes, nil
+}
+
+// setupAggregate starts polling the remote servers listed in --aggregate
+func setupAggregate(ctx context.Context) error {
+	remotes, err := parseAggregate(*aggregate)
+	if err != nil {
+		return err
+	}
+	if *aggregateTokenFile != "" {
+		if aggregateToken, err = readSecretFile(*aggregateTokenFile); err != nil {
+			return err
+		}
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, r := range remotes {
+		r := r
+		workers.Supervise(ctx, "aggregate "+r.location, func() {
+			sync.RepeatUntilCancelled(ctx, func() {
+				if err := pollRemote(ctx, client, r); err != nil {
+					slog.Error("Failed to poll remote server", "location", r.location, "err", err)
+				}
+			}, *aggregateInterval)
+		})
+	}
+	return nil
+}
+
+func pollRemote(ctx context.Context, client *http.Client, r remote) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"/api", nil)
+	if err != nil {
+		return err
+	}
+	if aggregateToken != "" {
+		req.Header.Set("Authorization", "Bearer "+aggregateToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s/api: %s", r.url, resp.Status)
+	}
+
+	var w wire.State
+	if err := json.NewDecoder(resp.Body).Decode(&w); err != nil {
+		return fmt.Errorf("failed to decode state: %w", err)
+	}
+	s := w.State()
+
+	// A remote that hasn't read its sensor yet has nothing worth exporting
+	if s.LastSensorUpdate.IsZero() {
+		return nil
+	}
+
+	s.SetNode(r.url)
+	recordSource("remote", r.location, s)
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/alert"
+	"github.com/lutzky/pitemp/internal/cron"
+	"github.com/lutzky/pitemp/internal/notify"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var alertRules alert.Rules
+
+var (
+	ntfyURL           = flag.String("ntfy_url", "", "If set, send alert notifications to this ntfy topic URL (e.g. https://ntfy.sh/my-pitemp)")
+	ntfyTokenFile     = flag.String("ntfy_token_file", "", "File containing an ntfy access token")
+	pushoverTokenFile = flag.String("pushover_token_file", "", "If set, send alert notifications through Pushover using the application token in this file")
+	pushoverUser      = flag.String("pushover_user", "", "Pushover user or group key")
+
+	smtpAddr         = flag.String("smtp_addr", "", "If set, send alert notifications by email through this SMTP server (host:port)")
+	smtpUsername     = flag.String("smtp_username", "", "SMTP username")
+	smtpPasswordFile = flag.String("smtp_password_file", "", "File containing the SMTP password")
+	smtpImplicitTLS  = flag.Bool("smtp_implicit_tls", false, "Connect to the SMTP server over TLS (normally port 465) instead of using STARTTLS")
+	smtpFrom         = flag.String("smtp_from", "", "Sender address for alert emails")
+	smtpTo           = flag.String("smtp_to", "", "Comma-separated recipient addresses for alert emails")
+
+	alertInterval = flag.Duration("alert_interval", 30*time.Second, "Frequency of evaluating --alert rules")
+
+	quietStart = flag.String("quiet_start", "", "Cron schedule for the start of quiet hours, e.g. \"0 22 * * *\" for 22:00 every night, during which alert notifications are held back (alerts are still evaluated, logged and served by /api); requires --quiet_end")
+	quietEnd   = flag.String("quiet_end", "", "Cron schedule for the end of quiet hours, e.g. \"0 7 * * *\", after which held back notifications are sent")
+)
+
+func init() {
+	flag.Var(&alertRules, "alert", "Alert rule, e.g. hot:temperature>28,for=10m,hysteresis=1,cooldown=1h, stale:staleness>15m, freezer-open:temperature_trend>5 (degrees per hour) or low-battery:battery<20,hysteresis=5 (with --ina219); may be repeated")
+}
+
+var alertActiveGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "alert_active",
+	Help: "Whether an alert is currently active (1) or not (0)",
+}, []string{"rule"})
+
+// alerts is set up in main, after flags are parsed
+var alerts *alert.Engine
+
+// setupAlerts creates the alert engine and its notification backends
+func setupAlerts() error {
+	alerts = alert.NewEngine(alertRules)
+
+	if *quietStart != "" || *quietEnd != "" {
+		if *quietStart == "" || *quietEnd == "" {
+			return fmt.Errorf("--quiet_start and --quiet_end must be given together")
+		}
+		var quiet cron.Window
+		var err error
+		if quiet.Start, err = cron.Parse(*quietStart); err != nil {
+			return fmt.Errorf("invalid --quiet_start: %w", err)
+		}
+		if quiet.End, err = cron.Parse(*quietEnd); err != nil {
+			return fmt.Errorf("invalid --quiet_end: %w", err)
+		}
+		alerts.SetQuiet(quiet.Contains)
+	}
+
+	if *ntfyURL != "" {
+		n := &notify.Ntfy{TopicURL: *ntfyURL}
+		if *ntfyTokenFile != "" {
+			var err error
+			if n.Token, err = readSecretFile(*ntfyTokenFile); err != nil {
+				return fmt.Errorf("ntfy: %w", err)
+			}
+		}
+		alerts.AddNotifier(n)
+	}
+
+	if *pushoverTokenFile != "" {
+		if *pushoverUser == "" {
+			return fmt.Errorf("pushover: --pushover_user is required")
+		}
+		token, err := readSecretFile(*pushoverTokenFile)
+		if err != nil {
+			return fmt.Errorf("pushover: %w", err)
+		}
+		alerts.AddNotifier(&notify.Pushover{Token: token, User: *pushoverUser})
+	}
+
+	if *smtpAddr != "" {
+		m := &notify.Email{
+			Addr:        *smtpAddr,
+			Username:    *smtpUsername,
+			From:        *smtpFrom,
+			ImplicitTLS: *smtpImplicitTLS,
+		}
+		for _, to := range strings.Split(*smtpTo, ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				m.To = append(m.To, to)
+			}
+		}
+		if m.From == "" || len(m.To) == 0 {
+			return fmt.Errorf("email: --smtp_from and --smtp_to are required")
+		}
+		if *smtpPasswordFile != "" {
+			var err error
+			if m.Password, err = readSecretFile(*smtpPasswordFile); err != nil {
+				return fmt.Errorf("email: %w", err)
+			}
+		}
+		alerts.AddNotifier(m)
+	}
+
+	return nil
+}
+
+// activeAlerts returns the names of the active alert rules
+func activeAlerts() []string {
+	var names []string
+	for _, a := range alerts.Active() {
+		names = append(names, a.Rule)
+	}
+	return names
+}
+
+// startAlerts evaluates the alert rules every --alert_interval, apart from
+// sensor reads, so that e.g. a staleness alert fires even while they're
+// stuck, and sends their notifications
+func startAlerts(ctx context.Context) {
+	if len(alertRules) == 0 {
+		return
+	}
+	workers.Supervise(ctx, "alerts", func() {
+		sync.RepeatUntilCancelled(ctx, evaluateAlerts, *alertInterval)
+	})
+	workers.Supervise(ctx, "alert notifications", func() { alerts.Run(ctx) })
+}
+
+func evaluateAlerts() {
+	alerts.Evaluate(state.Get(), time.Now())
+
+	for _, a := range alerts.Alerts() {
+		v := 0.0
+		if a.Active {
+			v = 1
+		}
+		alertActiveGauge.WithLabelValues(a.Rule).Set(v)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func TestServeJSONConditional(t *testing.T) {
+	if err := setupAlerts(); err != nil {
+		t.Fatalf("setupAlerts failed: %v", err)
+	}
+	state.Update(func(s *state.State) {
+		s.SetReading("temperature", state.Reading{Value: 21.5, Unit: "celsius", Sensor: "fake", MeasuredAt: time.Now()})
+	})
+
+	w := httptest.NewRecorder()
+	serveJSON(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" {
+		t.Fatalf("Got status %d with ETag %q, want 200 with an ETag", w.Code, tag)
+	}
+
+	// Long enough for the uptime served to change
+	time.Sleep(1100 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("If-None-Match", tag)
+	w = httptest.NewRecorder()
+	serveJSON(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Got status %d for an unchanged state, want 304", w.Code)
+	}
+
+	state.Update(func(s *state.State) {
+		s.SetReading("temperature", state.Reading{Value: 22, Unit: "celsius", Sensor: "fake", MeasuredAt: time.Now()})
+	})
+	w = httptest.NewRecorder()
+	serveJSON(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == tag {
+		t.Errorf("Got status %d with ETag %q after an update, want 200 with a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/openapi"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// apiVersion is reported by /api/v1 endpoints. Fields may be added within
+// a version, but never renamed or removed.
+const apiVersion = "1"
+
+type v1Reading struct {
+	TemperatureCelsius float32   `json:"temperature_celsius" doc:"Temperature in degrees Celsius"`
+	HumidityPercent    float32   `json:"humidity_percent" doc:"Relative humidity in percent"`
+	MeasuredAt         time.Time `json:"measured_at" doc:"Time of the reading (RFC 3339)"`
+	HumidexCelsius     float64   `json:"humidex_celsius,omitempty" doc:"Temperature as felt given the humidity (humidex), in degrees Celsius"`
+	Comfort            string    `json:"comfort,omitempty" doc:"How the climate feels: cold, dry, comfortable, damp, hot, muggy or oppressive"`
+	Sensor             string    `json:"sensor,omitempty" doc:"Type of sensor, e.g. dht11 or ruuvitag"`
+	Node               string    `json:"node,omitempty" doc:"Where the reading came from, if not this server: the URL of a remote server or the name of a node pushing readings"`
+}
+
+type v1State struct {
+	APIVersion string               `json:"api_version" doc:"API version, currently \"1\""`
+	IP         string               `json:"ip" doc:"IP address of the server"`
+	Location   string               `json:"location,omitempty" doc:"Configured location of the server"`
+	Reading    *v1Reading           `json:"reading" doc:"Latest reading of the local sensor; null until the first successful reading"`
+	Sources    map[string]v1Reading `json:"sources,omitempty" doc:"Latest readings of additional sources (remote nodes and BLE sensors), by name"`
+}
+
+func newV1Reading(s state.State) v1Reading {
+	t, _ := s.Reading("temperature")
+	r := v1Reading{
+		TemperatureCelsius: s.Temperature,
+		HumidityPercent:    s.Humidity,
+		MeasuredAt:         s.LastSensorUpdate,
+		Sensor:             t.Sensor,
+		Node:               t.Node,
+	}
+	if c, ok := s.Comfort(); ok {
+		r.HumidexCelsius, r.Comfort = c.Humidex, c.Label
+	}
+	return r
+}
+
+func newV1State() v1State {
+	s := state.Get()
+	result := v1State{
+		APIVersion: apiVersion,
+		IP:         s.IP,
+		Location:   *location,
+	}
+	if !s.LastSensorUpdate.IsZero() {
+		r := newV1Reading(s)
+		result.Reading = &r
+	}
+	if sources := state.Sources(); len(sources) > 0 {
+		result.Sources = map[string]v1Reading{}
+		for name, s := range sources {
+			result.Sources[name] = newV1Reading(s)
+		}
+	}
+	return result
+}
+
+// v1Endpoints are described in /api/v1/openapi.json
+var v1Endpoints = []openapi.Endpoint{
+	{Path: "/api/v1/state", Summary: "Current readings", Response: v1State{}},
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func serveV1State(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, newV1State())
+}
+
+func serveV1OpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, openapi.Document("pitemp", apiVersion, v1Endpoints))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/ble"
+	"github.com/lutzky/pitemp/internal/sync"
+)
+
+var bleSensors = flag.String("ble_sensors", "", "Comma-separated list of NAME=ADDRESS BLE sensors (RuuviTag, or LYWSD03MMC with custom firmware) to listen to, e.g. fridge=AA:BB:CC:DD:EE:FF")
+
+// parseBLESensors parses --ble_sensors into a map from address to name
+func parseBLESensors(s string) (map[string]string, error) {
+	sensors := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || len(parts[1]) != len("AA:BB:CC:DD:EE:FF") {
+			return nil, fmt.Errorf("invalid --ble_sensors entry %q, expected NAME=ADDRESS", entry)
+		}
+		sensors[strings.ToUpper(parts[1])] = parts[0]
+	}
+	return sensors, nil
+}
+
+// setupBLESensors starts listening to the sensors listed in --ble_sensors,
+// storing their readings as additional sources.
+func setupBLESensors(ctx context.Context) error {
+	sensors, err := parseBLESensors(*bleSensors)
+	if err != nil || len(sensors) == 0 {
+		return err
+	}
+
+	handle := func(r ble.Reading) {
+		name, ok := sensors[r.Addr]
+		if !ok {
+			return
+		}
+		s := climateState(r.Model, "", r.Temperature, r.Humidity, time.Now())
+		recordSource(r.Model, name, s)
+	}
+
+	// Scanning only stops on errors (e.g. the adapter being reset), in
+	// which case it is retried
+	workers.Supervise(ctx, "BLE sensors", func() {
+		sync.RepeatUntilCancelled(ctx, func() {
+			if err := ble.Scan(ctx, *bleDevice, handle); err != nil {
+				slog.Error("BLE scanning failed", "err", err)
+			}
+		}, time.Minute)
+	})
+	return nil
+}
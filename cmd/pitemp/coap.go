@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"strconv"
+
+	"github.com/lutzky/pitemp/internal/coap"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var coapAddr = flag.String("coap_addr", "", "If set, serve readings over CoAP on this UDP address (e.g. :5683)")
+
+// coapResources are served over CoAP: "state" returns this node's state as
+// JSON in the same wire format as /api, and "temperature" and "humidity"
+// return plain-text values for the simplest clients.
+var coapResources = map[string]coap.Resource{
+	"state": func() ([]byte, uint16) {
+		b, err := json.Marshal(wireState(state.Get()))
+		if err != nil {
+			slog.Error("Error encoding JSON", "err", err)
+		}
+		return b, coap.ApplicationJSON
+	},
+	"temperature": func() ([]byte, uint16) {
+		return strconv.AppendFloat(nil, float64(state.Get().Temperature), 'f', 1, 32), coap.TextPlain
+	},
+	"humidity": func() ([]byte, uint16) {
+		return strconv.AppendFloat(nil, float64(state.Get().Humidity), 'f', 1, 32), coap.TextPlain
+	},
+}
+
+func serveCoAP(ctx context.Context) error {
+	if *coapAddr == "" {
+		return nil
+	}
+	return coap.ListenAndServe(ctx, *coapAddr, coapResources)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lutzky/pitemp/internal/app/config"
+	"github.com/lutzky/pitemp/internal/app/display"
+	"github.com/lutzky/pitemp/internal/app/logging"
+	"github.com/lutzky/pitemp/internal/app/staleness"
+	"github.com/lutzky/pitemp/internal/app/tuning"
+	"github.com/lutzky/pitemp/internal/version"
+)
+
+var (
+	showVersion = flag.Bool("version", false, "Print the version and exit, like the version command")
+
+	displayKind = flag.String("display", display.PiOLED, "Display to drive with the display command: lcd, pioled or another registered driver, as DRIVER[:CONFIG]")
+	displayPort = flag.Int("display_port", 8081, "HTTP port for the display command's status page (see also --listen)")
+)
+
+// command is a pitemp subcommand. All commands except wipe share the global
+// flags (and config file).
+type command struct {
+	main    func() int
+	summary string
+}
+
+var commands = map[string]command{
+	"serve":    {serveMain, "Read the sensor and serve the web UI and API (default)"},
+	"read":     {readMain, "Read the sensor once and print the reading (see --format)"},
+	"selftest": {selftestMain, "Check the sensor and configuration, and exit"},
+	"version":  {versionMain, "Print the version, VCS revision and build date"},
+	"display":  {displayMain, "Show the state from pitemp servers on a local display (see --display)"},
+	"sim":      {simMain, "Preview the LCD and PiOLED layouts in a browser, with fake sensors (see --sim_rooms)"},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [COMMAND] [flags]\n\nCommands:\n", os.Args[0])
+
+	names := []string{"wipe"}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		summary := "Securely delete all data (see wipe --help)"
+		if c, ok := commands[name]; ok {
+			summary = c.summary
+		}
+		fmt.Fprintf(out, "  %-10s%s\n", name, summary)
+	}
+
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "wipe" {
+		os.Exit(wipeMain(args))
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	flag.Usage = usage
+	flag.CommandLine.Parse(args)
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %s\n\n", strings.Join(flag.Args(), " "))
+		usage()
+		os.Exit(2)
+	}
+	if *showVersion {
+		os.Exit(versionMain())
+	}
+	if err := config.Load(); err != nil {
+		logging.Fatal("Failed to load config", "err", err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal("Failed to set up logging", "err", err)
+	}
+	var memoryLimitMB int64
+	if name == "display" {
+		memoryLimitMB = tuning.DisplayMemoryLimitMB
+	}
+	tuning.Apply(memoryLimitMB)
+	staleness.Apply()
+
+	os.Exit(cmd.main())
+}
+
+// versionMain implements "pitemp version"
+func versionMain() int {
+	fmt.Println(version.GetInfo())
+	return 0
+}
+
+// displayMain implements "pitemp display", the equivalent of the pitemp_lcd
+// and pitemp_pioled binaries.
+func displayMain() int {
+	if err := display.Run(*displayKind, *displayPort); err != nil {
+		slog.Error("Failed to run display", "err", err)
+		return 1
+	}
+	return 0
+}
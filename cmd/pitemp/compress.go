@@ -0,0 +1,93 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var gzipPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+var flatePool = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	},
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	// net/http would otherwise sniff the compressed data
+	if c.Header().Get("Content-Type") == "" {
+		c.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	return c.w.Write(b)
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	c.Header().Del("Content-Length")
+	if status == http.StatusNotModified {
+		// There's no body to encode
+		c.Header().Del("Content-Encoding")
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// acceptedEncoding returns "gzip" or "deflate" if the client accepts them
+// (preferring gzip), or "" otherwise.
+func acceptedEncoding(r *http.Request) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.TrimSpace(fields[0])
+		if len(fields) > 1 && strings.ReplaceAll(fields[1], " ", "") == "q=0" {
+			continue
+		}
+		accepted[coding] = true
+	}
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// compress wraps h, compressing GET responses with gzip or deflate if the
+// client accepts them. History responses in particular shrink a lot.
+func compress(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r)
+		if r.Method != http.MethodGet || encoding == "" {
+			h(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", encoding)
+		switch encoding {
+		case "gzip":
+			gz := gzipPool.Get().(*gzip.Writer)
+			defer gzipPool.Put(gz)
+			gz.Reset(w)
+			defer gz.Close()
+			h(&compressWriter{w, gz}, r)
+		case "deflate":
+			fl := flatePool.Get().(*flate.Writer)
+			defer flatePool.Put(fl)
+			fl.Reset(w)
+			defer fl.Close()
+			h(&compressWriter{w, fl}, r)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var corsOrigins = flag.String("cors_origins", "", "Comma-separated list of origins (e.g. https://dashboard.example.com) allowed to make cross-origin requests to /api, including authenticated ones; \"*\" allows any other origin, without credentials")
+
+// corsHandler wraps h, adding CORS headers to /api responses for allowed
+// origins, and answering preflight requests. It must wrap authentication,
+// as browsers send preflight requests without credentials.
+func corsHandler(h http.Handler) http.Handler {
+	origins := map[string]bool{}
+	for _, o := range strings.Split(*corsOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins[strings.TrimSuffix(o, "/")] = true
+		}
+	}
+	if len(origins) == 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !origins["*"] && !origins[origin] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if origins[origin] {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			// Any origin may read responses, but not using the user's
+			// credentials, lest any website they visit change settings
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/settings"
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/internal/minmax"
+	"github.com/lutzky/pitemp/pkg/display"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+//go:embed dashboard.html
+var dashboardTemplateText string
+
+var (
+	dashboardRefresh = settings.NewDuration("dashboard_refresh", time.Minute, "How often the dashboard and kiosk pages reload themselves, e.g. on a wall-mounted tablet; adjustable at runtime")
+	outdoorSource    = flag.String("outdoor_source", "", "Source measuring outdoors (e.g. a node from --aggregate), compared with the local sensor at the top of the dashboard, e.g. \"Outside 8°, 14° colder\"")
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(templateFuncs).Parse(dashboardTemplateText))
+
+// dashboardLocation is a single location shown on the dashboard
+type dashboardLocation struct {
+	Name string
+	state.State
+
+	Stale bool
+
+	// Range is over minmax.Window; HasRange is false if there were no
+	// readings in that time
+	Range    minmax.Range
+	HasRange bool
+}
+
+func newDashboardLocation(name, key string, s state.State) dashboardLocation {
+	l := dashboardLocation{
+		Name:  name,
+		State: s,
+		Stale: s.IsStale(state.StaleAfter()),
+	}
+	l.Range, l.HasRange = minmax.Get(key)
+	if defaultUnits.Load() == "imperial" {
+		l.State = toFahrenheit(l.State)
+		l.Range.MinTemperature = l.Range.MinTemperature*9/5 + 32
+		l.Range.MaxTemperature = l.Range.MaxTemperature*9/5 + 32
+	}
+	return l
+}
+
+// serveDashboard shows all locations (the local sensor, remote nodes and
+// BLE sensors) side by side.
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	var locations []dashboardLocation
+
+	if *dhtEnabled {
+		name := *location
+		if name == "" {
+			name, _ = os.Hostname()
+		}
+		locations = append(locations, newDashboardLocation(name, *location, state.Get()))
+	}
+
+	sources := state.Sources()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		locations = append(locations, newDashboardLocation(name, name, sources[name]))
+	}
+
+	data := struct {
+		Locations    []dashboardLocation
+		Comparison   string
+		ActiveAlerts []string
+		ClockInvalid bool
+		Window       time.Duration
+		Refresh      int
+	}{locations, compareOutdoor(locations), activeAlerts(), !clock.Valid(), minmax.Window, int(dashboardRefresh.Load().Seconds())}
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		slog.Error("Error executing dashboard template", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// compareOutdoor describes the --outdoor_source location compared with the
+// local sensor, the first of locations, e.g. "Outside 8°, 14° colder"; it
+// returns "" unless both have fresh readings
+func compareOutdoor(locations []dashboardLocation) string {
+	if *outdoorSource == "" || !*dhtEnabled {
+		return ""
+	}
+	fresh := func(l dashboardLocation) bool { return !l.LastSensorUpdate.IsZero() && !l.Stale }
+	inside := locations[0]
+	for _, outside := range locations[1:] {
+		if outside.Name == *outdoorSource && fresh(inside) && fresh(outside) {
+			return fmt.Sprintf("Outside %.0f°, %s", outside.Temperature, display.Difference(inside.Temperature, outside.Temperature, "°"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/history"
+)
+
+// historyQuery holds the query parameters of /api/history
+type historyQuery struct {
+	from, to time.Time
+	step     time.Duration
+	limit    int
+}
+
+// parseHistoryQuery parses the query parameters of /api/history, all
+// optional:
+//
+//	from, to: the range of readings, as RFC 3339 timestamps or durations
+//	  before now, e.g. from=168h for the last week
+//	step: downsample to one reading per step, e.g. 10m
+//	limit: return at most this many readings, downsampling as needed
+func parseHistoryQuery(r *http.Request, now time.Time) (historyQuery, error) {
+	var q historyQuery
+	values := r.URL.Query()
+	var err error
+	if q.from, err = parseHistoryTime(values.Get("from"), now); err != nil {
+		return q, fmt.Errorf("invalid from: %w", err)
+	}
+	if q.to, err = parseHistoryTime(values.Get("to"), now); err != nil {
+		return q, fmt.Errorf("invalid to: %w", err)
+	}
+	if !q.from.IsZero() && !q.to.IsZero() && q.to.Before(q.from) {
+		return q, fmt.Errorf("to (%s) is before from (%s)", q.to.Format(time.RFC3339), q.from.Format(time.RFC3339))
+	}
+	if v := values.Get("step"); v != "" {
+		if q.step, err = time.ParseDuration(v); err != nil {
+			return q, fmt.Errorf("invalid step: %w", err)
+		}
+		if q.step <= 0 {
+			return q, fmt.Errorf("invalid step %q: must be positive", v)
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if q.limit, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid limit: %w", err)
+		}
+		if q.limit <= 0 {
+			return q, fmt.Errorf("invalid limit %q: must be positive", v)
+		}
+	}
+	return q, nil
+}
+
+// parseHistoryTime parses s as an RFC 3339 timestamp, or a duration before
+// now; it returns the zero time for ""
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp or a duration, got %q", s)
+	}
+	return now.Add(-d), nil
+}
+
+// serveHistory serves the recorded readings, oldest first, as selected and
+// downsampled by the query parameters; see parseHistoryQuery
+func serveHistory(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history.Query(q.from, q.to, q.step, q.limit)); err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// influxTagEscaper escapes tag keys and values in InfluxDB line protocol
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// writeInfluxLine writes s as a line of InfluxDB line protocol, e.g.:
+//
+//	pitemp,location=attic,sensor=dht11 temperature=21,humidity=40 1600000000000000000
+func writeInfluxLine(w io.Writer, sensor, loc string, s state.State) {
+	fmt.Fprintf(w, "pitemp,")
+	if loc != "" {
+		fmt.Fprintf(w, "location=%s,", influxTagEscaper.Replace(loc))
+	}
+	fmt.Fprintf(w, "sensor=%s temperature=%g,humidity=%g %d\n",
+		influxTagEscaper.Replace(sensor),
+		s.Temperature, s.Humidity,
+		s.LastSensorUpdate.UnixNano())
+}
+
+// serveInflux serves the current readings in InfluxDB line protocol, for
+// scraping with e.g. Telegraf's http input. Sensors that haven't been read
+// yet are omitted.
+func serveInflux(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if s := state.Get(); !s.LastSensorUpdate.IsZero() {
+		writeInfluxLine(w, "dht11", *location, s)
+	}
+
+	sources := state.Sources()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeInfluxLine(w, "remote", name, sources[name])
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var ingestKeysFile = flag.String("ingest_keys_file", "", "If set, accept readings from remote devices at POST /api/readings; each line of this file is SOURCE SECRET")
+
+// maxReadingSize bounds the size of a pushed reading
+const maxReadingSize = 4096
+
+// maxSignedReadingAge bounds how old a signed reading may be, so that a
+// captured one can't be replayed later on
+const maxSignedReadingAge = 5 * time.Minute
+
+// ingestKeys maps source names to their shared secrets
+var ingestKeys map[string]string
+
+// ingestMu serializes checking pushed readings against the current ones and
+// storing them, so that concurrent requests can't both pass the check
+var ingestMu sync.Mutex
+
+// reading is a reading pushed to /api/readings, e.g.:
+//
+//	{"source": "garage", "temperature": 21.5, "humidity": 40}
+//
+// Time is required for signed readings; for others, it defaults to the time
+// the reading was received.
+type reading struct {
+	Source                string
+	Temperature, Humidity float32
+	Time                  time.Time
+}
+
+func loadIngestKeys(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ingest keys file: %w", err)
+	}
+	defer f.Close()
+
+	keys := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line in ingest keys file, expected SOURCE SECRET")
+		}
+		keys[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ingest keys file: %w", err)
+	}
+	return keys, nil
+}
+
+// authenticate checks that body was sent by a holder of key, either by an
+// HMAC-SHA256 signature of the body in the X-Pitemp-Signature header
+// ("sha256=HEX"), or by the key itself as a bearer token. Signatures are
+// preferred, as they don't expose the key over plain HTTP.
+func authenticate(r *http.Request, body []byte, key string) bool {
+	if sig := r.Header.Get("X-Pitemp-Signature"); sig != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1
+}
+
+// serveReadings accepts authenticated readings from remote devices, storing
+// them as additional sources.
+func serveReadings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReadingSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxReadingSize {
+		http.Error(w, "Reading too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var rd reading
+	if err := json.Unmarshal(body, &rd); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid reading: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	key, ok := ingestKeys[rd.Source]
+	if !ok || !authenticate(r, body, key) {
+		slog.Warn("Rejected reading", "source", rd.Source, "remote", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	if r.Header.Get("X-Pitemp-Signature") != "" {
+		// The signature covers the time, which bounds how long a captured
+		// reading could be replayed for
+		if rd.Time.IsZero() {
+			http.Error(w, "Signed readings require a time", http.StatusBadRequest)
+			return
+		}
+		if rd.Time.Before(now.Add(-maxSignedReadingAge)) {
+			http.Error(w, "Reading is too old", http.StatusBadRequest)
+			return
+		}
+	} else if rd.Time.IsZero() {
+		rd.Time = now
+	}
+	if rd.Time.After(now.Add(time.Minute)) {
+		http.Error(w, "Reading is from the future", http.StatusBadRequest)
+		return
+	}
+
+	ingestMu.Lock()
+	defer ingestMu.Unlock()
+	// Rejecting readings no newer than the current one prevents replaying
+	// signed readings within maxSignedReadingAge
+	if prev, ok := state.Sources()[rd.Source]; ok && !rd.Time.After(prev.LastSensorUpdate) {
+		http.Error(w, "Reading is older than the current one", http.StatusConflict)
+		return
+	}
+	s := climateState("remote", rd.Source, rd.Temperature, rd.Humidity, rd.Time)
+	recordSource("remote", rd.Source, s)
+
+	w.WriteHeader(http.StatusNoContent)
+}
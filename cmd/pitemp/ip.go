@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/ipaddr"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var ipInterval = flag.Duration("ip_interval", time.Minute, "Frequency of checking this host's preferred --ip_iface address, shown on the web UI and served on /api; 0 to disable")
+
+// setupIP keeps the state's IP address up to date, e.g. as DHCP leases
+// change
+func setupIP(ctx context.Context) {
+	if *ipInterval == 0 {
+		return
+	}
+	sel := ipaddr.Selection()
+	// The web UI only has room for the preferred address
+	sel.All = false
+
+	workers.Supervise(ctx, "ip", func() {
+		var lastErr string
+		sync.RepeatUntilCancelled(ctx, func() {
+			addr, err := sel.At(time.Now())
+			if err != nil {
+				// Log once per new error, rather than every time, e.g. while
+				// the network is down
+				if err.Error() != lastErr {
+					slog.Warn("Failed to get IP address", "err", err)
+				}
+				lastErr = err.Error()
+			} else {
+				lastErr = ""
+			}
+			state.Update(func(s *state.State) { s.IP = addr.Addr })
+		}, *ipInterval)
+	})
+}
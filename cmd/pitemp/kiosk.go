@@ -0,0 +1,76 @@
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/pkg/display"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+//go:embed kiosk.html
+var kioskTemplateText string
+
+var kioskTemplate = template.Must(template.New("kiosk").Funcs(templateFuncs).Parse(kioskTemplateText))
+
+// kioskLocation returns the location shown by the kiosk page: the one given
+// by the source query parameter, or else the local sensor, or else the first
+// source by name. ok is false if there's no such location.
+func kioskLocation(r *http.Request) (l dashboardLocation, ok bool) {
+	sources := state.Sources()
+	if name := r.URL.Query().Get("source"); name != "" {
+		s, ok := sources[name]
+		if !ok {
+			return l, false
+		}
+		return newDashboardLocation(name, name, s), true
+	}
+	if *dhtEnabled {
+		name := *location
+		if name == "" {
+			name, _ = os.Hostname()
+		}
+		return newDashboardLocation(name, *location, state.Get()), true
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return l, false
+	}
+	sort.Strings(names)
+	return newDashboardLocation(names[0], names[0], sources[names[0]]), true
+}
+
+// serveKiosk shows a single location in huge type on a dark background,
+// reloading itself every --dashboard_refresh, for a wall-mounted tablet or an
+// old phone in a fullscreen browser to serve as a display
+func serveKiosk(w http.ResponseWriter, r *http.Request) {
+	l, ok := kioskLocation(r)
+	if !ok {
+		http.Error(w, "No such location", http.StatusNotFound)
+		return
+	}
+
+	data := struct {
+		dashboardLocation
+		TemperatureTrend, HumidityTrend string
+		ActiveAlerts                    []string
+		ClockInvalid                    bool
+		Refresh                         int
+	}{
+		l,
+		display.TrendArrow(l.State, "temperature"), display.TrendArrow(l.State, "humidity"),
+		activeAlerts(), !clock.Valid(), int(dashboardRefresh.Load().Seconds()),
+	}
+	if err := kioskTemplate.Execute(w, data); err != nil {
+		slog.Error("Error executing kiosk template", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
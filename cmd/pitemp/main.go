@@ -1,121 +1,563 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
-	"log"
+	"hash/fnv"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"time"
 
-	"github.com/d2r2/go-dht"
-	"github.com/d2r2/go-logger"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-
-	"github.com/lutzky/pitemp/internal/state"
+	"github.com/lutzky/pitemp"
+	"github.com/lutzky/pitemp/internal/alert"
+	"github.com/lutzky/pitemp/internal/app/accesslog"
+	"github.com/lutzky/pitemp/internal/app/auth"
+	"github.com/lutzky/pitemp/internal/app/debugserver"
+	"github.com/lutzky/pitemp/internal/app/eco"
+	"github.com/lutzky/pitemp/internal/app/geo"
+	"github.com/lutzky/pitemp/internal/app/listen"
+	"github.com/lutzky/pitemp/internal/app/logging"
+	"github.com/lutzky/pitemp/internal/app/rtc"
+	"github.com/lutzky/pitemp/internal/app/settings"
+	"github.com/lutzky/pitemp/internal/app/shutdown"
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/internal/history"
+	"github.com/lutzky/pitemp/internal/otlp"
+	"github.com/lutzky/pitemp/internal/remotewrite"
+	"github.com/lutzky/pitemp/internal/sun"
 	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/internal/version"
+	"github.com/lutzky/pitemp/pkg/sensor/dht11"
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
 )
 
 var (
-	dhtDelay   = flag.Duration("dht11_delay", time.Minute, "Frequency of DHT11 measurement")
+	dhtDelay   = settings.NewDuration("dht11_delay", time.Minute, "Frequency of DHT11 measurement; adjustable at runtime")
 	dhtPin     = flag.Int("dht11_pin", 4, "GPIO pin to which DHT11 data pin is connected")
 	dhtRetries = flag.Int("dht11_retries", 10, "Retries for DHT11")
 
-	flagPort = flag.Int("port", 8080, "HTTP listening port")
-)
+	dhtEnabled  = flag.Bool("dht11", true, "Read the local DHT11; disable to run as a pure aggregator (see --aggregate)")
+	dhtRealtime = flag.Bool("dht11_realtime", false, "Raise scheduling priority and pause GC while reading DHT11, reducing checksum failures on busy systems (requires root)")
 
-var (
-	tempGauge = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "pitemp_temperature_celsius",
-		Help: "Current temperature as measured by DHT11",
-	})
-	humidityGauge = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "pitemp_humidity_percent",
-		Help: "Current humidity as measured by DHT11",
-	})
-	lastUpdateGauge = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "pitemp_last_update",
-		Help: "Last update time from DHT11",
-	})
-)
+	alignReads = flag.Bool("align_reads", false, "Read the DHT11 and --sensor sensors at wall-clock multiples of their intervals (e.g. at :00 seconds of every minute), so that readings from several nodes line up")
 
-func init() {
-	prometheus.MustRegister(tempGauge)
-	prometheus.MustRegister(humidityGauge)
-	prometheus.MustRegister(lastUpdateGauge)
-}
+	flagPort = flag.Int("port", 8080, "HTTP listening port (see also --listen)")
+
+	trendWindow = flag.Duration("trend_window", time.Hour, "Window over which the temperature and humidity trends (per hour) are calculated")
+	historySize = flag.Int("history_size", 1440, "Number of readings kept in history")
+
+	defaultUnits = settings.NewChoice("units", "metric", []string{"metric", "imperial"}, "Units of temperatures served by /api (unless given by its units query parameter) and shown on the dashboard: metric (Celsius) or imperial (Fahrenheit); adjustable at runtime")
 
-//go:embed template.html
-var httpTemplateText string
+	staleStatus = flag.Bool("stale_status", false, "Respond to /api with 503 Service Unavailable while the reading is stale (see --stale_after)")
 
-var httpTemplate = template.Must(template.New("root").Parse(httpTemplateText))
+	dataDir = flag.String("data_dir", defaultDataDir, "Directory for persistent data; relative paths in other flags are resolved against it")
 
+	remoteWriteURL          = flag.String("remote_write_url", "", "If set, push metrics to this Prometheus remote_write endpoint")
+	remoteWriteUsername     = flag.String("remote_write_username", "", "Basic auth username for remote_write")
+	remoteWritePasswordFile = flag.String("remote_write_password_file", "", "File containing the basic auth password for remote_write")
+	remoteWriteInterval     = flag.Duration("remote_write_interval", time.Minute, "Frequency of remote_write pushes")
+	remoteWriteInstance     = flag.String("remote_write_instance", "", "Instance label of metrics pushed to remote_write (unless they have one already), distinguishing this node from others pushing to the same endpoint; defaults to the hostname")
+)
+
+// workers are the background goroutines (sensor reads, pollers, exporters)
+// which must stop before shutting down; if one of them fails, the rest
+// are stopped too. It is set up by serveMain.
+var workers *sync.Group
+
+// serveHTTP serves the state as JSON or plain text (e.g. "21.0 C 45 %") if
+// the Accept header asks for those, or as an HTML page otherwise.
 func serveHTTP(w http.ResponseWriter, r *http.Request) {
-	err := httpTemplate.Execute(w, state.Get())
+	w.Header().Add("Vary", "Accept")
+	switch negotiate(r.Header.Get("Accept"), "text/html", "application/json", "text/plain") {
+	case "application/json":
+		serveJSON(w, r)
+		return
+	case "text/plain":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, plainText(state.Get()))
+		return
+	}
+
+	err := currentTemplate().Execute(w, newTemplateData())
 	if err != nil {
-		log.Printf("Error executing HTTP template: %v", err)
+		slog.Error("Error executing HTTP template", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// climateState returns a state with the temperature and humidity read at t
+// by sensor, on node (empty for this one)
+func climateState(sensor, node string, temperature, humidity float32, t time.Time) state.State {
+	var s state.State
+	s.SetReading("temperature", state.Reading{Value: temperature, Unit: "celsius", Sensor: sensor, Node: node, MeasuredAt: t})
+	s.SetReading("humidity", state.Reading{Value: humidity, Unit: "percent", Sensor: sensor, Node: node, MeasuredAt: t})
+	return s
+}
+
+// plainText formats the reading in s for humans and shell scripts, e.g.
+// "21.0 C 45 %"
+func plainText(s state.State) string {
+	return fmt.Sprintf("%.1f C %.0f %%", s.Temperature, s.Humidity)
+}
+
+// toFahrenheit converts the temperature in s to Fahrenheit
+func toFahrenheit(s state.State) state.State {
+	if s.Readings == nil {
+		s.Temperature = s.Temperature*9/5 + 32
+		return s
+	}
+	for name, r := range s.Readings {
+		if r.Unit == "celsius" {
+			r.Value, r.Unit = r.Value*9/5+32, "fahrenheit"
+			s.SetReading(name, r)
+		}
+	}
+	return s
+}
+
+// wireState returns s, the state of this node, in the wire format
+func wireState(s state.State) wire.State {
+	w := wire.FromState(s)
+	w.Hostname, _ = os.Hostname()
+	w.UptimeSeconds = int64(time.Since(startTime).Seconds())
+	w.Version = version.Get()
+	w.ClockInvalid = !clock.Valid()
+	w.ActiveAlerts = activeAlerts()
+	w.Sun = sunToday(time.Now())
+	return w
+}
+
+// sunToday returns the sunrise and sunset at --latitude and --longitude on
+// the day of now, or nil if they aren't set or this host's clock isn't valid
+func sunToday(now time.Time) *wire.Sun {
+	lat, lon, ok := geo.Position()
+	if !ok || !clock.Valid() {
+		return nil
+	}
+	sunrise, sunset, day := sun.Times(now, lat, lon)
+	w := &wire.Sun{DaylightSeconds: int64(day.Seconds())}
+	if !sunrise.IsZero() {
+		w.Sunrise, w.Sunset = &sunrise, &sunset
+	}
+	return w
+}
+
+// serveJSON serves the state as JSON. The units=imperial query parameter
+// converts temperatures to Fahrenheit; units=metric leaves them in Celsius.
+// Without it, --units applies. Stale readings are flagged as such, and
+// optionally served with 503 Service Unavailable so that naive consumers
+// notice.
 func serveJSON(w http.ResponseWriter, r *http.Request) {
+	var imperial bool
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		units = defaultUnits.Load()
+	}
+	switch units {
+	case "metric":
+	case "imperial":
+		imperial = true
+	default:
+		http.Error(w, fmt.Sprintf("Unknown units %q, expected metric or imperial", units), http.StatusBadRequest)
+		return
+	}
+
+	s, sources := state.Get(), state.Sources()
+	stale := s.IsStale(state.StaleAfter())
+	lastModified := lastUpdate(s, sources)
+	if imperial {
+		s = toFahrenheit(s)
+		for name, src := range sources {
+			sources[name] = toFahrenheit(src)
+		}
+	}
+
+	resp := struct {
+		wire.State
+		Stale        bool                   `json:"stale"`
+		Sources      map[string]wire.State  `json:"sources,omitempty"`
+		Alerts       []alert.Alert          `json:"alerts,omitempty"`
+		SensorErrors map[string]sensorError `json:"sensor_errors,omitempty"`
+		Build        version.Info           `json:"build"`
+	}{wireState(s), stale, nil, alerts.Alerts(), lastSensorErrors(), version.GetInfo()}
+	for name, src := range sources {
+		if resp.Sources == nil {
+			resp.Sources = map[string]wire.State{}
+		}
+		resp.Sources[name] = wire.FromState(src)
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(state.Get()); err != nil {
-		log.Printf("Error encoding JSON: %v", err)
+	if stale && *staleStatus {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(body)
+		return
+	}
+
+	// Polling clients mostly get a 304. The ETag covers everything in the
+	// response (such as alerts), so it's the more precise of the two, but
+	// for what changes regardless of the state: the uptime, and the values
+	// alert rules were last evaluated with.
+	stable := resp
+	stable.UptimeSeconds = 0
+	stable.Alerts = nil
+	for _, a := range resp.Alerts {
+		a.Value = 0
+		stable.Alerts = append(stable.Alerts, a)
+	}
+	tagged, err := json.Marshal(stable)
+	if err != nil {
+		slog.Error("Error encoding JSON", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(tagged))
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
+
+// lastUpdate returns the latest sensor update in s and sources
+func lastUpdate(s state.State, sources map[string]state.State) time.Time {
+	t := s.LastSensorUpdate
+	for _, src := range sources {
+		if src.LastSensorUpdate.After(t) {
+			t = src.LastSensorUpdate
+		}
+	}
+	return t
+}
+
+// etag returns a weak entity tag for body; weak, as it's computed without
+// the uptime, so responses differing only in that share it
+func etag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// serveVersion serves the version, VCS revision and build date on one line
+func serveVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, version.GetInfo())
+}
+
+// serveMain implements "pitemp serve", the default command: reading the
+// sensor and serving the web UI and API until SIGTERM or SIGINT.
+func serveMain() int {
+	validateServe()
+	if err := rtc.Setup(); err != nil {
+		logging.Fatal("Failed to set up the RTC", "err", err)
+	}
+	if err := registerMetrics(); err != nil {
+		logging.Fatal("Failed to register metrics", "err", err)
+	}
+	setupOTLP()
+	history.SetSize(*historySize)
+	state.Update(func(s *state.State) { s.Location = *location })
+	if err := setupAlerts(); err != nil {
+		logging.Fatal("Failed to set up alerts", "err", err)
+	}
+
+	var routes []pitemp.Option
+	handle := func(pattern string, h http.HandlerFunc) {
+		routes = append(routes, pitemp.WithHandler(pattern, h))
+	}
+	handle("/", compress(serveHTTP))
+	registerStatic(handle)
+	handle("/dashboard", compress(serveDashboard))
+	handle("/kiosk", compress(serveKiosk))
+	handle("/api", compress(serveJSON))
+	handle("/api/history", compress(serveHistory))
+	handle("/api/version", serveVersion)
+	handle("/api/influx", compress(serveInflux))
+	handle("/api/v1/state", compress(serveV1State))
+	handle("/api/v1/openapi.json", compress(serveV1OpenAPI))
+	if *ingestKeysFile != "" {
+		var err error
+		if ingestKeys, err = loadIngestKeys(*ingestKeysFile); err != nil {
+			logging.Fatal("Failed to load ingest keys", "err", err)
+		}
+		// /api/readings has its own per-source authentication
+		auth.Exempt("/api/readings")
+		handle("/api/readings", serveReadings)
 	}
+	authenticator, err := auth.New()
+	if err != nil {
+		logging.Fatal("Failed to set up authentication", "err", err)
+	}
+	// Restoring a snapshot replaces the state and history, so it's as
+	// sensitive as changing settings
+	handle("/api/snapshot", compress(serveSnapshot(auth.Required(authenticator, restoreSnapshot))))
+	handle("/api/config", auth.Required(authenticator, settings.Handler))
+	handle("/api/reload", auth.Required(authenticator, serveReload))
+	l, err := listen.Listen(*flagPort)
+	if err != nil {
+		logging.Fatal("Failed to listen", "err", err)
+	}
+	srv := pitemp.NewServer(append(routes,
+		pitemp.WithListener(l),
+		pitemp.WithWireState(wireState),
+		pitemp.WithHandlerWrapper(instrumented),
+		pitemp.WithMiddleware(accesslog.Handler),
+		pitemp.WithMiddleware(tracer.Handler),
+		pitemp.WithMiddleware(corsHandler),
+		pitemp.WithMiddleware(authenticator.Handler),
+	)...)
+	// Servers on addresses of their own, if configured, by name
+	extraServers := map[string]*http.Server{}
+	if s := serveMetrics(srv.Mux()); s != nil {
+		extraServers["metrics server"] = s
+	}
+	if s := debugserver.Setup(srv.Mux()); s != nil {
+		extraServers["pprof server"] = s
+	}
+
+	ctx, stop := shutdown.OnSignal(context.Background())
+	defer stop()
+	workers, ctx = sync.WithContext(ctx)
+
+	status := 0
+	workers.Run("HTTP server", srv.Serve)
+	for name, s := range extraServers {
+		s := s
+		workers.Run(name, func() error { return listen.Serve(s) })
+	}
+	if err := startWorkers(ctx); err != nil {
+		slog.Error("Failed to start", "err", err)
+		status = 1
+		stop()
+	}
+
+	<-ctx.Done()
+	if workers.Err() != nil {
+		status = 1
+	}
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := shutdown.Context()
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to cleanly shut down HTTP server", "err", err)
+	}
+	for name, s := range extraServers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to cleanly shut down server", "server", name, "err", err)
+		}
+	}
+	// Stop sampling before closing outputs, so nothing is written to them
+	// as they close
+	if err := workers.Wait(shutdownCtx); err != nil {
+		slog.Warn("Gave up waiting for background work to stop", "err", err)
+	}
+	closeSinks()
+	return status
 }
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	flag.Parse()
-	logger.ChangePackageLogLevel("i2c", logger.InfoLevel)
-	logger.ChangePackageLogLevel("dht", logger.InfoLevel)
+// startWorkers sets up and starts the background work: sensor reads,
+// pollers, exporters and protocol servers. On failure, the workers already
+// started must still be stopped.
+func startWorkers(ctx context.Context) error {
+	workers.Run("CoAP server", func() error { return serveCoAP(ctx) })
+	workers.Run("Modbus server", func() error { return serveModbus(ctx) })
 
-	srv := &http.Server{Addr: fmt.Sprintf(":%d", *flagPort)}
-	http.HandleFunc("/", serveHTTP)
-	http.HandleFunc("/api", serveJSON)
-	http.Handle("/metrics", promhttp.Handler())
-	go srv.ListenAndServe()
+	if err := setupAggregate(ctx); err != nil {
+		return fmt.Errorf("failed to set up aggregation: %w", err)
+	}
+	if err := setupBLESensors(ctx); err != nil {
+		return fmt.Errorf("failed to set up BLE sensors: %w", err)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	workers.Go(func() { exportOTLP(ctx) })
+	workers.Supervise(ctx, "rtc", func() { rtc.Sync(ctx) })
+	workers.Supervise(ctx, "eco", func() { eco.WatchMotion(ctx) })
 
-	interrupted := make(chan os.Signal, 1)
-	signal.Notify(interrupted, syscall.SIGTERM, syscall.SIGINT)
+	if err := setupSinks(ctx); err != nil {
+		return fmt.Errorf("failed to set up outputs: %w", err)
+	}
+	if err := setupPlugins(); err != nil {
+		return fmt.Errorf("failed to set up plugins: %w", err)
+	}
+	if err := setupThermostat(ctx); err != nil {
+		return fmt.Errorf("failed to set up thermostat: %w", err)
+	}
+	if err := setupPower(ctx); err != nil {
+		return fmt.Errorf("failed to set up power monitoring: %w", err)
+	}
+	setupThrottled(ctx)
+	setupSystem(ctx)
+	setupIP(ctx)
+	startAlerts(ctx)
 
-	go func() {
-		<-interrupted
-		cancel()
-	}()
+	if *remoteWriteURL != "" {
+		rw, err := newRemoteWriteClient()
+		if err != nil {
+			return fmt.Errorf("failed to set up remote_write: %w", err)
+		}
+		workers.Supervise(ctx, "remote_write", func() {
+			sync.RepeatUntilCancelled(ctx, func() {
+				if err := rw.Push(ctx); err != nil {
+					slog.Error("Failed to push metrics", "err", err)
+				}
+			}, *remoteWriteInterval)
+		})
+	}
 
-	sync.RepeatUntilCancelled(ctx, func() { dhtUpdater(ctx) }, *dhtDelay)
+	if err := startSensors(ctx); err != nil {
+		return fmt.Errorf("failed to set up sensors: %w", err)
+	}
+	return nil
+}
 
-	if err := srv.Shutdown(context.Background()); err != nil {
-		log.Println("Failed to cleanly shut down HTTP server")
-		panic(err)
+// readSchedule returns the options for scheduling sensor reads
+func readSchedule() []sync.RepeatOption {
+	opts := eco.Schedule()
+	if *alignReads {
+		opts = append(opts, sync.Aligned())
 	}
+	return opts
+}
+
+// dataPath resolves p relative to --data_dir, creating the directory if
+// needed.
+func dataPath(p string) (string, error) {
+	if filepath.IsAbs(p) {
+		return p, nil
+	}
+	if err := os.MkdirAll(*dataDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return filepath.Join(*dataDir, p), nil
+}
+
+func newRemoteWriteClient() (*remotewrite.Client, error) {
+	instance := *remoteWriteInstance
+	if instance == "" {
+		var err error
+		if instance, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to get hostname for the instance label, set --remote_write_instance: %w", err)
+		}
+	}
+	c := &remotewrite.Client{
+		URL:            *remoteWriteURL,
+		Username:       *remoteWriteUsername,
+		HTTPClient:     &http.Client{Timeout: 30 * time.Second},
+		ExternalLabels: map[string]string{"instance": instance, "job": "pitemp"},
+	}
+	if *remoteWritePasswordFile != "" {
+		var err error
+		if c.Password, err = readSecretFile(*remoteWritePasswordFile); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// readSecretFile reads a password or token from path, trimming whitespace
+func readSecretFile(path string) (string, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// dhtReader reads the DHT11 on --dht11_pin; tests replace it with a fake
+var dhtReader dht11.Reader = dht11.Hardware
+
+// readDHT reads the DHT11. In realtime mode, the goroutine is locked to its
+// OS thread, which the dht library raises to maximum scheduling priority
+// for the duration of the read, and GC is paused so it can't preempt the
+// timing-critical bit-banging.
+func readDHT(ctx context.Context) (temperature, humidity float32, err error) {
+	ctx, span := tracer.Start(ctx, "dht11.read", otlp.KindInternal)
+	defer span.End()
+
+	if *dhtRealtime {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	}
+
+	start := time.Now()
+	temperature, humidity, retried, err := dhtReader.ReadDHT11(ctx, *dhtPin, *dhtRealtime, *dhtRetries)
+	duration := time.Since(start)
+
+	failures := retried
+	if err != nil {
+		failures++
+		slog.Error("Failed to read DHT11", "sensor", "dht11", "error_type", errorType(err),
+			"duration", duration, "retries", retried, "err", err)
+		dhtReadDurationHistogram.WithLabelValues("failure").Observe(duration.Seconds())
+		recordSensorError("dht11", err)
+	} else {
+		dhtReadsCounter.WithLabelValues("success").Inc()
+		dhtReadDurationHistogram.WithLabelValues("success").Observe(duration.Seconds())
+		slog.Debug("Read DHT11", "sensor", "dht11", "duration", duration, "retries", retried,
+			"temperature", temperature, "humidity", humidity)
+	}
+	dhtReadsCounter.WithLabelValues("failure").Add(float64(failures))
+
+	span.SetAttribute("dht11.retries", fmt.Sprint(retried))
+	span.SetError(err)
+
+	return temperature, humidity, err
+}
+
+// errorType classifies sensor read errors for logs and metrics, so that
+// e.g. DHT11 checksum failures (usually timing problems) can be told apart
+// from wiring problems
+func errorType(err error) string {
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case strings.Contains(msg, "CRCs doesn't match"):
+		return "checksum"
+	case strings.Contains(msg, "decode"), strings.Contains(msg, "edge value"):
+		return "decode"
+	case strings.Contains(msg, "Humidity value"):
+		return "implausible"
+	case strings.Contains(msg, "C.dial_DHTxx_and_read"):
+		return "gpio"
+	}
+	return "other"
 }
 
 func dhtUpdater(ctx context.Context) {
-	temperature, humidity, _, err := dht.ReadDHTxxWithContextAndRetry(ctx, dht.DHT11, *dhtPin, false, *dhtRetries)
+	temperature, humidity, err := readDHT(ctx)
 	if err != nil {
-		log.Printf("Failed to read DHT11: %v", err)
+		readFailed(ctx, state.Get())
 	} else {
-		state.Set(&state.State{
-			Temperature:      temperature,
-			Humidity:         humidity,
-			LastSensorUpdate: time.Now(),
+		reading := climateState("dht11", "", temperature, humidity, time.Now())
+		s := state.Update(func(s *state.State) {
+			for name, r := range reading.Readings {
+				s.SetReading(name, r)
+			}
 		})
+		history.Add(s)
+		trends := history.Trends(*trendWindow, time.Now())
+		s = state.Update(func(s *state.State) { s.Trends = trends })
+
+		recordReading("dht11", s)
 
-		tempGauge.Set(float64(temperature))
-		humidityGauge.Set(float64(humidity))
-		lastUpdateGauge.Set(float64(time.Now().Unix()))
+		writeSinks(ctx, s)
 	}
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/internal/minmax"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/internal/version"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	location       = flag.String("location", "", "Location of this node (e.g. bedroom), shown on web pages and displays, served on /api, used as the default MQTT topic prefix (pitemp/LOCATION), and added as a location label to all metrics")
+	legacyMetrics  = flag.Bool("legacy_metrics", true, "Also export the unlabeled pitemp_temperature_celsius, pitemp_humidity_percent and pitemp_last_update metrics")
+	metricsPrefix  = flag.String("metrics_prefix", "pitemp_", "Prefix for the names of exported metrics")
+	metricsEnabled = flag.Bool("metrics", true, "Serve Prometheus metrics on /metrics")
+	metricsAddr    = flag.String("metrics_addr", "", "Address for a separate metrics server, e.g. localhost:9100 to keep metrics internal; if empty, metrics are served on the main HTTP port")
+	metricsLabels  = flag.String("metrics_labels", "", "Comma-separated list of NAME=VALUE constant labels added to all exported metrics, e.g. instance=attic")
+)
+
+var sensorLabels = []string{"sensor", "location"}
+
+var (
+	sensorTempGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sensor_temperature_celsius",
+		Help: "Current temperature, by sensor",
+	}, sensorLabels)
+	sensorHumidityGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sensor_humidity_percent",
+		Help: "Current humidity, by sensor",
+	}, sensorLabels)
+	sensorLastUpdateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sensor_last_update_timestamp_seconds",
+		Help: "Time of the last successful read, by sensor",
+	}, sensorLabels)
+	dhtReadsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dht_reads_total",
+		Help: "DHT11 reads (including retries), by result",
+	}, []string{"result"})
+	dhtReadDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dht_read_duration_seconds",
+		Help:    "Duration of DHT11 reads (including retries), by result",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"result"})
+	sensorLastErrorGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sensor_last_error_timestamp_seconds",
+		Help: "Time of the last failed read, by sensor: dht11, or the --sensor name",
+	}, []string{"sensor"})
+	sensorLastErrorInfoGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sensor_last_error_info",
+		Help: "Always 1, labeled with the type of the last failed read (e.g. checksum), by sensor",
+	}, []string{"sensor", "error_type"})
+
+	buildInfoGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Always 1, labeled with the version, VCS revision and build date of the running binary",
+	}, []string{"version", "revision", "build_date", "goversion"})
+
+	httpRequestsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests, by handler, method and status code",
+	}, []string{"handler", "method", "code"})
+	httpDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by handler and method",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"handler", "method"})
+)
+
+// Legacy, unlabeled metrics
+var (
+	tempGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "temperature_celsius",
+		Help: "Current temperature as measured by DHT11",
+	})
+	humidityGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "humidity_percent",
+		Help: "Current humidity as measured by DHT11",
+	})
+	lastUpdateGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "last_update",
+		Help: "Last update time from DHT11",
+	})
+)
+
+// parseMetricsLabels parses --metrics_labels
+func parseMetricsLabels(s string) (prometheus.Labels, error) {
+	labels := prometheus.Labels{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid --metrics_labels entry %q, expected NAME=VALUE", entry)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return labels, nil
+}
+
+// metricsLocation is the location label of this node's own sensor metrics:
+// --location, unless --metrics_labels sets one
+var metricsLocation string
+
+// registerMetrics registers the metrics selected by flags, named with
+// --metrics_prefix and labeled with --metrics_labels; call it after
+// flag.Parse.
+func registerMetrics() error {
+	return registerMetricsWith(prometheus.DefaultRegisterer)
+}
+
+// registerMetricsWith registers the metrics selected by flags with reg
+func registerMetricsWith(reg prometheus.Registerer) error {
+	labels, err := parseMetricsLabels(*metricsLabels)
+	if err != nil {
+		return err
+	}
+	metricsLocation = *location
+	if l, ok := labels["location"]; ok {
+		metricsLocation = l
+	}
+
+	// The per-sensor metrics label each reading with the location of its
+	// source, be it this node or another, so a constant location label
+	// would clash with theirs; the rest are this node's
+	sensorConstLabels := prometheus.Labels{}
+	for name, value := range labels {
+		if name != "location" {
+			sensorConstLabels[name] = value
+		}
+	}
+	sensorRegisterer := prometheus.WrapRegistererWithPrefix(*metricsPrefix,
+		prometheus.WrapRegistererWith(sensorConstLabels, reg))
+	for _, c := range []prometheus.Collector{sensorTempGauge, sensorHumidityGauge, sensorLastUpdateGauge} {
+		if err := sensorRegisterer.Register(c); err != nil {
+			return err
+		}
+	}
+	if metricsLocation != "" {
+		labels["location"] = metricsLocation
+	}
+	registerer := prometheus.WrapRegistererWithPrefix(*metricsPrefix,
+		prometheus.WrapRegistererWith(labels, reg))
+
+	collectors := []prometheus.Collector{
+		dhtReadsCounter,
+		dhtReadDurationHistogram,
+		sensorLastErrorGauge,
+		sensorLastErrorInfoGauge,
+		buildInfoGauge,
+		httpRequestsCounter,
+		httpDurationHistogram,
+		alertActiveGauge,
+		sync.RestartsCounter,
+		clock.SyncedGauge,
+		relayGauge,
+		relaySwitchesCounter,
+		throttledGauge,
+		throttledSinceBootGauge,
+	}
+	if *legacyMetrics {
+		collectors = append(collectors, tempGauge, humidityGauge, lastUpdateGauge)
+	}
+	if *systemMetrics {
+		collectors = append(collectors, systemLoadGauge, systemMemoryGauge, systemDiskGauge, systemSoCTempGauge)
+	}
+	if *ina219Enabled {
+		collectors = append(collectors, supplyVoltageGauge, supplyCurrentGauge, supplyPowerGauge)
+		if *batteryFullVoltage > 0 {
+			collectors = append(collectors, batteryGauge)
+		}
+	}
+	for _, c := range collectors {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+
+	info := version.GetInfo()
+	buildInfoGauge.WithLabelValues(info.Version, info.Revision, info.BuildDate, info.GoVersion).Set(1)
+	return nil
+}
+
+// recordReading updates the metrics with a successful reading from a local
+// sensor
+func recordReading(sensor string, s state.State) {
+	recordLocationReading(sensor, metricsLocation, s)
+	minmax.Add(*location, s)
+
+	tempGauge.Set(float64(s.Temperature))
+	humidityGauge.Set(float64(s.Humidity))
+	lastUpdateGauge.Set(float64(s.LastSensorUpdate.Unix()))
+}
+
+// recordLocationReading updates the labeled metrics with a successful reading
+// from sensor at loc
+func recordLocationReading(sensor, loc string, s state.State) {
+	sensorTempGauge.WithLabelValues(sensor, loc).Set(float64(s.Temperature))
+	sensorHumidityGauge.WithLabelValues(sensor, loc).Set(float64(s.Humidity))
+	sensorLastUpdateGauge.WithLabelValues(sensor, loc).Set(float64(s.LastSensorUpdate.Unix()))
+}
+
+// recordSource stores a successful reading from an additional source (a
+// remote node or BLE sensor), updating its metrics
+func recordSource(sensor, name string, s state.State) {
+	state.SetSource(name, s)
+	recordLocationReading(sensor, name, s)
+	minmax.Add(name, s)
+}
+
+// instrumented wraps h with request count and latency metrics, labeled with
+// handler (normally its mux pattern).
+func instrumented(handler string, h http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": handler}
+	return promhttp.InstrumentHandlerDuration(httpDurationHistogram.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(httpRequestsCounter.MustCurryWith(labels), h))
+}
+
+// serveMetrics serves /metrics on mux, or returns a separate server for it,
+// to be run with listen.Serve, as configured by flags.
+func serveMetrics(mux *http.ServeMux) *http.Server {
+	if !*metricsEnabled {
+		return nil
+	}
+
+	handler := instrumented("/metrics", promhttp.Handler())
+	if *metricsAddr == "" {
+		mux.Handle("/metrics", handler)
+		return nil
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", handler)
+	slog.Info("Serving metrics", "url", "http://"+*metricsAddr+"/metrics")
+	return &http.Server{Addr: *metricsAddr, Handler: metricsMux}
+}
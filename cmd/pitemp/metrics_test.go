@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func TestRegisterMetricsLocationLabel(t *testing.T) {
+	defer func(v string) { *metricsLabels = v }(*metricsLabels)
+	*metricsLabels = "location=bedroom,instance=attic"
+
+	reg := prometheus.NewRegistry()
+	if err := registerMetricsWith(reg); err != nil {
+		t.Fatalf("registerMetricsWith failed: %v", err)
+	}
+	var s state.State
+	s.SetReading("temperature", state.Reading{Value: 21.5, Unit: "celsius", Sensor: "dht11", MeasuredAt: time.Now()})
+	recordReading("dht11", s)
+	recordSource("remote", "garage", s)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, f := range families {
+		if f.GetName() != "pitemp_sensor_temperature_celsius" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["instance"] != "attic" {
+				t.Errorf("Got labels %v, want instance=attic", labels)
+			}
+			got[labels["location"]] = true
+		}
+	}
+	for _, want := range []string{"bedroom", "garage"} {
+		if !got[want] {
+			t.Errorf("No temperature with location=%s, got locations %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/modbus"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	modbusAddr      = flag.String("modbus_addr", "", "If set, serve readings as Modbus TCP registers on this address (e.g. :502)")
+	modbusUnitID    = flag.Int("modbus_unit_id", 1, "Modbus unit ID to respond to")
+	modbusRegisters = flag.String("modbus_registers", "temperature=0,humidity=1,age=2", "Comma-separated Modbus register map of VALUE=ADDRESS; see modbusValues for available values")
+)
+
+// modbusValues are the values available for the Modbus register map.
+// Temperature and humidity are in tenths (e.g. 215 for 21.5°C), with
+// temperature as a two's complement signed value; age is the number of
+// seconds since the last successful reading.
+var modbusValues = map[string]modbus.Register{
+	"temperature": func() uint16 {
+		return uint16(int16(math.Round(float64(state.Get().Temperature) * 10)))
+	},
+	"humidity": func() uint16 {
+		return uint16(math.Round(float64(state.Get().Humidity) * 10))
+	},
+	"age": func() uint16 {
+		s := state.Get()
+		if s.LastSensorUpdate.IsZero() {
+			return math.MaxUint16
+		}
+		age := time.Since(s.LastSensorUpdate).Seconds()
+		if age > math.MaxUint16 {
+			return math.MaxUint16
+		}
+		return uint16(age)
+	},
+}
+
+func parseModbusRegisters(s string) (map[uint16]modbus.Register, error) {
+	registers := map[uint16]modbus.Register{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid register %q, expected VALUE=ADDRESS", entry)
+		}
+		r, ok := modbusValues[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown value %q in register map", parts[0])
+		}
+		addr, err := strconv.ParseUint(parts[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address for %q: %w", parts[0], err)
+		}
+		if _, ok := registers[uint16(addr)]; ok {
+			return nil, fmt.Errorf("duplicate register address %d", addr)
+		}
+		registers[uint16(addr)] = r
+	}
+	return registers, nil
+}
+
+func serveModbus(ctx context.Context) error {
+	if *modbusAddr == "" {
+		return nil
+	}
+	registers, err := parseModbusRegisters(*modbusRegisters)
+	if err != nil {
+		return fmt.Errorf("invalid --modbus_registers: %w", err)
+	}
+	if *modbusUnitID < 0 || *modbusUnitID > 255 {
+		return fmt.Errorf("invalid --modbus_unit_id %d", *modbusUnitID)
+	}
+	return modbus.ListenAndServe(ctx, *modbusAddr, byte(*modbusUnitID), registers)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// negotiate returns the offered media type the Accept header prefers, or the
+// first offer if it expresses no preference among them. Ties go to the
+// earlier offer.
+func negotiate(accept string, offers ...string) string {
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q := quality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// quality returns the q-value accept assigns to mediaType, using the most
+// specific matching range.
+func quality(accept, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		r, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		var s int
+		switch {
+		case r == mediaType:
+			s = 2
+		case strings.HasSuffix(r, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(r, "*")):
+			s = 1
+		case r == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+	}
+	return q
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/otlp"
+	"github.com/lutzky/pitemp/internal/sync"
+)
+
+var (
+	otlpEndpoint = flag.String("otlp_endpoint", "", "If set, export metrics and traces to this OTLP/HTTP collector (e.g. http://localhost:4318)")
+	otlpInterval = flag.Duration("otlp_interval", time.Minute, "Frequency of OTLP exports")
+	otlpTraces   = flag.Bool("otlp_traces", true, "Export traces of sensor reads and HTTP requests, if --otlp_endpoint is set")
+)
+
+var (
+	otlpExporter *otlp.Exporter
+
+	// tracer is nil unless OTLP tracing is enabled; a nil tracer records
+	// nothing.
+	tracer *otlp.Tracer
+)
+
+func setupOTLP() {
+	if *otlpEndpoint == "" {
+		return
+	}
+	otlpExporter = &otlp.Exporter{
+		Endpoint:    *otlpEndpoint,
+		ServiceName: "pitemp",
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+	if *location != "" {
+		otlpExporter.Attributes = map[string]string{"location": *location}
+	}
+	if *otlpTraces {
+		tracer = otlp.NewTracer(otlpExporter)
+	}
+}
+
+// exportOTLP periodically exports metrics and queued spans until ctx is
+// cancelled, then flushes any remaining spans.
+func exportOTLP(ctx context.Context) {
+	if otlpExporter == nil {
+		return
+	}
+	sync.RepeatUntilCancelled(ctx, func() {
+		if err := otlpExporter.ExportMetrics(ctx, prometheus.DefaultGatherer, *metricsPrefix); err != nil {
+			slog.Error("Failed to export OTLP metrics", "err", err)
+		}
+		if err := tracer.Flush(ctx); err != nil {
+			slog.Error("Failed to export OTLP traces", "err", err)
+		}
+	}, *otlpInterval)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tracer.Flush(flushCtx); err != nil {
+		slog.Error("Failed to export OTLP traces", "err", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/alert"
+	"github.com/lutzky/pitemp/internal/plugin"
+	"github.com/lutzky/pitemp/pkg/sensor"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	outputPlugins repeated
+	notifyPlugins repeated
+
+	pluginTimeout = flag.Duration("plugin_timeout", 10*time.Second, "How long plugins (see --sensor=NAME=exec:COMMAND, --output_plugin and --notify_plugin) have to respond before being restarted")
+)
+
+func init() {
+	flag.Var(&outputPlugins, "output_plugin", "Command of a plugin to send every reading to, as JSON lines on its stdin; may be repeated")
+	flag.Var(&notifyPlugins, "notify_plugin", "Command of a plugin to send alert notifications to, as JSON lines on its stdin; may be repeated")
+
+	sensor.Register("exec", newPluginSensor)
+}
+
+// pluginSensor is a sensor read by a plugin, configured as its command
+type pluginSensor struct {
+	p *plugin.Process
+}
+
+func newPluginSensor(command string) (sensor.Sensor, error) {
+	p, err := plugin.New(command, *pluginTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return pluginSensor{p}, nil
+}
+
+func (s pluginSensor) Read(ctx context.Context) (map[string]state.Reading, error) {
+	resp, err := s.p.Call(ctx, plugin.Request{Type: "read"})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	readings := map[string]state.Reading{}
+	for name, r := range resp.Readings {
+		if r.MeasuredAt.IsZero() {
+			r.MeasuredAt = now
+		}
+		if r.Sensor == "" {
+			r.Sensor = s.p.Name()
+		}
+		readings[name] = state.Reading{Value: r.Value, Unit: r.Unit, Sensor: r.Sensor, MeasuredAt: r.MeasuredAt}
+	}
+	return readings, nil
+}
+
+func (s pluginSensor) Close() error {
+	return s.p.Close()
+}
+
+// setupPlugins starts the output and notification plugins; call it after
+// setupAlerts
+func setupPlugins() error {
+	for _, command := range outputPlugins {
+		p, err := plugin.New(command, *pluginTimeout)
+		if err != nil {
+			return fmt.Errorf("--output_plugin: %w", err)
+		}
+		write := func(ctx context.Context, s state.State) error {
+			w := wireState(s)
+			_, err := p.Call(ctx, plugin.Request{Type: "state", State: &w})
+			return err
+		}
+		sinks = append(sinks, sink{name: "plugin " + p.Name(), write: write, close: p.Close})
+	}
+
+	for _, command := range notifyPlugins {
+		p, err := plugin.New(command, *pluginTimeout)
+		if err != nil {
+			return fmt.Errorf("--notify_plugin: %w", err)
+		}
+		alerts.AddNotifier(alert.NotifierFunc(func(ctx context.Context, e alert.Event) error {
+			a := plugin.Alert{Rule: e.Rule.Name, Firing: e.Firing, Value: e.Value, Time: e.Time}
+			_, err := p.Call(ctx, plugin.Request{Type: "alert", Alert: &a})
+			return err
+		}))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/sensor/ina219"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	ina219Enabled       = flag.Bool("ina219", false, "Monitor this node's power supply with an INA219, reporting voltage, current and power readings (and battery, with --battery_full_voltage)")
+	ina219Bus           = flag.Int("ina219_bus", 1, "I2C bus of the INA219")
+	ina219Addr          = flag.Uint("ina219_addr", 0x40, "I2C address of the INA219")
+	ina219Shunt         = flag.Float64("ina219_shunt", 0.1, "Resistance of the INA219's shunt resistor, in ohms")
+	powerInterval       = flag.Duration("power_interval", time.Minute, "Frequency of reading the INA219")
+	batteryEmptyVoltage = flag.Float64("battery_empty_voltage", 3.3, "Supply voltage of an empty battery, reported as 0% charge")
+	batteryFullVoltage  = flag.Float64("battery_full_voltage", 0, "Supply voltage of a full battery, reported as 100% charge; if set, a battery reading is estimated from the voltage, e.g. for low:battery<20 alerts")
+)
+
+var (
+	supplyVoltageGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "supply_voltage_volts",
+		Help: "Supply voltage measured by the INA219",
+	})
+	supplyCurrentGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "supply_current_amperes",
+		Help: "Supply current measured by the INA219",
+	})
+	supplyPowerGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "supply_power_watts",
+		Help: "Supply power measured by the INA219",
+	})
+	batteryGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "battery_percent",
+		Help: "Battery charge, estimated from the supply voltage",
+	})
+)
+
+// setupPower starts monitoring the power supply, if enabled by --ina219
+func setupPower(ctx context.Context) error {
+	if !*ina219Enabled {
+		return nil
+	}
+	d, err := ina219.Open(*ina219Bus, uint8(*ina219Addr), *ina219Shunt)
+	if err != nil {
+		return fmt.Errorf("ina219: %w", err)
+	}
+	workers.Supervise(ctx, "ina219", func() {
+		sync.RepeatUntilCancelled(ctx, func() { powerUpdater(ctx, d) }, *powerInterval, readSchedule()...)
+	})
+	workers.Go(func() {
+		<-ctx.Done()
+		if err := d.Close(); err != nil {
+			slog.Error("Failed to close INA219", "err", err)
+		}
+	})
+	return nil
+}
+
+// powerUpdater reads the INA219 into the state, and evaluates alerts (e.g.
+// for a low battery)
+func powerUpdater(ctx context.Context, d *ina219.INA219) {
+	readings, err := d.Read(ctx)
+	if err != nil {
+		slog.Error("Failed to read INA219", "sensor", "ina219", "err", err)
+		return
+	}
+	voltage := readings["voltage"]
+	if *batteryFullVoltage > 0 {
+		readings["battery"] = state.Reading{
+			Value:      float32(batteryPercent(float64(voltage.Value))),
+			Unit:       "percent",
+			Sensor:     "ina219",
+			MeasuredAt: voltage.MeasuredAt,
+		}
+	}
+	slog.Debug("Read INA219", "sensor", "ina219", "voltage", voltage.Value, "current", readings["current"].Value)
+
+	state.Update(func(s *state.State) {
+		// LastSensorUpdate tells how fresh the climate readings are; the
+		// power supply being readable says nothing about that
+		last := s.LastSensorUpdate
+		for name, r := range readings {
+			s.SetReading(name, r)
+		}
+		s.LastSensorUpdate = last
+	})
+
+	supplyVoltageGauge.Set(float64(voltage.Value))
+	supplyCurrentGauge.Set(float64(readings["current"].Value))
+	supplyPowerGauge.Set(float64(readings["power"].Value))
+	if b, ok := readings["battery"]; ok {
+		batteryGauge.Set(float64(b.Value))
+	}
+}
+
+// batteryPercent estimates the battery charge from its voltage, linearly
+// between --battery_empty_voltage and --battery_full_voltage. That's crude
+// (discharge curves are anything but linear), but enough to warn before the
+// node dies.
+func batteryPercent(voltage float64) float64 {
+	p := (voltage - *batteryEmptyVoltage) / (*batteryFullVoltage - *batteryEmptyVoltage) * 100
+	return math.Max(0, math.Min(100, math.Round(p)))
+}
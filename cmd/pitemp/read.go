@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/shutdown"
+)
+
+var readFormat = flag.String("format", "json", `Output format for the read command: json, text (e.g. "21.0 C 45 %"), temperature or humidity (just the value)`)
+
+// readMain implements "pitemp read", printing a single DHT11 reading as JSON
+// or, with --format, as plain text for shell pipelines. It exits non-zero if
+// the sensor can't be read, making it suitable for cron jobs.
+func readMain() int {
+	switch *readFormat {
+	case "json", "text", "temperature", "humidity":
+	default:
+		slog.Error("Unknown --format, expected json, text, temperature or humidity", "format", *readFormat)
+		return 2
+	}
+
+	ctx, stop := shutdown.OnSignal(context.Background())
+	defer stop()
+
+	temperature, humidity, err := readDHT(ctx)
+	if err != nil {
+		// readDHT logs the details
+		return 1
+	}
+	s := climateState("dht11", "", temperature, humidity, time.Now())
+
+	switch *readFormat {
+	case "json":
+		if err := json.NewEncoder(os.Stdout).Encode(s.Readings); err != nil {
+			slog.Error("Error encoding JSON", "err", err)
+			return 1
+		}
+	case "text":
+		fmt.Println(plainText(s))
+	case "temperature":
+		fmt.Printf("%.1f\n", s.Temperature)
+	case "humidity":
+		fmt.Printf("%.0f\n", s.Humidity)
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/lutzky/pitemp/internal/sync"
+)
+
+// reloads receives requests to restart the sensor loops, each answered on
+// its channel once they're restarted
+var reloads = make(chan chan error)
+
+// startSensors starts reading the DHT11 and the sensors given by --sensor
+// until ctx is cancelled, restarting them (and reopening the sensors) on
+// requests to /api/reload
+func startSensors(ctx context.Context) error {
+	stop, err := openSensors(ctx)
+	if err != nil {
+		return err
+	}
+	workers.Go(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case reply := <-reloads:
+				slog.Info("Restarting sensors")
+				stop()
+				stop, err = openSensors(ctx)
+				if err != nil {
+					stop = func() {}
+				}
+				reply <- err
+			}
+		}
+	})
+	return nil
+}
+
+// openSensors opens the sensors and starts reading them, returning a
+// function which stops reading them and waits for them to be closed
+func openSensors(ctx context.Context) (stop func(), err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var g sync.Group
+	stop = func() {
+		cancel()
+		g.Wait(context.Background())
+	}
+	if err := setupSensors(ctx, &g); err != nil {
+		stop()
+		return nil, err
+	}
+	if *dhtEnabled {
+		g.Supervise(ctx, "dht11", func() {
+			sync.RepeatUntilCancelled(ctx, func() { dhtUpdater(ctx) }, dhtDelay.Load(), append(readSchedule(), sync.WithInterval(dhtDelay.Load))...)
+		})
+	}
+	return stop, nil
+}
+
+// serveReload restarts the sensor loops on POST, reopening the sensors and
+// reading them immediately, e.g. for a DHT11 which stopped responding
+func serveReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reply := make(chan error, 1)
+	select {
+	case reloads <- reply:
+	case <-r.Context().Done():
+		return
+	}
+	if err := <-reply; err != nil {
+		http.Error(w, fmt.Sprintf("Failed to restart sensors: %v", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "Restarted sensors")
+}
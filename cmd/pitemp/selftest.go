@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/lutzky/pitemp/internal/app/shutdown"
+)
+
+// selftestCheck checks one aspect of the setup, returning details to show
+// on success
+type selftestCheck struct {
+	name  string
+	check func(ctx context.Context) (string, error)
+}
+
+var selftestChecks = []selftestCheck{
+	{"data directory", func(context.Context) (string, error) {
+		p, err := dataPath(".selftest")
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(p, nil, 0600); err != nil {
+			return "", fmt.Errorf("not writable: %w", err)
+		}
+		return *dataDir, os.Remove(p)
+	}},
+	{"alerts", func(context.Context) (string, error) {
+		return fmt.Sprintf("%d rules", len(alertRules)), setupAlerts()
+	}},
+	{"outputs", func(ctx context.Context) (string, error) {
+		defer closeSinks()
+		if err := setupSinks(ctx); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d configured", len(sinks)), nil
+	}},
+	{"dht11", func(ctx context.Context) (string, error) {
+		if !*dhtEnabled {
+			return "disabled", nil
+		}
+		temperature, humidity, err := readDHT(ctx)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%.1f°C, %.0f%%", temperature, humidity), nil
+	}},
+}
+
+// selftestMain implements "pitemp selftest", checking the data directory,
+// configuration and sensor without starting the server. It exits non-zero
+// if any check fails.
+func selftestMain() int {
+	ctx, stop := shutdown.OnSignal(context.Background())
+	defer stop()
+
+	status := 0
+	for _, c := range selftestChecks {
+		details, err := c.check(ctx)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", c.name, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("ok   %s: %s\n", c.name, details)
+	}
+	return status
+}
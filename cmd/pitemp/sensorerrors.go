@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// sensorError is the last failed read of a sensor, as served on /api
+type sensorError struct {
+	Error     string    `json:"error"`
+	ErrorType string    `json:"error_type"`
+	Time      time.Time `json:"time"`
+}
+
+// sensorErrors holds the last sensorError of each sensor, by name
+var sensorErrors struct {
+	mu     sync.Mutex
+	errors map[string]sensorError
+}
+
+// recordSensorError records a failed read of sensor (dht11, or the
+// --sensor name) for /api and the metrics
+func recordSensorError(sensor string, err error) {
+	e := sensorError{Error: err.Error(), ErrorType: errorType(err), Time: time.Now()}
+
+	sensorErrors.mu.Lock()
+	defer sensorErrors.mu.Unlock()
+	if last, ok := sensorErrors.errors[sensor]; ok {
+		sensorLastErrorInfoGauge.DeleteLabelValues(sensor, last.ErrorType)
+	}
+	if sensorErrors.errors == nil {
+		sensorErrors.errors = map[string]sensorError{}
+	}
+	sensorErrors.errors[sensor] = e
+
+	sensorLastErrorGauge.WithLabelValues(sensor).Set(float64(e.Time.Unix()))
+	sensorLastErrorInfoGauge.WithLabelValues(sensor, e.ErrorType).Set(1)
+}
+
+// lastSensorErrors returns the last failed read of each sensor, by name, or
+// nil if none failed
+func lastSensorErrors() map[string]sensorError {
+	sensorErrors.mu.Lock()
+	defer sensorErrors.mu.Unlock()
+	if len(sensorErrors.errors) == 0 {
+		return nil
+	}
+	result := make(map[string]sensorError, len(sensorErrors.errors))
+	for name, e := range sensorErrors.errors {
+		result[name] = e
+	}
+	return result
+}
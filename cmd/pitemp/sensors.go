@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/settings"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/sensor"
+	"github.com/lutzky/pitemp/pkg/state"
+
+	// Built-in sensor drivers; out-of-tree drivers can be added the same way
+	_ "github.com/lutzky/pitemp/pkg/sensor/dht11"
+)
+
+// repeated is the value of a repeatable flag
+type repeated []string
+
+func (s *repeated) String() string { return strings.Join(*s, " ") }
+
+func (s *repeated) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+var (
+	sensorFlags    repeated
+	sensorInterval = settings.NewDuration("sensor_interval", time.Minute, "Frequency of reading the sensors given by --sensor; adjustable at runtime")
+)
+
+func init() {
+	flag.Var(&sensorFlags, "sensor", "Additional sensor, as NAME=DRIVER[:KEY=VALUE,...], e.g. garage=dht11:pin=17; its readings are shown as another location. May be repeated")
+}
+
+// parseSensor parses a --sensor value into a location name and a sensor
+func parseSensor(spec string) (string, sensor.Sensor, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", nil, fmt.Errorf("invalid --sensor %q, expected NAME=DRIVER[:CONFIG]", spec)
+	}
+	driver, config := parts[1], ""
+	if i := strings.Index(driver, ":"); i >= 0 {
+		driver, config = driver[:i], driver[i+1:]
+	}
+	s, err := sensor.New(driver, config)
+	if err != nil {
+		return "", nil, fmt.Errorf("--sensor %s: %w", parts[0], err)
+	}
+	return parts[0], s, nil
+}
+
+// setupSensors starts reading the sensors given by --sensor in g, storing
+// their readings as additional sources.
+func setupSensors(ctx context.Context, g *sync.Group) error {
+	sensors := map[string]sensor.Sensor{}
+	for _, spec := range sensorFlags {
+		name, s, err := parseSensor(spec)
+		if err != nil {
+			return err
+		}
+		if _, ok := sensors[name]; ok {
+			return fmt.Errorf("duplicate --sensor name %q", name)
+		}
+		sensors[name] = s
+	}
+
+	for name, s := range sensors {
+		name, s := name, s
+		g.Supervise(ctx, "sensor "+name, func() {
+			sync.RepeatUntilCancelled(ctx, func() { readSensor(ctx, name, s) }, sensorInterval.Load(), append(readSchedule(), sync.WithInterval(sensorInterval.Load))...)
+			if c, ok := s.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					slog.Error("Failed to close sensor", "name", name, "err", err)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// readSensor reads s, storing its readings as the source called name
+func readSensor(ctx context.Context, name string, s sensor.Sensor) {
+	readings, err := s.Read(ctx)
+	if err != nil {
+		slog.Error("Failed to read sensor", "name", name, "err", err)
+		recordSensorError(name, err)
+		return
+	}
+	var st state.State
+	driver := ""
+	for reading, r := range readings {
+		r.Node = ""
+		st.SetReading(reading, r)
+		driver = r.Sensor
+	}
+	recordSource(driver, name, st)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lutzky/pitemp/pkg/sensor/dht11"
+	"github.com/lutzky/pitemp/pkg/sensor/sensortest"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func TestReadSensor(t *testing.T) {
+	ctx := context.Background()
+	var s sensortest.Sensor
+	s.Set(map[string]state.Reading{
+		"temperature": {Value: 12.5, Unit: "celsius", Sensor: "fake", Node: "ignored"},
+	})
+
+	readSensor(ctx, "garage", &s)
+	r, ok := state.Sources()["garage"].Reading("temperature")
+	if !ok {
+		t.Fatal("No temperature for garage")
+	}
+	if r.Value != 12.5 || r.Node != "" || r.MeasuredAt.IsZero() {
+		t.Errorf("Got %+v, want 12.5 with no node, measured now", r)
+	}
+
+	// Failures keep the last readings
+	s.Fail(errors.New("sensor unplugged"))
+	readSensor(ctx, "garage", &s)
+	if got := state.Sources()["garage"].Temperature; got != 12.5 {
+		t.Errorf("Temperature after a failure = %v, want 12.5", got)
+	}
+	if s.Reads() != 2 {
+		t.Errorf("Read the sensor %d times, want 2", s.Reads())
+	}
+}
+
+func TestDHTUpdater(t *testing.T) {
+	defer func(r dht11.Reader) { dhtReader = r }(dhtReader)
+	fake := &sensortest.DHT11{}
+	dhtReader = fake
+	if err := setupAlerts(); err != nil {
+		t.Fatalf("setupAlerts failed: %v", err)
+	}
+	ctx := context.Background()
+
+	fake.Set(21, 40, 2)
+	dhtUpdater(ctx)
+	s := state.Get()
+	if s.Temperature != 21 || s.Humidity != 40 {
+		t.Errorf("Got %v°C, %v%%, want 21°C, 40%%", s.Temperature, s.Humidity)
+	}
+
+	fake.Fail(errors.New("checksum mismatch"))
+	dhtUpdater(ctx)
+	if s := state.Get(); s.Temperature != 21 {
+		t.Errorf("Temperature after a failure = %v, want 21", s.Temperature)
+	}
+	if e := lastSensorErrors()["dht11"]; e.Error != "checksum mismatch" || e.Time.IsZero() {
+		t.Errorf("Last DHT11 error = %+v, want the checksum mismatch", e)
+	}
+}
+
+func BenchmarkReadSensor(b *testing.B) {
+	ctx := context.Background()
+	var s sensortest.Sensor
+	s.Set(map[string]state.Reading{
+		"temperature": {Value: 12.5, Unit: "celsius", Sensor: "fake"},
+		"humidity":    {Value: 60, Unit: "percent", Sensor: "fake"},
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readSensor(ctx, "garage", &s)
+	}
+}
+
+func BenchmarkDHTUpdater(b *testing.B) {
+	defer func(r dht11.Reader) { dhtReader = r }(dhtReader)
+	fake := &sensortest.DHT11{}
+	fake.Set(21, 40, 0)
+	dhtReader = fake
+	if err := setupAlerts(); err != nil {
+		b.Fatalf("setupAlerts failed: %v", err)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dhtUpdater(ctx)
+	}
+}
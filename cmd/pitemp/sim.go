@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/accesslog"
+	"github.com/lutzky/pitemp/internal/app/listen"
+	"github.com/lutzky/pitemp/internal/app/shutdown"
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/pkg/client"
+	"github.com/lutzky/pitemp/pkg/lcd"
+	"github.com/lutzky/pitemp/pkg/lcd/lcdtest"
+	"github.com/lutzky/pitemp/pkg/pioled"
+	"github.com/lutzky/pitemp/pkg/pioled/pioledtest"
+	"github.com/lutzky/pitemp/pkg/sensor/sensortest"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	simRooms    = flag.String("sim_rooms", "garage", "Comma-separated names of simulated rooms for the sim command, each shown on a page of its own")
+	simInterval = flag.Duration("sim_interval", 5*time.Second, "How often the sim command's fake sensors change, and are fetched")
+)
+
+//go:embed sim.html
+var simHTML []byte
+
+// simMain implements "pitemp sim": the display pipeline, from fake sensors
+// through the client to the LCD and PiOLED layouts, with previews of both
+// served on --port, for working on layouts without any hardware.
+func simMain() int {
+	// Whether or not the desktop's clock is synchronized, the layouts
+	// should show the time
+	clock.MarkValid()
+
+	nodes := map[string]*simNode{"main": newSimNode()}
+	opts := client.Options{
+		Servers:        []string{"main"},
+		FetchInterval:  *simInterval,
+		UpdateInterval: time.Second,
+		Fetch: func(ctx context.Context, server string) (*state.State, error) {
+			return nodes[server].read(ctx)
+		},
+	}
+	for _, name := range strings.Split(*simRooms, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			nodes[name] = newSimNode()
+			opts.Rooms = append(opts.Rooms, client.Room{Name: name, URL: name})
+		}
+	}
+
+	var sim simDisplays
+	opts.Update = sim.update
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(simHTML)
+	})
+	mux.HandleFunc("/oled.png", sim.serveOLED)
+	mux.HandleFunc("/lcd", sim.serveLCD)
+	l, err := listen.Listen(*flagPort)
+	if err != nil {
+		slog.Error("Failed to listen", "err", err)
+		return 1
+	}
+	srv := http.Server{Handler: accesslog.Handler(mux)}
+	go srv.Serve(l)
+	slog.Info("Serving display previews", "addr", l.Addr().String())
+
+	ctx, stop := shutdown.OnSignal(context.Background())
+	defer stop()
+	runErr := client.Run(ctx, opts)
+
+	shutdownCtx, cancel := shutdown.Context()
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to cleanly shut down HTTP server", "err", err)
+	}
+	if runErr != nil {
+		slog.Error("Simulation failed", "err", runErr)
+		return 1
+	}
+	return 0
+}
+
+// simNode is a simulated node, whose fake sensor's readings drift randomly
+// between reads
+type simNode struct {
+	sensor                sensortest.Sensor
+	temperature, humidity float64
+}
+
+func newSimNode() *simNode {
+	return &simNode{temperature: 15 + rand.Float64()*10, humidity: 30 + rand.Float64()*40}
+}
+
+// read drifts the readings, and reads them like a node would, with trends
+// from how far they drifted
+func (n *simNode) read(ctx context.Context) (*state.State, error) {
+	temperature, humidity := n.temperature, n.humidity
+	n.temperature += rand.NormFloat64() * 0.3
+	n.humidity += rand.NormFloat64()
+	if n.humidity < 0 {
+		n.humidity = 0
+	} else if n.humidity > 100 {
+		n.humidity = 100
+	}
+	n.sensor.Set(map[string]state.Reading{
+		"temperature": {Value: float32(n.temperature), Unit: "celsius", Sensor: "sim"},
+		"humidity":    {Value: float32(n.humidity), Unit: "percent", Sensor: "sim"},
+	})
+
+	readings, err := n.sensor.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var s state.State
+	for name, r := range readings {
+		s.SetReading(name, r)
+	}
+	// The readings drift about as much per read as a room's might in a few
+	// minutes, so there's a mix of rising, falling and steady trends
+	const perHour = 10
+	s.Trends = map[string]float32{
+		"temperature": float32((n.temperature - temperature) * perHour),
+		"humidity":    float32((n.humidity - humidity) * perHour),
+	}
+	return &s, nil
+}
+
+// simDisplays drives an in-memory LCD and PiOLED, as the display command
+// would drive real ones
+type simDisplays struct {
+	mu sync.Mutex
+
+	lcdDev  lcdtest.Device
+	oledDev pioledtest.Device
+
+	// lcdDisplay and oledDisplay are created on the first update
+	lcdDisplay  *lcd.LCD
+	oledDisplay *pioled.PiOLED
+}
+
+func (d *simDisplays) update() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lcdDisplay == nil {
+		d.lcdDev.Backlight = true
+		d.lcdDisplay = lcd.New(&d.lcdDev)
+		d.oledDisplay = pioled.New(&d.oledDev)
+	}
+	d.lcdDisplay.Display()
+	return d.oledDisplay.Display()
+}
+
+// serveOLED serves what the PiOLED shows, as a PNG image
+func (d *simDisplays) serveOLED(w http.ResponseWriter, _ *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var img image.Image = d.oledDev.Frame
+	if d.oledDev.Frame == nil {
+		img = image.NewGray(d.oledDev.Bounds())
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := png.Encode(w, img); err != nil {
+		slog.Error("Failed to encode PNG", "err", err)
+	}
+}
+
+// lcdCharset maps the LCD's character set, which is ASCII except for its
+// degree symbol and trend arrows, to UTF-8
+var lcdCharset = strings.NewReplacer(
+	string([]byte{lcd.DegreeSymbol}), "°",
+	string([]byte{lcd.ArrowUp}), "↑",
+	string([]byte{lcd.ArrowDown}), "↓",
+	string([]byte{lcd.ArrowRight}), "→",
+)
+
+// serveLCD serves what the LCD shows, as text
+func (d *simDisplays) serveLCD(w http.ResponseWriter, _ *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	for i := 0; i < lcdtest.Height; i++ {
+		line := lcdCharset.Replace(d.lcdDev.Line(i))
+		fmt.Fprintln(w, line)
+	}
+}
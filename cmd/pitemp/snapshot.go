@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/lutzky/pitemp/internal/history"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// snapshot is the full persistent state of a node, used for backups and for
+// migrating a node to new hardware.
+type snapshot struct {
+	State   state.State
+	History []state.State
+}
+
+// serveSnapshot dumps the snapshot on GET, and restores it on PUT using
+// restore, which should be restoreSnapshot wrapped in auth.Required
+func serveSnapshot(restore http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", `attachment; filename="pitemp-snapshot.json"`)
+			snap := snapshot{
+				State:   state.Get(),
+				History: history.Get(),
+			}
+			if err := json.NewEncoder(w).Encode(snap); err != nil {
+				slog.Error("Error encoding snapshot", "err", err)
+			}
+		case http.MethodPut:
+			restore(w, r)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// restoreSnapshot replaces the state and history with the snapshot in the
+// request body
+func restoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	var snap snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+		http.Error(w, "Invalid snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The location and IP address are this node's, wherever the snapshot
+	// was taken
+	snap.State.Location = *location
+	snap.State.IP = state.Get().IP
+	state.Set(&snap.State)
+	history.Set(snap.History)
+	slog.Info("Restored snapshot", "history_entries", len(snap.History))
+	w.WriteHeader(http.StatusNoContent)
+}
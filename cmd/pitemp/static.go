@@ -0,0 +1,35 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"mime"
+	"net/http"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+func init() {
+	// Not included in the default MIME types
+	mime.AddExtensionType(".webmanifest", "application/manifest+json")
+}
+
+// registerStatic serves the embedded stylesheet, icons and web manifest
+// under /static/, and the favicon at /favicon.ico, where browsers look for
+// it regardless of the page.
+func registerStatic(handle func(pattern string, h http.HandlerFunc)) {
+	sub, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FileServer(http.FS(sub))
+	cached := func(h http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+			h.ServeHTTP(w, r)
+		}
+	}
+	handle("/static/", compress(cached(http.StripPrefix("/static", files))))
+	handle("/favicon.ico", cached(files))
+}
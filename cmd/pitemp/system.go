@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/app/sysinfo"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	systemMetrics  = flag.Bool("system_metrics", false, "Collect the host's load average, memory and disk usage (of --system_disk) and SoC temperature, exporting them as metrics and on /api")
+	systemInterval = flag.Duration("system_interval", 30*time.Second, "Frequency of collecting --system_metrics")
+)
+
+var (
+	systemLoadGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "system_load",
+		Help: "Load average of the host, by period (1m, 5m or 15m)",
+	}, []string{"period"})
+	systemMemoryGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "system_memory_used_percent",
+		Help: "Memory in use on the host, not counting reclaimable caches",
+	})
+	systemDiskGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "system_disk_used_percent",
+		Help: "Disk space in use on --system_disk",
+	})
+	// systemSoCTempGauge is an unlabeled vector, so that it's only exported
+	// once set, rather than as 0 on hosts without a known SoC temperature
+	systemSoCTempGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "system_soc_temperature_celsius",
+		Help: "Temperature of the host's system-on-chip",
+	}, nil)
+)
+
+// setupSystem starts collecting the host's stats, if enabled by
+// --system_metrics
+func setupSystem(ctx context.Context) {
+	if !*systemMetrics {
+		return
+	}
+	workers.Supervise(ctx, "system", func() {
+		sync.RepeatUntilCancelled(ctx, func() {
+			sys, err := sysinfo.Read()
+			if err != nil {
+				slog.Error("Failed to collect system stats", "err", err)
+				return
+			}
+			recordSystem(sys)
+		}, *systemInterval)
+	})
+}
+
+// recordSystem updates the state and metrics with the host's stats
+func recordSystem(sys state.System) {
+	state.Update(func(s *state.State) { s.System = &sys })
+
+	systemLoadGauge.WithLabelValues("1m").Set(sys.Load1)
+	systemLoadGauge.WithLabelValues("5m").Set(sys.Load5)
+	systemLoadGauge.WithLabelValues("15m").Set(sys.Load15)
+	systemMemoryGauge.Set(sys.MemoryUsedPercent)
+	systemDiskGauge.Set(sys.DiskUsedPercent)
+	if sys.SoCTemperature != 0 {
+		systemSoCTempGauge.WithLabelValues().Set(sys.SoCTemperature)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/internal/version"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var templatePath = flag.String("template", "", "If set, use this HTML template file instead of the built-in one; it is reloaded when modified")
+
+//go:embed template.html
+var httpTemplateText string
+
+var defaultTemplate = template.Must(template.New("root").Funcs(templateFuncs).Parse(httpTemplateText))
+
+// startTime is used to report uptime
+var startTime = time.Now()
+
+// templateData is passed to the HTML template
+type templateData struct {
+	state.State
+
+	// Sources are additional sources (remote nodes and BLE sensors), by name
+	Sources map[string]state.State
+
+	Hostname, Location, Version string
+	Uptime                      time.Duration
+
+	// Freshness is the time since the last successful reading, or 0 if
+	// there was none
+	Freshness time.Duration
+}
+
+func newTemplateData() templateData {
+	hostname, _ := os.Hostname()
+	d := templateData{
+		State:    state.Get(),
+		Sources:  state.Sources(),
+		Hostname: hostname,
+		Location: *location,
+		Version:  version.Get(),
+		Uptime:   time.Since(startTime),
+	}
+	d.ClockInvalid = !clock.Valid()
+	d.ActiveAlerts = activeAlerts()
+	if !d.LastSensorUpdate.IsZero() {
+		d.Freshness = time.Since(d.LastSensorUpdate)
+	}
+	return d
+}
+
+// templateFuncs are available in the HTML template, e.g.:
+//
+//	{{.Temperature | fixed 1}}
+//	{{.LastSensorUpdate | ago}}
+//	{{.Uptime | round}}
+var templateFuncs = template.FuncMap{
+	// ago formats the time since t, e.g. "42s ago"
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return humanDuration(time.Since(t)) + " ago"
+	},
+	// rfc3339 formats t for machine consumption, e.g. by JavaScript
+	"rfc3339": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+	// round rounds a duration to seconds
+	"round": func(d time.Duration) time.Duration {
+		return d.Round(time.Second)
+	},
+	// human formats a duration in its largest unit, e.g. "3h"
+	"human": humanDuration,
+	// fixed formats a number with the given number of decimals
+	"fixed": func(decimals int, f float32) string {
+		return strconv.FormatFloat(float64(f), 'f', decimals, 32)
+	},
+	// fahrenheit converts Celsius to Fahrenheit
+	"fahrenheit": func(c float32) float32 {
+		return c*9/5 + 32
+	},
+}
+
+// humanDuration formats d in its largest unit, e.g. "42s", "3m" or "2d"
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// overrideTemplate holds the template parsed from --template, and the
+// modification time of the file it was parsed from.
+var overrideTemplate struct {
+	mu      sync.Mutex
+	tmpl    *template.Template
+	modTime time.Time
+}
+
+// currentTemplate returns the template for the HTML page. If --template is
+// set, the file is re-parsed whenever its modification time changes; if it
+// can't be read or parsed, the last good version (or the built-in template)
+// is used.
+func currentTemplate() *template.Template {
+	if *templatePath == "" {
+		return defaultTemplate
+	}
+
+	overrideTemplate.mu.Lock()
+	defer overrideTemplate.mu.Unlock()
+
+	fallback := overrideTemplate.tmpl
+	if fallback == nil {
+		fallback = defaultTemplate
+	}
+
+	fi, err := os.Stat(*templatePath)
+	if err != nil {
+		slog.Error("Failed to stat template", "err", err)
+		return fallback
+	}
+	if fi.ModTime().Equal(overrideTemplate.modTime) {
+		return fallback
+	}
+
+	// Record the modification time even on failure, so a broken template
+	// is only reported once per change
+	overrideTemplate.modTime = fi.ModTime()
+	tmpl, err := template.New(filepath.Base(*templatePath)).Funcs(templateFuncs).ParseFiles(*templatePath)
+	if err != nil {
+		slog.Error("Failed to parse template", "path", *templatePath, "err", err)
+		return fallback
+	}
+	slog.Info("Loaded template", "path", *templatePath)
+	overrideTemplate.tmpl = tmpl
+	return tmpl
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/gpio"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/internal/thermostat"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	thermostatMode       = flag.String("thermostat_mode", "", "If set, switch a relay on --thermostat_gpio to control a heater (by temperature), humidifier or dehumidifier (by humidity)")
+	thermostatGPIO       = flag.Int("thermostat_gpio", 17, "GPIO pin of the thermostat relay")
+	thermostatActiveLow  = flag.Bool("thermostat_active_low", false, "Drive the thermostat GPIO low to switch the relay on, as many relay boards expect")
+	thermostatSetpoint   = flag.Float64("thermostat_setpoint", 20, "Target temperature (°C) or humidity (%) for the thermostat")
+	thermostatHysteresis = flag.Float64("thermostat_hysteresis", 0.5, "How far the reading may stray from --thermostat_setpoint before the relay is switched, in either direction")
+	thermostatMinOn      = flag.Duration("thermostat_min_on", 5*time.Minute, "Shortest time the thermostat relay stays on")
+	thermostatMinOff     = flag.Duration("thermostat_min_off", 5*time.Minute, "Shortest time the thermostat relay stays off")
+)
+
+// thermostatInterval is how often the thermostat checks the state
+const thermostatInterval = 10 * time.Second
+
+var (
+	relayGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "relay_on",
+		Help: "Whether a relay is on (1) or off (0), by relay",
+	}, []string{"relay"})
+	relaySwitchesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_switches_total",
+		Help: "Times a relay was switched on or off, by relay",
+	}, []string{"relay"})
+)
+
+// setupThermostat starts the thermostat, if enabled by --thermostat_mode
+func setupThermostat(ctx context.Context) error {
+	if *thermostatMode == "" {
+		return nil
+	}
+	mode, err := thermostat.ParseMode(*thermostatMode)
+	if err != nil {
+		return err
+	}
+	relay, err := gpio.OpenOutput(*thermostatGPIO, *thermostatActiveLow)
+	if err != nil {
+		return fmt.Errorf("thermostat: %w", err)
+	}
+	c := thermostat.New(thermostat.Config{
+		Mode:       mode,
+		Setpoint:   float32(*thermostatSetpoint),
+		Hysteresis: float32(*thermostatHysteresis),
+		MinOn:      *thermostatMinOn,
+		MinOff:     *thermostatMinOff,
+	}, relay)
+
+	name := string(mode)
+	recordRelay(name, false)
+	// The relay is switched off only once the loop is done, so that an
+	// update in progress can't switch it back on
+	workers.Go(func() {
+		sync.Supervise(ctx, "thermostat", func() {
+			on := false
+			sync.RepeatUntilCancelled(ctx, func() {
+				now, err := c.Update(state.Get(), time.Now())
+				if err != nil {
+					slog.Error("Failed to switch relay", "relay", name, "err", err)
+				}
+				if now != on {
+					slog.Info("Switched relay", "relay", name, "on", now)
+					relaySwitchesCounter.WithLabelValues(name).Inc()
+					on = now
+					recordRelay(name, on)
+				}
+			}, thermostatInterval)
+		})
+		if err := relay.Close(); err != nil {
+			slog.Error("Failed to switch off relay", "relay", name, "err", err)
+		}
+		recordRelay(name, false)
+	})
+	return nil
+}
+
+// recordRelay updates the state and metrics with the state of a relay
+func recordRelay(name string, on bool) {
+	state.Update(func(s *state.State) { s.SetRelay(name, on) })
+	v := 0.0
+	if on {
+		v = 1
+	}
+	relayGauge.WithLabelValues(name).Set(v)
+}
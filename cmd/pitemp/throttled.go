@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/internal/throttled"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var throttledInterval = flag.Duration("throttled_interval", time.Minute, "Frequency of checking the Raspberry Pi's undervoltage and throttling flags (as in vcgencmd get_throttled); 0 to disable")
+
+var (
+	throttledGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "throttled",
+		Help: "Whether a Raspberry Pi undervoltage or throttling condition currently holds (1) or not (0), by condition",
+	}, []string{"condition"})
+	throttledSinceBootGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "throttled_since_boot",
+		Help: "Whether a Raspberry Pi undervoltage or throttling condition has held since boot (1) or not (0), by condition",
+	}, []string{"condition"})
+)
+
+// setupThrottled starts checking the undervoltage and throttling flags,
+// unless disabled or unavailable (e.g. when not running on a Raspberry Pi)
+func setupThrottled(ctx context.Context) {
+	if *throttledInterval == 0 {
+		return
+	}
+	if _, err := throttled.Read(ctx); errors.Is(err, throttled.ErrUnsupported) {
+		slog.Info("Not checking for undervoltage or throttling", "err", err)
+		return
+	}
+
+	workers.Supervise(ctx, "throttled", func() {
+		var last throttled.Flags
+		sync.RepeatUntilCancelled(ctx, func() {
+			flags, err := throttled.Read(ctx)
+			if err != nil {
+				slog.Error("Failed to check for undervoltage or throttling", "err", err)
+				return
+			}
+			// Warn once per new condition, rather than every time
+			if newly := flags &^ last; len(newly.Names()) > 0 {
+				slog.Warn("Raspberry Pi is undervolted or throttled; check the power supply", "conditions", strings.Join(flags.Names(), ","))
+			}
+			last = flags
+			recordThrottled(flags)
+		}, *throttledInterval)
+	})
+}
+
+// recordThrottled updates the state and metrics with flags
+func recordThrottled(flags throttled.Flags) {
+	state.Update(func(s *state.State) { s.Throttled = flags.Names() })
+	for _, c := range throttled.Conditions {
+		now, sinceBoot := 0.0, 0.0
+		if flags.Now(c.Flag) {
+			now = 1
+		}
+		if flags.SinceBoot(c.Flag) {
+			sinceBoot = 1
+		}
+		throttledGauge.WithLabelValues(c.Name).Set(now)
+		throttledSinceBootGauge.WithLabelValues(c.Name).Set(sinceBoot)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+
+	"github.com/lutzky/pitemp/internal/app/eco"
+	"github.com/lutzky/pitemp/internal/app/geo"
+	"github.com/lutzky/pitemp/internal/app/ipaddr"
+	"github.com/lutzky/pitemp/internal/app/rtc"
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/internal/thermostat"
+)
+
+// validateServe checks the flags used by the serve command and, with
+// --strict_init, probes the sensor.
+func validateServe() {
+	var c startup.Checks
+
+	// The Pi's GPIO header exposes BCM pins 2 through 27
+	c.Range("dht11_pin", *dhtPin, 2, 27)
+	c.Range("dht11_retries", *dhtRetries, 0, 100)
+	c.Positive("trend_window", *trendWindow)
+	c.Range("port", *flagPort, 1, 65535)
+	c.Range("history_size", *historySize, 1, 1<<20)
+	rtc.CheckFlags(&c)
+	eco.CheckFlags(&c)
+	geo.CheckFlags(&c)
+	ipaddr.CheckFlags(&c)
+	if *ipInterval < 0 {
+		c.Errorf("--ip_interval must not be negative, got %v", *ipInterval)
+	}
+	c.Positive("aggregate_interval", *aggregateInterval)
+	c.Positive("plugin_timeout", *pluginTimeout)
+	c.Positive("remote_write_interval", *remoteWriteInterval)
+	c.Positive("otlp_interval", *otlpInterval)
+	c.Positive("ble_interval", *bleInterval)
+	c.Positive("alert_interval", *alertInterval)
+	if *systemMetrics {
+		c.Positive("system_interval", *systemInterval)
+	}
+	if *throttledInterval < 0 {
+		c.Errorf("--throttled_interval must not be negative, got %v", *throttledInterval)
+	}
+	c.Range("modbus_unit_id", *modbusUnitID, 0, 255)
+	c.Range("mqtt_qos", *mqttQoS, 0, 2)
+	if *thermostatMode != "" {
+		_, err := thermostat.ParseMode(*thermostatMode)
+		c.Check("--thermostat_mode", err)
+		c.Range("thermostat_gpio", *thermostatGPIO, 2, 27)
+		c.Positive("thermostat_min_on", *thermostatMinOn)
+		c.Positive("thermostat_min_off", *thermostatMinOff)
+		if *thermostatHysteresis < 0 {
+			c.Errorf("--thermostat_hysteresis must not be negative, got %v", *thermostatHysteresis)
+		}
+	}
+
+	if *ina219Enabled {
+		c.Range("ina219_bus", *ina219Bus, 0, 255)
+		c.Range("ina219_addr", int(*ina219Addr), 0x40, 0x4f)
+		c.Positive("power_interval", *powerInterval)
+		if *ina219Shunt <= 0 {
+			c.Errorf("--ina219_shunt must be positive, got %v", *ina219Shunt)
+		}
+		if *batteryFullVoltage != 0 && *batteryFullVoltage <= *batteryEmptyVoltage {
+			c.Errorf("--battery_full_voltage must be above --battery_empty_voltage (%v), got %v", *batteryEmptyVoltage, *batteryFullVoltage)
+		}
+	}
+
+	c.Address("coap_addr", *coapAddr)
+	c.Address("modbus_addr", *modbusAddr)
+	c.Address("metrics_addr", *metricsAddr)
+	c.Address("smtp_addr", *smtpAddr)
+	c.Address("zabbix_server", *zabbixServer)
+
+	c.URL("remote_write_url", *remoteWriteURL)
+	c.URL("otlp_endpoint", *otlpEndpoint)
+	c.URL("ntfy_url", *ntfyURL)
+	c.URL("mqtt_broker", *mqttBroker)
+
+	if *outdoorSource != "" && !*dhtEnabled {
+		c.Errorf("--outdoor_source requires --dht11, to compare with")
+	}
+
+	_, err := parseAggregate(*aggregate)
+	c.Check("--aggregate", err)
+	_, err = parseBLESensors(*bleSensors)
+	c.Check("--ble_sensors", err)
+	_, err = parseModbusRegisters(*modbusRegisters)
+	c.Check("--modbus_registers", err)
+	_, err = parseMetricsLabels(*metricsLabels)
+	c.Check("--metrics_labels", err)
+
+	if startup.Strict() && *dhtEnabled {
+		_, _, err := readDHT(context.Background())
+		c.Check("DHT11", err)
+	}
+
+	c.Done()
+}
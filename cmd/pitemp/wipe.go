@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/lutzky/pitemp/internal/wipe"
+)
+
+// defaultDataDir is where pitemp keeps persistent data such as history,
+// credentials and provisioning information.
+const defaultDataDir = "/var/lib/pitemp"
+
+// wipeMain implements "pitemp wipe", securely deleting all data pitemp has
+// accumulated before a node is decommissioned or given away.
+func wipeMain(args []string) int {
+	fs := flag.NewFlagSet("wipe", flag.ExitOnError)
+	dataDir := fs.String("data_dir", defaultDataDir, "Data directory to wipe")
+	yes := fs.Bool("yes", false, "Don't ask for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s wipe [flags] [EXTRA_FILES...]\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Securely deletes the data directory and any extra files given (e.g. credential files).\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if !*yes {
+		fmt.Printf("This will irrecoverably delete %s", *dataDir)
+		for _, f := range fs.Args() {
+			fmt.Printf(", %s", f)
+		}
+		fmt.Print(". Continue? [y/N] ")
+		var answer string
+		fmt.Scanln(&answer)
+		if answer != "y" && answer != "Y" {
+			fmt.Println("Aborted")
+			return 1
+		}
+	}
+
+	failed := false
+	for _, f := range fs.Args() {
+		slog.Info("Wiping", "path", f)
+		if err := wipe.File(f); err != nil {
+			slog.Error("Failed to wipe", "err", err)
+			failed = true
+		}
+	}
+
+	err := wipe.Dir(*dataDir, func(path string) {
+		slog.Info("Wiping", "path", path)
+	})
+	if err != nil {
+		slog.Error("Failed to wipe", "err", err)
+		failed = true
+	}
+
+	if failed {
+		return 1
+	}
+	slog.Info("Wipe complete")
+	return 0
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+var (
+	nodesList = flag.String("nodes", "", "Comma-separated list of pitemp base URLs (e.g. http://bedroom:8080)")
+	nodesFile = flag.String("nodes_file", "", "File containing one pitemp base URL per line")
+	timeout   = flag.Duration("timeout", 10*time.Second, "Timeout for each request to a node")
+	tokenFile = flag.String("token_file", "", "File containing a bearer token for nodes requiring authentication")
+)
+
+// token is sent as a bearer token, if set
+var token string
+
+const usage = `Usage: pitemp_fleetctl [flags] COMMAND [ARGS...]
+
+Commands:
+  status             Show the current readings of each node
+  version            Show the version of each node
+  reload             Ask each node to restart its sensors
+  set KEY=VALUE...   Apply configuration changes to each node
+
+Flags:
+`
+
+// action runs against a single node, returning a one-line result.
+type action func(ctx context.Context, node string) (string, error)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	nodes, err := readNodes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(nodes) == 0 {
+		log.Fatal("No nodes provided; use --nodes or --nodes_file")
+	}
+
+	if *tokenFile != "" {
+		b, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read token: %v", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	var act action
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "status":
+		act = status
+	case "version":
+		act = get("/api/version")
+	case "reload":
+		act = post("/api/reload", nil)
+	case "set":
+		body, err := configBody(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		act = post("/api/config", body)
+	default:
+		log.Printf("Unknown command %q", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !runAll(nodes, act) {
+		os.Exit(1)
+	}
+}
+
+// runAll runs act against all nodes concurrently, printing results in the
+// order the nodes were given. It returns true if all nodes succeeded.
+func runAll(nodes []string, act action) bool {
+	results := make([]string, len(nodes))
+	ok := true
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+			defer cancel()
+			result, err := act(ctx, node)
+			if err != nil {
+				result = "ERROR: " + err.Error()
+				mu.Lock()
+				ok = false
+				mu.Unlock()
+			}
+			results[i] = result
+		}(i, node)
+	}
+	wg.Wait()
+
+	for i, node := range nodes {
+		fmt.Printf("%-30s %s\n", node, results[i])
+	}
+	return ok
+}
+
+func readNodes() ([]string, error) {
+	var nodes []string
+	for _, n := range strings.Split(*nodesList, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			nodes = append(nodes, strings.TrimSuffix(n, "/"))
+		}
+	}
+
+	if *nodesFile == "" {
+		return nodes, nil
+	}
+
+	f, err := os.Open(*nodesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open nodes file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		nodes = append(nodes, strings.TrimSuffix(line, "/"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes file: %w", err)
+	}
+	return nodes, nil
+}
+
+// configBody converts KEY=VALUE arguments into a JSON object
+func configBody(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("set requires at least one KEY=VALUE argument")
+	}
+	config := map[string]string{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid argument %q, expected KEY=VALUE", arg)
+		}
+		config[parts[0]] = parts[1]
+	}
+	return json.Marshal(config)
+}
+
+func do(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	// /api/version, /api/reload and /api/config are newer than /api; older
+	// nodes serve their HTML page for unknown paths, or 404
+	if resp.StatusCode == http.StatusNotFound || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("%s %s: not supported by this node; upgrade its pitemp", method, url)
+	}
+	if resp.StatusCode/100 != 2 {
+		if msg := strings.TrimSpace(string(respBody)); msg != "" {
+			return nil, fmt.Errorf("%s %s: %s", method, url, msg)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return respBody, nil
+}
+
+func get(path string) action {
+	return func(ctx context.Context, node string) (string, error) {
+		body, err := do(ctx, http.MethodGet, node+path, nil)
+		return strings.TrimSpace(string(body)), err
+	}
+}
+
+func post(path string, reqBody []byte) action {
+	return func(ctx context.Context, node string) (string, error) {
+		body, err := do(ctx, http.MethodPost, node+path, reqBody)
+		if err != nil {
+			return "", err
+		}
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return msg, nil
+		}
+		return "OK", nil
+	}
+}
+
+func status(ctx context.Context, node string) (string, error) {
+	body, err := do(ctx, http.MethodGet, node+"/api", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var s wire.State
+	if err := json.Unmarshal(body, &s); err != nil {
+		return "", fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	if s.LastSensorUpdate.IsZero() {
+		return "waiting for sensor data", nil
+	}
+	return fmt.Sprintf("%.0f°C, %.0f%% humidity, updated %s ago",
+		s.Temperature, s.Humidity,
+		time.Since(s.LastSensorUpdate).Round(time.Second)), nil
+}
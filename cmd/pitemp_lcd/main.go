@@ -1,52 +1,31 @@
+// pitemp_lcd shows the state from a pitemp server on an HD44780 LCD. It is
+// equivalent to "pitemp display --display=lcd".
 package main
 
 import (
-	"context"
 	"flag"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-	"time"
 
-	"github.com/lutzky/pitemp/internal/app/client"
-	"github.com/lutzky/pitemp/internal/lcd"
-	"github.com/lutzky/pitemp/internal/pioled"
+	"github.com/lutzky/pitemp/internal/app/config"
+	"github.com/lutzky/pitemp/internal/app/display"
+	"github.com/lutzky/pitemp/internal/app/logging"
+	"github.com/lutzky/pitemp/internal/app/staleness"
+	"github.com/lutzky/pitemp/internal/app/tuning"
 )
 
-var (
-	server = flag.String("server", "", "URL for pitemp API server (including /api)")
-	port   = flag.Int("port", 8081, "HTTP Serving port")
-
-	fetchInterval  = flag.Duration("fetch_interval", 1*time.Minute, "How often to poll the API server")
-	updateInterval = flag.Duration("update_interval", 2*time.Second, "How often to update the screen")
-
-	ipIface = flag.String("ip_iface", "wlan0", "Network interface for IP address")
-)
+var port = flag.Int("port", 8081, "HTTP Serving port (see also --listen)")
 
 func main() {
 	flag.Parse()
-
-	if *server == "" {
-		log.Print("--server not provided")
-		os.Exit(1)
+	if err := config.Load(); err != nil {
+		logging.Fatal("Failed to load config", "err", err)
 	}
-
-	lcd.IPIface = *ipIface
-	if err := lcd.Initialize(); err != nil {
-		log.Printf("Failed to initialize pioled: %v", err)
-		os.Exit(1)
+	if err := logging.Setup(); err != nil {
+		logging.Fatal("Failed to set up logging", "err", err)
 	}
-	defer lcd.Cleanup()
-
-	http.HandleFunc("/", pioled.HTTPResponse)
-	srv := http.Server{Addr: fmt.Sprintf(":%d", *port)}
-	go srv.ListenAndServe()
-	defer srv.Shutdown(context.Background())
+	tuning.Apply(tuning.DisplayMemoryLimitMB)
+	staleness.Apply()
 
-	log.Print("Starting client")
-	client.Run(
-		context.Background(),
-		*server, lcd.Display,
-		*fetchInterval, *updateInterval)
+	if err := display.Run(display.LCD, *port); err != nil {
+		logging.Fatal("Failed to run display", "err", err)
+	}
 }
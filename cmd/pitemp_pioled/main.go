@@ -1,55 +1,31 @@
+// pitemp_pioled shows the state from a pitemp server on an Adafruit PiOLED.
+// It is equivalent to "pitemp display --display=pioled".
 package main
 
 import (
-	"context"
 	"flag"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-	"time"
 
-	"github.com/lutzky/pitemp/internal/app/client"
-	"github.com/lutzky/pitemp/internal/pioled"
+	"github.com/lutzky/pitemp/internal/app/config"
+	"github.com/lutzky/pitemp/internal/app/display"
+	"github.com/lutzky/pitemp/internal/app/logging"
+	"github.com/lutzky/pitemp/internal/app/staleness"
+	"github.com/lutzky/pitemp/internal/app/tuning"
 )
 
-var (
-	server         = flag.String("server", "", "URL for pitemp API server (including /api)")
-	port           = flag.Int("port", 8081, "HTTP Serving port")
-	fetchInterval  = flag.Duration("fetch_interval", 1*time.Minute, "How often to poll the API server")
-	updateInterval = flag.Duration("update_interval", 500*time.Millisecond, "How often to update the screen")
-
-	simulatorMode = flag.Bool("simulator", false, "Simulator mode - do not contact PiOLED hardware")
-)
+var port = flag.Int("port", 8081, "HTTP Serving port (see also --listen)")
 
 func main() {
 	flag.Parse()
-
-	if *server == "" {
-		log.Print("--server not provided")
-		os.Exit(1)
+	if err := config.Load(); err != nil {
+		logging.Fatal("Failed to load config", "err", err)
 	}
-
-	displayFunc := func() {}
-
-	if !*simulatorMode {
-		if err := pioled.Initialize(); err != nil {
-			log.Printf("Failed to initialize pioled: %v", err)
-			os.Exit(1)
-		}
-		defer pioled.Cleanup()
-
-		displayFunc = pioled.Display
+	if err := logging.Setup(); err != nil {
+		logging.Fatal("Failed to set up logging", "err", err)
 	}
+	tuning.Apply(tuning.DisplayMemoryLimitMB)
+	staleness.Apply()
 
-	http.HandleFunc("/", pioled.HTTPResponse)
-	srv := http.Server{Addr: fmt.Sprintf(":%d", *port)}
-	go srv.ListenAndServe()
-	defer srv.Shutdown(context.Background())
-
-	log.Print("Starting client")
-	client.Run(
-		context.Background(),
-		*server, displayFunc,
-		*fetchInterval, *updateInterval)
+	if err := display.Run(display.PiOLED, *port); err != nil {
+		logging.Fatal("Failed to run display", "err", err)
+	}
 }
@@ -0,0 +1,162 @@
+package pitemp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp"
+	"github.com/lutzky/pitemp/pkg/client"
+	"github.com/lutzky/pitemp/pkg/sensor/sensortest"
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+// TestEndToEnd wires a fake sensor, the state, the HTTP server and the
+// client fetch loop together. As the server and client share the state in
+// a single process, the client shows the server's state as a room, which
+// it keeps separately; the state isn't changed after the client starts, so
+// that the client can't overwrite changes with what it fetched earlier.
+func TestEndToEnd(t *testing.T) {
+	defer state.SetStaleAfter(state.StaleAfter())
+	state.SetStaleAfter(500 * time.Millisecond)
+
+	ctx := context.Background()
+	var sensor sensortest.Sensor
+	sensor.Set(map[string]state.Reading{
+		"temperature": {Value: 21.5, Unit: "celsius", Sensor: "fake"},
+		"humidity":    {Value: 40, Unit: "percent", Sensor: "fake"},
+	})
+	readings, err := sensor.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := state.Update(func(s *state.State) {
+		for name, r := range readings {
+			s.SetReading(name, r)
+		}
+		s.SetRelay("heater", true)
+	})
+
+	ts := httptest.NewServer(pitemp.NewServer().Handler())
+	defer ts.Close()
+	api := ts.URL + "/api"
+
+	if w := getState(t, ts.Client(), api); w.Stale || w.Temperature != 21.5 {
+		t.Errorf("Served %v°C, stale = %v; want 21.5°C, not stale", w.Temperature, w.Stale)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pages := make(chan client.Page, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx, client.Options{
+			Servers: []string{api},
+			// The client caches responses by URL, so the room needs one of
+			// its own
+			Rooms:          []client.Room{{Name: "e2e", URL: api + "?room"}},
+			FetchInterval:  10 * time.Millisecond,
+			UpdateInterval: 10 * time.Millisecond,
+			HTTPClient:     ts.Client(),
+			// Pushed updates would loop back through the shared state (so
+			// Push is left off), and retries would slow down detecting the
+			// server going away
+			FetchRetries: -1,
+			Update: func() error {
+				select {
+				case <-pages:
+				default:
+				}
+				pages <- client.Main()
+				return nil
+			},
+		})
+	}()
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		waitFor(t, "the room to be fetched", func() bool {
+			return state.Sources()["e2e"].LastSensorUpdate.Equal(want.LastSensorUpdate)
+		})
+		got := state.Sources()["e2e"]
+		if got.Temperature != want.Temperature || got.Humidity != want.Humidity {
+			t.Errorf("Got %v°C, %v%%, want %v°C, %v%%", got.Temperature, got.Humidity, want.Temperature, want.Humidity)
+		}
+		for name, w := range want.Readings {
+			g := got.Readings[name]
+			if g.Value != w.Value || g.Unit != w.Unit || g.Sensor != w.Sensor || !g.MeasuredAt.Equal(w.MeasuredAt) {
+				t.Errorf("Got %s reading %+v, want %+v", name, g, w)
+			}
+		}
+		if !got.Relays["heater"] {
+			t.Errorf("Got relays %v, want the heater on", got.Relays)
+		}
+
+		page := <-pages
+		if page.State.Temperature != 21.5 || client.Unreachable() {
+			t.Errorf("Displayed %v°C (unreachable = %v), want 21.5°C", page.State.Temperature, client.Unreachable())
+		}
+	})
+
+	t.Run("Staleness", func(t *testing.T) {
+		waitFor(t, "the server to report stale state", func() bool {
+			return getState(t, ts.Client(), api).Stale
+		})
+		if page := <-pages; !page.State.IsStale(state.StaleAfter()) {
+			t.Error("Displayed state isn't stale")
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		ts.Close()
+		waitFor(t, "the server to be unreachable", client.Unreachable)
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run didn't return after cancelling")
+		}
+	})
+}
+
+// apiResponse is the response served by pitemp.EndpointState
+type apiResponse struct {
+	wire.State
+	Stale bool `json:"stale"`
+}
+
+// getState fetches the state from api
+func getState(t *testing.T, c *http.Client, api string) apiResponse {
+	t.Helper()
+	resp, err := c.Get(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var w apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&w); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// waitFor waits for cond to hold, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
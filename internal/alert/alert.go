@@ -0,0 +1,293 @@
+// Package alert evaluates threshold rules against the current state, with
+// hysteresis and cooldowns, and sends notifications when alerts fire or
+// resolve.
+package alert
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// notifyTimeout bounds how long a single notifier may take
+const notifyTimeout = 30 * time.Second
+
+// maxPending bounds the events waiting to be notified by Run, so that
+// unreachable notifiers can't pile them up
+const maxPending = 64
+
+// Alert is the current status of a rule
+type Alert struct {
+	Rule   string `json:"rule"`
+	Active bool   `json:"active"`
+	// Since is when the alert became active (or pending, if not yet active)
+	Since time.Time `json:"since"`
+	// Value is what the rule was last evaluated with, except for staleness
+	// rules: that changes continuously, and follows from the update time
+	Value float64 `json:"value,omitempty"`
+}
+
+// Event is sent to notifiers when an alert fires or resolves
+type Event struct {
+	Rule   Rule
+	Firing bool
+	Value  float64
+	Time   time.Time
+
+	// Held is set for events held back during quiet hours, and notified
+	// once they ended
+	Held bool
+}
+
+// Notifier sends notifications about alert events
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, e Event) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+type ruleState struct {
+	Rule
+
+	active       bool
+	pendingSince time.Time
+	activeSince  time.Time
+	lastNotified time.Time
+	notified     bool // whether the current firing was notified
+	value        float64
+}
+
+// Engine evaluates rules and dispatches notifications
+type Engine struct {
+	mu        sync.Mutex
+	rules     []*ruleState
+	notifiers []Notifier
+	started   time.Time
+
+	// quiet, if set, reports whether notifications are held back at a
+	// time; held are the events held back so far
+	quiet func(time.Time) bool
+	held  []Event
+
+	// pending are the events waiting to be notified by Run
+	pending chan Event
+}
+
+// NewEngine creates an engine for rules
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{started: time.Now(), pending: make(chan Event, maxPending)}
+	for _, r := range rules {
+		e.rules = append(e.rules, &ruleState{Rule: r})
+	}
+	return e
+}
+
+// AddNotifier adds a notification backend
+func (e *Engine) AddNotifier(n Notifier) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.notifiers = append(e.notifiers, n)
+}
+
+// SetQuiet sets quiet, which reports whether notifications should be held
+// back at a time, e.g. at night. Alerts are still evaluated, logged and
+// reported by Alerts meanwhile; their events are notified (as Held) on the
+// first evaluation after quiet hours end.
+func (e *Engine) SetQuiet(quiet func(time.Time) bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.quiet = quiet
+}
+
+// Evaluate checks all rules against s at time now, queueing notifications
+// for alerts that fire or resolve, to be sent by Run.
+func (e *Engine) Evaluate(s state.State, now time.Time) {
+	e.mu.Lock()
+	var events []Event
+	for _, r := range e.rules {
+		value, ok := e.value(r.Metric, s, now)
+		if !ok {
+			continue
+		}
+		r.value = value
+		if ev, ok := r.evaluate(value, now); ok {
+			events = append(events, ev)
+		}
+	}
+	notify := events
+	if e.quiet != nil && e.quiet(now) {
+		for _, ev := range events {
+			ev.Held = true
+			e.held = append(e.held, ev)
+		}
+		notify = nil
+	} else if len(e.held) > 0 {
+		notify, e.held = append(e.held, events...), nil
+	}
+	e.mu.Unlock()
+
+	for _, ev := range events {
+		if ev.Firing {
+			slog.Warn("Alert firing", "alert", ev.Rule.Name, "metric", ev.Rule.Metric, "value", ev.Value)
+		} else {
+			slog.Info("Alert resolved", "alert", ev.Rule.Name, "metric", ev.Rule.Metric, "value", ev.Value)
+		}
+	}
+	if len(events) > 0 && len(notify) == 0 {
+		slog.Info("Holding back alert notifications during quiet hours", "events", len(events))
+	}
+	for _, ev := range notify {
+		select {
+		case e.pending <- ev:
+		default:
+			slog.Error("Dropped alert notification, as too many are pending", "alert", ev.Rule.Name)
+		}
+	}
+}
+
+// Run sends the notifications queued by Evaluate, until ctx is cancelled.
+// Notifiers may be slow (e.g. an SMTP server timing out), so they're kept
+// apart from evaluating rules.
+func (e *Engine) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-e.pending:
+			e.mu.Lock()
+			notifiers := append([]Notifier(nil), e.notifiers...)
+			e.mu.Unlock()
+			for _, n := range notifiers {
+				ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+				if err := n.Notify(ctx, ev); err != nil {
+					slog.Error("Failed to send alert notification", "alert", ev.Rule.Name, "err", err)
+				}
+				cancel()
+			}
+		}
+	}
+}
+
+// value returns the value of metric in s, or false if it isn't available
+func (e *Engine) value(metric string, s state.State, now time.Time) (float64, bool) {
+	if metric == Staleness {
+		last := s.LastSensorUpdate
+		if last.IsZero() {
+			// No data at all is as stale as it gets
+			last = e.started
+		}
+		return now.Sub(last).Seconds(), true
+	}
+
+	switch metric {
+	case Voltage, Current, Power, Battery:
+		// Power readings don't count as sensor updates, so don't check
+		// LastSensorUpdate
+		r, ok := s.Readings[metric]
+		return float64(r.Value), ok
+	}
+
+	if s.LastSensorUpdate.IsZero() {
+		return 0, false
+	}
+	switch metric {
+	case Temperature:
+		return float64(s.Temperature), true
+	case Humidity:
+		return float64(s.Humidity), true
+	case TemperatureTrend:
+		t, ok := s.Trends[Temperature]
+		return float64(t), ok
+	case HumidityTrend:
+		t, ok := s.Trends[Humidity]
+		return float64(t), ok
+	}
+	return 0, false
+}
+
+// evaluate updates the rule state, returning an event to be notified, if any
+func (r *ruleState) evaluate(value float64, now time.Time) (Event, bool) {
+	ev := Event{Rule: r.Rule, Value: value, Time: now}
+
+	if !r.active {
+		if !r.breached(value, 0) {
+			r.pendingSince = time.Time{}
+			return ev, false
+		}
+		if r.pendingSince.IsZero() {
+			r.pendingSince = now
+		}
+		if now.Sub(r.pendingSince) < r.For {
+			return ev, false
+		}
+
+		r.active = true
+		r.activeSince = now
+		r.notified = false
+		if !r.lastNotified.IsZero() && now.Sub(r.lastNotified) < r.Cooldown {
+			return ev, false
+		}
+		r.notified = true
+		r.lastNotified = now
+		ev.Firing = true
+		return ev, true
+	}
+
+	if r.breached(value, r.Hysteresis) {
+		return ev, false
+	}
+	r.active = false
+	r.pendingSince = time.Time{}
+	// Only notify about resolution if the firing was notified
+	return ev, r.notified
+}
+
+// breached returns whether value is past the threshold, moved back by
+// margin (for hysteresis)
+func (r *ruleState) breached(value, margin float64) bool {
+	if r.Above {
+		return value > r.Threshold-margin
+	}
+	return value < r.Threshold+margin
+}
+
+// Alerts returns the status of all rules
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var result []Alert
+	for _, r := range e.rules {
+		a := Alert{Rule: r.Name, Active: r.active}
+		if r.Metric != Staleness {
+			a.Value = r.value
+		}
+		if r.active {
+			a.Since = r.activeSince
+		} else {
+			a.Since = r.pendingSince
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+// Active returns only the active alerts
+func (e *Engine) Active() []Alert {
+	var result []Alert
+	for _, a := range e.Alerts() {
+		if a.Active {
+			result = append(result, a)
+		}
+	}
+	return result
+}
@@ -0,0 +1,160 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics that rules can refer to
+const (
+	Temperature = "temperature"
+	Humidity    = "humidity"
+	// Staleness is the time since the last sensor update, in seconds
+	Staleness = "staleness"
+
+	// TemperatureTrend and HumidityTrend are the rates of change per hour,
+	// e.g. to catch a freezer door left open
+	TemperatureTrend = "temperature_trend"
+	HumidityTrend    = "humidity_trend"
+
+	// Voltage, Current, Power and Battery describe the node's power supply,
+	// if monitored; battery is the estimated charge in percent
+	Voltage = "voltage"
+	Current = "current"
+	Power   = "power"
+	Battery = "battery"
+)
+
+// Rule describes a condition that should raise an alert
+type Rule struct {
+	Name   string
+	Metric string
+
+	// Above is true for rules of the form "metric > threshold", and false for
+	// "metric < threshold"
+	Above     bool
+	Threshold float64
+
+	// Hysteresis is how far back past the threshold the metric has to go
+	// for the alert to clear, avoiding flapping around the threshold.
+	Hysteresis float64
+
+	// For is how long the condition has to hold before the alert fires
+	For time.Duration
+
+	// Cooldown is the minimum time between notifications for this rule
+	Cooldown time.Duration
+}
+
+var ruleRE = regexp.MustCompile(`^\s*([\w-]+)\s*:\s*(\w+)\s*([<>])\s*([^,\s]+)\s*((?:,\s*\w+\s*=\s*[^,\s]+\s*)*)$`)
+
+// ParseRule parses a rule of the form
+//
+//	NAME:METRIC>THRESHOLD[,for=DURATION][,hysteresis=AMOUNT][,cooldown=DURATION]
+//
+// (or with < instead of >), e.g. "hot:temperature>28,for=10m,hysteresis=1".
+// For staleness, the threshold and hysteresis are durations, e.g.
+// "stale:staleness>15m".
+func ParseRule(s string) (Rule, error) {
+	m := ruleRE.FindStringSubmatch(s)
+	if m == nil {
+		return Rule{}, fmt.Errorf("invalid alert rule %q, expected NAME:METRIC>THRESHOLD[,key=value...]", s)
+	}
+
+	r := Rule{
+		Name:   m[1],
+		Metric: m[2],
+		Above:  m[3] == ">",
+	}
+
+	switch r.Metric {
+	case Temperature, Humidity, Staleness, TemperatureTrend, HumidityTrend, Voltage, Current, Power, Battery:
+	default:
+		return Rule{}, fmt.Errorf("invalid alert rule %q: unknown metric %q", s, r.Metric)
+	}
+
+	var err error
+	if r.Threshold, err = r.parseValue(m[4]); err != nil {
+		return Rule{}, fmt.Errorf("invalid alert rule %q: %w", s, err)
+	}
+
+	for _, kv := range strings.Split(m[5], ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "for":
+			r.For, err = time.ParseDuration(value)
+		case "cooldown":
+			r.Cooldown, err = time.ParseDuration(value)
+		case "hysteresis":
+			r.Hysteresis, err = r.parseValue(value)
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid alert rule %q: %w", s, err)
+		}
+	}
+
+	return r, nil
+}
+
+func (r Rule) parseValue(s string) (float64, error) {
+	if r.Metric == Staleness {
+		d, err := time.ParseDuration(s)
+		return d.Seconds(), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// String formats the rule the same way ParseRule accepts it
+func (r Rule) String() string {
+	op := "<"
+	if r.Above {
+		op = ">"
+	}
+	format := func(v float64) string {
+		if r.Metric == Staleness {
+			return time.Duration(v * float64(time.Second)).String()
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	s := fmt.Sprintf("%s:%s%s%s", r.Name, r.Metric, op, format(r.Threshold))
+	if r.For > 0 {
+		s += ",for=" + r.For.String()
+	}
+	if r.Hysteresis > 0 {
+		s += ",hysteresis=" + format(r.Hysteresis)
+	}
+	if r.Cooldown > 0 {
+		s += ",cooldown=" + r.Cooldown.String()
+	}
+	return s
+}
+
+// Rules is a flag.Value accumulating rules from repeated flags
+type Rules []Rule
+
+func (rs *Rules) String() string {
+	var parts []string
+	for _, r := range *rs {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set parses and appends a rule
+func (rs *Rules) Set(s string) error {
+	r, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*rs = append(*rs, r)
+	return nil
+}
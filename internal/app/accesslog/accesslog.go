@@ -0,0 +1,72 @@
+// Package accesslog optionally logs HTTP requests, in logfmt style for
+// easy grepping and parsing.
+package accesslog
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+var enabled = flag.Bool("access_log", false, "Log every HTTP request (method, path, status, latency, remote address)")
+
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports websockets, which are logged as switching protocols
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Handler wraps h, logging each request once it completes, if enabled by
+// --access_log.
+func Handler(h http.Handler) http.Handler {
+	if !*enabled {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &recorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.Info("HTTP request",
+			"method", r.Method, "path", r.URL.RequestURI(), "status", rec.status, "bytes", rec.bytes,
+			"duration", time.Since(start).Round(time.Microsecond), "remote", r.RemoteAddr, "user_agent", r.UserAgent())
+	})
+}
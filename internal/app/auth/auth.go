@@ -0,0 +1,128 @@
+// Package auth authenticates HTTP requests by basic auth or bearer tokens,
+// as configured by flags, for the serve command and display clients.
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	username      = flag.String("auth_username", "", "If set, require HTTP basic auth with this username (see --auth_password_file)")
+	passwordFile  = flag.String("auth_password_file", "", "File containing the HTTP basic auth password")
+	tokensFile    = flag.String("auth_tokens_file", "", "If set, accept bearer tokens listed in this file, one per line")
+	exemptMetrics = flag.Bool("auth_exempt_metrics", false, "Don't require authentication for /metrics, e.g. for a Prometheus server without credentials")
+)
+
+// exemptPaths never require authentication
+var exemptPaths = map[string]bool{}
+
+// Exempt exempts path from authentication, e.g. for an endpoint with its own
+// authentication; call it before serving.
+func Exempt(path string) {
+	exemptPaths[path] = true
+}
+
+// Authenticator authenticates requests
+type Authenticator struct {
+	username, password string
+	tokens             []string
+}
+
+// New returns an Authenticator configured by flags, or nil if
+// authentication is disabled.
+func New() (*Authenticator, error) {
+	if *username == "" && *tokensFile == "" {
+		return nil, nil
+	}
+
+	a := &Authenticator{username: *username}
+	if a.username != "" {
+		if *passwordFile == "" {
+			return nil, fmt.Errorf("--auth_password_file is required with --auth_username")
+		}
+		password, err := os.ReadFile(*passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password file: %w", err)
+		}
+		a.password = strings.TrimSpace(string(password))
+	}
+
+	if *tokensFile != "" {
+		f, err := os.Open(*tokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open tokens file: %w", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			a.tokens = append(a.tokens, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read tokens file: %w", err)
+		}
+	}
+	return a, nil
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (a *Authenticator) authorized(r *http.Request) bool {
+	if a.username != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			return secureEqual(user, a.username) && secureEqual(pass, a.password)
+		}
+	}
+
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+		for _, t := range a.tokens {
+			if secureEqual(token, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Handler wraps h, requiring authentication for all paths except exempt
+// ones. A nil Authenticator allows all requests.
+func (a *Authenticator) Handler(h http.Handler) http.Handler {
+	if a == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exempt := exemptPaths[r.URL.Path] || (*exemptMetrics && r.URL.Path == "/metrics")
+		if !exempt && !a.authorized(r) {
+			if a.username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="pitemp"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Required returns h if authentication is configured, and otherwise a
+// handler refusing all requests; for endpoints which mustn't be open to
+// anyone on the network, such as changing settings.
+func Required(a *Authenticator, h http.HandlerFunc) http.HandlerFunc {
+	if a != nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Forbidden: requires authentication (see --auth_username and --auth_tokens_file)", http.StatusForbidden)
+	}
+}
@@ -0,0 +1,323 @@
+// Package config loads settings from a YAML configuration file, as an
+// alternative to passing many flags. Every flag can be set in the file, with
+// flags given on the command line taking precedence. Nested mappings are
+// flattened into flag names by joining keys with underscores, so these are
+// equivalent:
+//
+//	dht11_pin: 4
+//
+//	dht11:
+//	  pin: 4
+//
+// Lists set repeatable flags (such as alert) once per item, and other flags
+// to the items joined by commas:
+//
+//	alert:
+//	  - hot:temperature>28,for=10m
+//	  - stale:staleness>15m
+//	aggregate: [bedroom=http://bedroom:8080, attic=http://attic:8080]
+//
+// Only this subset of YAML is supported: block mappings, lists of scalars,
+// and plain, single-quoted or double-quoted scalars.
+//
+// Save changes settings in the file, keeping its layout and comments.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var path = flag.String("config", "", "YAML configuration file (e.g. /etc/pitemp/config.yaml) setting any of these flags by name; flags given on the command line take precedence")
+
+// setting is a flag value from the configuration file
+type setting struct {
+	name   string
+	values []string
+	list   bool
+	line   int
+}
+
+// Load applies the configuration file given by --config, if any, to flags
+// not set on the command line; call it after flag.Parse.
+func Load() error {
+	if *path == "" {
+		return nil
+	}
+	f, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("failed to open config: %w", err)
+	}
+	defer f.Close()
+
+	settings, err := parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *path, err)
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for _, s := range settings {
+		f := flag.Lookup(s.name)
+		if f == nil || s.name == "config" {
+			return fmt.Errorf("%s:%d: unknown setting %q", *path, s.line, s.name)
+		}
+		if explicit[s.name] {
+			continue
+		}
+		values := s.values
+		// Standard flags are Getters; the repeatable ones are not
+		if _, ok := f.Value.(flag.Getter); ok && s.list {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, v := range values {
+			if err := flag.Set(s.name, v); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for %s: %w", *path, s.line, v, s.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Save sets flags, by name, in the configuration file given by --config,
+// replacing their values where the file sets them and appending them
+// otherwise; other lines, including comments, are kept. Without --config,
+// it does nothing. The file is replaced atomically, so that a crash can't
+// leave it half-written.
+func Save(values map[string]string) error {
+	if *path == "" || len(values) == 0 {
+		return nil
+	}
+	b, err := os.ReadFile(*path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	settings, err := parse(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s: %w", *path, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(b) == 0 {
+		lines = nil
+	}
+	found := map[string]bool{}
+	for _, s := range settings {
+		v, ok := values[s.name]
+		if !ok {
+			continue
+		}
+		if s.list {
+			return fmt.Errorf("%s:%d: can't save %s, which is set by a list", *path, s.line, s.name)
+		}
+		lines[s.line-1] = replaceValue(lines[s.line-1], v)
+		found[s.name] = true
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !found[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, name+": "+quote(values[name]))
+	}
+
+	info, err := os.Stat(*path)
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(*path), ".config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.WriteString(f, strings.Join(lines, "\n")+"\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := os.Rename(f.Name(), *path); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+// replaceValue returns line, a KEY: VALUE line, with value instead,
+// keeping any comment
+func replaceValue(line, value string) string {
+	content := stripComment(line)
+	comment := line[len(content):]
+	if comment != "" {
+		comment = " " + comment
+	}
+	i := strings.Index(content, ": ")
+	return content[:i+2] + quote(value) + comment
+}
+
+// quote returns value as a scalar, quoted only if it would otherwise be
+// read differently
+func quote(value string) string {
+	if value == "" || strings.TrimSpace(value) != value ||
+		strings.ContainsAny(value[:1], `"'[-#`) ||
+		strings.Contains(value, " #") || strings.Contains(value, ": ") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// parse parses the supported subset of YAML into settings
+func parse(r io.Reader) ([]setting, error) {
+	type level struct {
+		indent int
+		prefix string
+	}
+	levels := []level{{0, ""}}
+
+	var settings []setting
+	parent, parentIndent := "", 0 // key with no value, expecting a nested mapping or list
+	list := -1                    // index in settings of the list being read, if any
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(stripComment(scanner.Text()), " \t")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content == "---" {
+			continue
+		}
+		if content[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n)
+		}
+		indent := len(line) - len(content)
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			if list < 0 {
+				if parent == "" || indent < parentIndent {
+					return nil, fmt.Errorf("line %d: unexpected list item", n)
+				}
+				settings = append(settings, setting{name: parent, list: true, line: n})
+				list, parent = len(settings)-1, ""
+			}
+			v, err := scalar(strings.TrimSpace(content[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			settings[list].values = append(settings[list].values, v)
+			continue
+		}
+		list = -1
+
+		if parent != "" {
+			if indent <= parentIndent {
+				return nil, fmt.Errorf("line %d: %s has no value", n-1, parent)
+			}
+			levels = append(levels, level{indent, parent + "_"})
+			parent = ""
+		}
+		for indent < levels[len(levels)-1].indent {
+			levels = levels[:len(levels)-1]
+		}
+		if top := levels[len(levels)-1]; indent != top.indent {
+			return nil, fmt.Errorf("line %d: inconsistent indentation", n)
+		}
+
+		var key, value string
+		if strings.HasSuffix(content, ":") {
+			key = strings.TrimSuffix(content, ":")
+		} else if i := strings.Index(content, ": "); i >= 0 {
+			key, value = content[:i], strings.TrimSpace(content[i+2:])
+		} else {
+			return nil, fmt.Errorf("line %d: expected KEY: VALUE", n)
+		}
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: invalid key %q", n, key)
+		}
+		name := levels[len(levels)-1].prefix + key
+
+		switch {
+		case value == "":
+			parent, parentIndent = name, indent
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			s := setting{name: name, list: true, line: n}
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				v, err := scalar(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				s.values = append(s.values, v)
+			}
+			settings = append(settings, s)
+		default:
+			v, err := scalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			settings = append(settings, setting{name: name, values: []string{v}, line: n})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if parent != "" {
+		return nil, fmt.Errorf("line %d: %s has no value", n, parent)
+	}
+	return settings, nil
+}
+
+// scalar returns the value of a plain, single-quoted or double-quoted scalar
+func scalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// stripComment removes a comment (a # at the start of the line or after
+// whitespace, outside quotes) from line
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
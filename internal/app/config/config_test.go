@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []setting
+	}{
+		{
+			name: "flat",
+			yaml: "port: 8080\nlocation: garage\n",
+			want: []setting{
+				{name: "port", values: []string{"8080"}, line: 1},
+				{name: "location", values: []string{"garage"}, line: 2},
+			},
+		},
+		{
+			name: "nested",
+			yaml: "---\ndht11:\n  pin: 4\n  retries: 3\nremote:\n  write:\n    url: http://prom:9090\n  timeout: 5s\nport: 8080\n",
+			want: []setting{
+				{name: "dht11_pin", values: []string{"4"}, line: 3},
+				{name: "dht11_retries", values: []string{"3"}, line: 4},
+				{name: "remote_write_url", values: []string{"http://prom:9090"}, line: 7},
+				{name: "remote_timeout", values: []string{"5s"}, line: 8},
+				{name: "port", values: []string{"8080"}, line: 9},
+			},
+		},
+		{
+			name: "block_list",
+			yaml: "alert:\n  - hot:temperature>28,for=10m\n  - 'stale:staleness>15m'\nport: 8080\n",
+			want: []setting{
+				{name: "alert", values: []string{"hot:temperature>28,for=10m", "stale:staleness>15m"}, list: true, line: 2},
+				{name: "port", values: []string{"8080"}, line: 4},
+			},
+		},
+		{
+			name: "unindented_block_list",
+			yaml: "alert:\n- hot:temperature>28\n",
+			want: []setting{
+				{name: "alert", values: []string{"hot:temperature>28"}, list: true, line: 2},
+			},
+		},
+		{
+			name: "flow_list",
+			yaml: `aggregate: [bedroom=http://bedroom:8080, "attic=http://attic:8080", ]` + "\nempty: []\n",
+			want: []setting{
+				{name: "aggregate", values: []string{"bedroom=http://bedroom:8080", "attic=http://attic:8080"}, list: true, line: 1},
+				{name: "empty", list: true, line: 2},
+			},
+		},
+		{
+			name: "quoting",
+			yaml: `a: "x: y # z"` + "\n" + `b: 'it''s'` + "\n" + `c: "tab\there"` + "\n" + `d: ""` + "\n",
+			want: []setting{
+				{name: "a", values: []string{"x: y # z"}, line: 1},
+				{name: "b", values: []string{"it's"}, line: 2},
+				{name: "c", values: []string{"tab\there"}, line: 3},
+				{name: "d", values: []string{""}, line: 4},
+			},
+		},
+		{
+			name: "comments",
+			yaml: "# Garage node\nlocation: garage # by the door\n\nurl: http://x/#anchor\ndht11: # sensor\n  # The data pin\n  pin: 4\n",
+			want: []setting{
+				{name: "location", values: []string{"garage"}, line: 2},
+				{name: "url", values: []string{"http://x/#anchor"}, line: 4},
+				{name: "dht11_pin", values: []string{"4"}, line: 7},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parse(strings.NewReader(tc.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parse() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"tab", "dht11:\n\tpin: 4\n", "line 2: tabs"},
+		{"no_value", "dht11:\nport: 8080\n", "line 1: dht11 has no value"},
+		{"no_value_at_end", "dht11:\n", "line 1: dht11 has no value"},
+		{"stray_item", "- a\n", "line 1: unexpected list item"},
+		{"indentation", "dht11:\n    pin: 4\n  retries: 3\n", "line 3: inconsistent indentation"},
+		{"no_colon", "port 8080\n", "line 1: expected KEY: VALUE"},
+		{"key", "dht 11: 4\n", "line 1: invalid key"},
+		{"quote", "a: 'x\n", "line 1: invalid single-quoted string"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parse(strings.NewReader(tc.yaml))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("parse() error = %v, want one containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		values  map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "replace",
+			yaml:   "port: 8080\nlocation: garage\n",
+			values: map[string]string{"location": "attic"},
+			want:   "port: 8080\nlocation: attic\n",
+		},
+		{
+			name:   "nested",
+			yaml:   "dht11:\n  pin: 4\n  retries: 3\n",
+			values: map[string]string{"dht11_retries": "5"},
+			want:   "dht11:\n  pin: 4\n  retries: 5\n",
+		},
+		{
+			name:   "comments",
+			yaml:   "# Garage node\nlocation: garage # by the door\n",
+			values: map[string]string{"location": "attic"},
+			want:   "# Garage node\nlocation: attic # by the door\n",
+		},
+		{
+			name:   "append",
+			yaml:   "port: 8080\n",
+			values: map[string]string{"location": "attic", "message": "# hi", "eco": "true"},
+			want:   "port: 8080\neco: true\nlocation: attic\nmessage: \"# hi\"\n",
+		},
+		{
+			name:   "empty",
+			values: map[string]string{"message": ""},
+			want:   "message: \"\"\n",
+		},
+		{
+			name:    "list",
+			yaml:    "alert:\n  - hot:temperature>28\n",
+			values:  map[string]string{"alert": "cold:temperature<5"},
+			wantErr: "can't save alert, which is set by a list",
+		},
+		{
+			name:    "flow_list",
+			yaml:    "port: 8080\naggregate: [a=http://a]\n",
+			values:  map[string]string{"aggregate": "b=http://b"},
+			wantErr: ":2: can't save aggregate",
+		},
+	}
+	defer func(p string) { *path = p }(*path)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			*path = filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(*path, []byte(tc.yaml), 0o640); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Save(tc.values)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("Save() error = %v, want one containing %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(*path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want
+			if tc.wantErr != "" {
+				want = tc.yaml
+			}
+			if string(b) != want {
+				t.Errorf("Saved %q, want %q", b, want)
+			}
+			if info, err := os.Stat(*path); err != nil || info.Mode().Perm() != 0o640 {
+				t.Errorf("Saved config mode = %v (%v), want 0640", info.Mode().Perm(), err)
+			}
+
+			got, err := parse(strings.NewReader(string(b)))
+			if err != nil {
+				t.Fatalf("Saved config doesn't parse: %v", err)
+			}
+			for _, s := range got {
+				if v, ok := tc.values[s.name]; ok && tc.wantErr == "" && (len(s.values) != 1 || s.values[0] != v) {
+					t.Errorf("Saved config sets %s to %q, want %q", s.name, s.values, v)
+				}
+			}
+		})
+	}
+}
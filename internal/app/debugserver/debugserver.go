@@ -0,0 +1,44 @@
+// Package debugserver optionally serves net/http/pprof handlers, for
+// diagnosing e.g. memory growth of long-running processes in the field.
+package debugserver
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+)
+
+var (
+	enabled = flag.Bool("debug_pprof", false, "Serve pprof handlers under /debug/pprof/")
+	addr    = flag.String("debug_pprof_addr", "localhost:6060", "Address for the pprof server; if empty, pprof is served on the main HTTP port instead")
+)
+
+// Setup mounts pprof handlers on mux, or returns a separate server for
+// them, to be run with listen.Serve and shut down by the caller, as
+// configured by flags. Importing net/http/pprof also registers its handlers
+// on http.DefaultServeMux, so that mux must not be served.
+func Setup(mux *http.ServeMux) *http.Server {
+	if !*enabled {
+		return nil
+	}
+
+	if *addr == "" {
+		register(mux)
+		slog.Info("Serving pprof on /debug/pprof/")
+		return nil
+	}
+
+	debugMux := http.NewServeMux()
+	register(debugMux)
+	slog.Info("Serving pprof", "url", "http://"+*addr+"/debug/pprof/")
+	return &http.Server{Addr: *addr, Handler: debugMux}
+}
+
+func register(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
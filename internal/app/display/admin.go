@@ -0,0 +1,103 @@
+package display
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/lutzky/pitemp/pkg/client"
+)
+
+// adminHandlers returns the handlers of the admin API, by path, controlling
+// the display remotely. Each takes POST requests; blank takes a blank=true
+// or blank=false query parameter, and backlight an on=true or on=false one,
+// toggling without it. reinit closes and reopens the display.
+func (s *screen) adminHandlers() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/api/display/blank": post(func(w http.ResponseWriter, r *http.Request) {
+			if !s.canBlank {
+				http.Error(w, "The display can't be blanked", http.StatusNotImplemented)
+				return
+			}
+			blank, err := toggle(r, "blank", atomic.LoadInt32(&s.blank) == 1)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.setBlank(blank)
+			client.Refresh()
+			fmt.Fprintf(w, "Display blank: %v\n", blank)
+		}),
+		"/api/display/backlight": post(func(w http.ResponseWriter, r *http.Request) {
+			if !s.hasBacklight {
+				http.Error(w, "The display has no backlight", http.StatusNotImplemented)
+				return
+			}
+			on, err := toggle(r, "on", atomic.LoadInt32(&s.backlightOff) == 0)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.setBacklight(on)
+			client.Refresh()
+			fmt.Fprintf(w, "Backlight on: %v\n", on)
+		}),
+		"/api/display/page": post(func(w http.ResponseWriter, r *http.Request) {
+			client.NextPage()
+			client.Refresh()
+			fmt.Fprintf(w, "Showing %s\n", pageName(client.Current()))
+		}),
+		"/api/display/refresh": post(func(w http.ResponseWriter, r *http.Request) {
+			atomic.StoreInt32(&s.redraw, 1)
+			client.Refresh()
+			fmt.Fprintln(w, "Refreshing")
+		}),
+		"/api/display/reinit": post(func(w http.ResponseWriter, r *http.Request) {
+			if s.open == nil {
+				http.Error(w, "No display to reinitialize in simulator mode", http.StatusNotImplemented)
+				return
+			}
+			atomic.StoreInt32(&s.reinit, 1)
+			client.Refresh()
+			fmt.Fprintln(w, "Reinitializing the display")
+		}),
+	}
+}
+
+// post returns h, refusing requests other than POST
+func post(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// toggle returns the value of r's boolean query parameter param, or the
+// opposite of current without it
+func toggle(r *http.Request, param string, current bool) (bool, error) {
+	v := r.URL.Query().Get(param)
+	if v == "" {
+		return !current, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s=%q, expected true or false", param, v)
+	}
+	return b, nil
+}
+
+// pageName describes page for admin API responses
+func pageName(page client.Page) string {
+	switch {
+	case page.Main:
+		return "the main page"
+	case page.System:
+		return "the system page"
+	}
+	return fmt.Sprintf("room %s", page.Label)
+}
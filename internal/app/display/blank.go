@@ -0,0 +1,164 @@
+package display
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/cron"
+	displays "github.com/lutzky/pitemp/pkg/display"
+)
+
+// screen switches the display off and on: between --screen_off and
+// --screen_on, and as requested through the admin API, which can also
+// reinitialize it. Requests are only applied by updates, so that the display
+// is only ever accessed by one goroutine.
+type screen struct {
+	off       cron.Window
+	scheduled bool
+
+	// blank is 1 while the display should be blank, backlightOff is 1
+	// while its backlight should be off, and redraw is 1 if the next update
+	// should redraw everything, and reinit is 1 if it should close and reopen
+	// the display first
+	blank, backlightOff, redraw, reinit int32
+
+	// open opens the display, for reinitializing it; it's nil in simulator
+	// mode. d is the open display, nil if reopening it failed.
+	open func() (displays.Display, error)
+	d    displays.Display
+
+	// canBlank and hasBacklight report what the display supports; they're
+	// set by wrap, before serving the admin API
+	canBlank, hasBacklight bool
+}
+
+// schedule sets the schedule for blanking the display
+func (s *screen) schedule(off, on string) error {
+	if off == "" || on == "" {
+		return errors.New("--screen_off and --screen_on must be given together")
+	}
+	var err error
+	if s.off.Start, err = cron.Parse(off); err != nil {
+		return fmt.Errorf("invalid --screen_off: %w", err)
+	}
+	if s.off.End, err = cron.Parse(on); err != nil {
+		return fmt.Errorf("invalid --screen_on: %w", err)
+	}
+	s.scheduled = true
+	if s.off.Contains(time.Now()) {
+		s.blank = 1
+	}
+	return nil
+}
+
+// run switches the display off and on according to the schedule, if any,
+// until ctx is cancelled
+func (s *screen) run(ctx context.Context) {
+	if s.scheduled {
+		s.off.Run(ctx, s.setBlank)
+	}
+}
+
+// setBlank requests blanking or restoring the display
+func (s *screen) setBlank(blank bool) {
+	var v int32
+	if blank {
+		v = 1
+	}
+	atomic.StoreInt32(&s.blank, v)
+}
+
+// setBacklight requests turning the display's backlight on or off
+func (s *screen) setBacklight(on bool) {
+	var v int32
+	if !on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.backlightOff, v)
+}
+
+// wrap returns d's update, applying requests to d first; it is skipped
+// while the display is blank. d must be opened by s.open, and is closed by
+// s.close.
+func (s *screen) wrap(d displays.Display) func() error {
+	s.d = d
+	_, canBlank := d.(displays.Blanker)
+	_, hasBacklight := d.(displays.Backlighter)
+	s.canBlank, s.hasBacklight = canBlank, hasBacklight
+
+	var blanked, dark bool
+	return func() error {
+		if atomic.SwapInt32(&s.reinit, 0) == 1 || s.d == nil {
+			if err := s.reopen(); err != nil {
+				// An update error would stop the client, so the display is
+				// left closed, and reopened on the next update instead
+				slog.Error("Failed to reinitialize display; retrying", "err", err)
+				return nil
+			}
+			// The reopened display is restored, with its backlight on
+			blanked, dark = false, false
+		}
+		d := s.d
+		if canBlank {
+			b := d.(displays.Blanker)
+			blank := atomic.LoadInt32(&s.blank) == 1
+			if blank != blanked {
+				slog.Info("Switching display", "blank", blank)
+				if err := b.SetBlank(blank); err != nil {
+					return fmt.Errorf("failed to switch display: %w", err)
+				}
+				// Restoring the display turns its backlight back on
+				blanked, dark = blank, false
+			}
+			if blanked {
+				return nil
+			}
+		}
+		if hasBacklight {
+			l := d.(displays.Backlighter)
+			off := atomic.LoadInt32(&s.backlightOff) == 1
+			if off != dark {
+				slog.Info("Switching backlight", "on", !off)
+				if err := l.SetBacklight(!off); err != nil {
+					return fmt.Errorf("failed to switch backlight: %w", err)
+				}
+				dark = off
+			}
+		}
+		if r, ok := d.(displays.Redrawer); ok && atomic.SwapInt32(&s.redraw, 0) == 1 {
+			r.Invalidate()
+		}
+		return d.Update()
+	}
+}
+
+// reopen closes the display and opens it again, e.g. for an LCD whose I²C
+// backpack stopped responding; if opening it fails, it's retried on the
+// next update
+func (s *screen) reopen() error {
+	if s.d != nil {
+		slog.Info("Reinitializing display")
+		s.close()
+	}
+	d, err := s.open()
+	if err != nil {
+		return fmt.Errorf("failed to reinitialize display: %w", err)
+	}
+	s.d = d
+	return nil
+}
+
+// close closes the display, if open
+func (s *screen) close() {
+	if s.d == nil {
+		return
+	}
+	if err := s.d.Close(); err != nil {
+		slog.Error("Failed to close display", "err", err)
+	}
+	s.d = nil
+}
@@ -0,0 +1,125 @@
+package display
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/lutzky/pitemp/internal/app/eco"
+	"github.com/lutzky/pitemp/internal/app/shutdown"
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/pkg/client"
+)
+
+var (
+	serverTokenFile = flag.String("server_token_file", "", "File containing a bearer token for the pitemp API server, if it requires authentication")
+	authToken       = flag.String("auth_token", "", "Bearer token for the pitemp API server; prefer --server_token_file, which keeps the token out of the process list")
+	serverCA        = flag.String("server_ca", "", "PEM file with CA certificates to trust for https servers, e.g. for a self-signed certificate on a reverse proxy")
+	insecure        = flag.Bool("insecure", false, "Don't verify the certificates of https servers; only for testing")
+
+	// fetchTimeout bounds each attempt, so an unresponsive server doesn't
+	// delay failing over to the next one.
+	fetchTimeout = flag.Duration("fetch_timeout", client.DefaultFetchTimeout, "Timeout for each attempt to fetch state from a server")
+	fetchRetries = flag.Int("fetch_retries", client.DefaultFetchRetries, "How many times to retry fetching from a server, with exponential backoff, before failing over to the next one")
+	fetchBackoff = flag.Duration("fetch_backoff", client.DefaultFetchBackoff, "Delay before the first retry of a failed fetch; doubled for each further retry")
+	fetchJitter  = flag.Duration("fetch_jitter", 0, "Random extra delay of up to this much before each poll, so that many displays started together (e.g. after a power cut) don't poll the server in lockstep")
+
+	alignUpdates = flag.Bool("align_updates", true, "Tick the display at wall-clock multiples of the update interval (e.g. on the second), keeping the displayed clock in step; new state is shown as soon as it arrives regardless")
+
+	push = flag.Bool("push", true, "Subscribe to updates from the primary server over a WebSocket, rather than only polling it every --fetch_interval; polling resumes while disconnected")
+
+	sourceLabel      = flag.String("source_label", "", "Label for the state from --server on the display (e.g. garage); defaults to the server's --location, if set")
+	pageInterval     = flag.Duration("page_interval", client.DefaultPageInterval, "How long to show each page, when showing several rooms")
+	unreachableAfter = flag.Int("unreachable_after", client.DefaultUnreachableAfter, "Consecutive failed fetches (from all servers) after which the display shows the servers as unreachable")
+)
+
+// checkClientFlags checks the flags configuring the client
+func checkClientFlags(checks *startup.Checks) {
+	checks.Positive("fetch_timeout", *fetchTimeout)
+	checks.Range("fetch_retries", *fetchRetries, 0, 10)
+	checks.Positive("fetch_backoff", *fetchBackoff)
+	if *fetchJitter < 0 {
+		checks.Errorf("--fetch_jitter must not be negative, got %v", *fetchJitter)
+	}
+	checks.Positive("page_interval", *pageInterval)
+	checks.Range("unreachable_after", *unreachableAfter, 1, 1000)
+}
+
+// clientOptions returns the client options given by flags, with an HTTP
+// client and token for the servers
+func clientOptions() (client.Options, error) {
+	token, err := readToken()
+	if err != nil {
+		return client.Options{}, err
+	}
+	httpClient, err := newHTTPClient()
+	if err != nil {
+		return client.Options{}, err
+	}
+	opts := client.Options{
+		HTTPClient:       httpClient,
+		Token:            token,
+		FetchTimeout:     *fetchTimeout,
+		FetchRetries:     *fetchRetries,
+		FetchBackoff:     *fetchBackoff,
+		FetchJitter:      *fetchJitter,
+		Push:             *push,
+		AlignUpdates:     *alignUpdates,
+		SourceLabel:      *sourceLabel,
+		PageInterval:     *pageInterval,
+		UnreachableAfter: *unreachableAfter,
+		ShutdownTimeout:  shutdown.Timeout(),
+	}
+	if opts.FetchRetries == 0 {
+		opts.FetchRetries = -1 // Zero means the default in client.Options
+	}
+	if eco.Enabled() {
+		opts.Slowdown = eco.Factor
+	}
+	return opts, nil
+}
+
+// readToken returns the bearer token for the servers, according to flags
+func readToken() (string, error) {
+	switch {
+	case *authToken != "" && *serverTokenFile != "":
+		return "", fmt.Errorf("only one of --auth_token and --server_token_file may be set")
+	case *authToken != "":
+		return *authToken, nil
+	case *serverTokenFile != "":
+		b, err := os.ReadFile(*serverTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read server token: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return "", nil
+}
+
+// newHTTPClient returns an HTTP client for pitemp API servers, configured by
+// flags such as --server_ca and --fetch_timeout.
+func newHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: *insecure}
+	if *insecure {
+		slog.Warn("Not verifying server certificates (--insecure)")
+	}
+	if *serverCA != "" {
+		b, err := os.ReadFile(*serverCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --server_ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", *serverCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: *fetchTimeout}, nil
+}
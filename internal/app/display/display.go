@@ -0,0 +1,329 @@
+// Package display runs a display client, which fetches the state from pitemp
+// servers, shows it on a local LCD or PiOLED and serves a small status page,
+// and an admin API for controlling the display (authenticated by the same
+// flags as the serve command's API).
+package display
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // For --timezone on systems without zoneinfo, e.g. in containers
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/lutzky/pitemp/internal/app/accesslog"
+	"github.com/lutzky/pitemp/internal/app/auth"
+	"github.com/lutzky/pitemp/internal/app/debugserver"
+	"github.com/lutzky/pitemp/internal/app/eco"
+	"github.com/lutzky/pitemp/internal/app/geo"
+	"github.com/lutzky/pitemp/internal/app/ipaddr"
+	"github.com/lutzky/pitemp/internal/app/listen"
+	"github.com/lutzky/pitemp/internal/app/rtc"
+	"github.com/lutzky/pitemp/internal/app/shutdown"
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/internal/app/sysinfo"
+	"github.com/lutzky/pitemp/internal/sun"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/client"
+	displays "github.com/lutzky/pitemp/pkg/display"
+	_ "github.com/lutzky/pitemp/pkg/lcd" // Registers the lcd driver
+	"github.com/lutzky/pitemp/pkg/pioled"
+	"github.com/lutzky/pitemp/pkg/sensor/dht11"
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+var (
+	server         = flag.String("server", "", "Comma-separated URLs for pitemp API servers (including /api); later servers are used if earlier ones are unreachable")
+	rooms          = flag.String("rooms", "", "Comma-separated NAME=URL pitemp API servers (e.g. outside=http://garden:8080/api) to show on pages of their own, in rotation with --server's")
+	fetchInterval  = flag.Duration("fetch_interval", 1*time.Minute, "How often to poll the API server")
+	updateInterval = flag.Duration("update_interval", 0, "How often to update the screen (default 2s for the LCD, 1s for the PiOLED, or as set by the display driver); the screen is also updated as soon as the state changes")
+
+	localDHTPin     = flag.Int("local_dht11_pin", 0, "GPIO pin of a local DHT11, shown (and served on /api) instead of stale state while the servers are unreachable; 0 for none")
+	localDHTRetries = flag.Int("local_dht11_retries", 10, "Retries for the local DHT11")
+
+	clockFormat = flag.String("clock_format", "24h", "Clock format for the display: 24h or 12h")
+	dateLayout  = flag.String("date_layout", "Mon Jan 2", "Date shown before the clock, as a Go time layout (e.g. 02/01 for day/month); empty to show only the time")
+	timezone    = flag.String("timezone", "", "IANA time zone for the displayed clock (e.g. Europe/London); defaults to the system's local time zone")
+	screenOff   = flag.String("screen_off", "", "Cron schedule for blanking the display, e.g. \"0 23 * * *\" for 23:00 every night; requires --screen_on")
+	screenOn    = flag.String("screen_on", "", "Cron schedule for restoring the display, e.g. \"30 6 * * mon-fri\"")
+	outdoorRoom = flag.String("outdoor_room", "", "Add a page after the main one comparing it with outdoors: the --rooms room of that name, or \"forecast\" for --forecast's outdoor temperature")
+	sunPage     = flag.Bool("sun_page", false, "Add a page showing today's sunrise and sunset at --latitude and --longitude, after the rooms (and the forecast page)")
+	qrPage      = flag.Bool("qr_page", false, "Add a page showing the web UI's URL, at this host's preferred --ip_iface address and --qr_port, as a QR code on the PiOLED (or as text on the LCD), so that visitors can open it on their phones")
+	qrPort      = flag.Int("qr_port", 8080, "Port of the web UI shown by --qr_page, i.e. the --port of pitemp serve on this host")
+	systemPage  = flag.Bool("system_page", false, "Add a page showing this host's load average, memory and disk usage (of --system_disk) and SoC temperature, after the rooms")
+	messageLine = flag.Int("message_line", 2, "Line of the display replaced by messages sent to /api/message which don't give one: 1-4 on the LCD, or 1-3 on the PiOLED, where 3 is the clock line")
+	simulator   = flag.Bool("simulator", false, "Simulator mode - do not contact display hardware")
+)
+
+// Built-in kinds of display
+const (
+	LCD    = "lcd"
+	PiOLED = "pioled"
+)
+
+// Run runs a client for the given kind of display, given as DRIVER[:CONFIG]
+// (see package github.com/lutzky/pitemp/pkg/display), serving the status
+// page on port (unless overridden by --listen), until SIGTERM or SIGINT.
+// Call it after flag.Parse.
+func Run(kind string, port int) error {
+	servers := client.ParseServers(*server)
+	if len(servers) == 0 {
+		return errors.New("--server not provided")
+	}
+
+	roomList, err := client.ParseRooms(*rooms)
+	if err != nil {
+		return err
+	}
+
+	var checks startup.Checks
+	for _, s := range servers {
+		checks.URL("server", s)
+	}
+	for _, r := range roomList {
+		checks.URL("rooms", r.URL)
+	}
+	checks.Positive("fetch_interval", *fetchInterval)
+	checkClientFlags(&checks)
+	if *updateInterval != 0 {
+		checks.Positive("update_interval", *updateInterval)
+	}
+	rtc.CheckFlags(&checks)
+	eco.CheckFlags(&checks)
+	ipaddr.CheckFlags(&checks)
+	checks.Range("message_line", *messageLine, 1, maxMessageLine)
+	checkForecastFlags(&checks)
+	if _, _, ok := geo.Position(); *sunPage && !ok {
+		checks.Errorf("--sun_page requires --latitude and --longitude")
+	}
+	checks.Range("port", port, 1, 65535)
+	if *qrPage {
+		checks.Range("qr_port", *qrPort, 1, 65535)
+	}
+	checks.Done()
+
+	if err := rtc.Setup(); err != nil {
+		return err
+	}
+
+	location := time.Local
+	if *timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(*timezone); err != nil {
+			return fmt.Errorf("invalid --timezone: %w", err)
+		}
+	}
+	layout, err := clockLayout(*clockFormat, *dateLayout)
+	if err != nil {
+		return err
+	}
+	ip := ipaddr.Selection()
+	settings := displays.Settings{Location: location, ClockLayout: layout, IP: ip}
+
+	var scr screen
+	if *screenOff != "" || *screenOn != "" {
+		if err := scr.schedule(*screenOff, *screenOn); err != nil {
+			return err
+		}
+	}
+
+	weather, err := newForecast()
+	if err != nil {
+		return err
+	}
+
+	driver, config, err := displays.Lookup(kind)
+	if err != nil {
+		return err
+	}
+	interval := *updateInterval
+	if interval == 0 {
+		interval = driver.UpdateInterval
+	}
+
+	update := func() error { return nil }
+	if !*simulator {
+		scr.open = func() (displays.Display, error) { return driver.Open(config, settings) }
+		d, err := scr.open()
+		if err != nil {
+			return fmt.Errorf("failed to initialize %s: %w", kind, err)
+		}
+		update = scr.wrap(d)
+		defer scr.close()
+		if !scr.canBlank && scr.scheduled {
+			return fmt.Errorf("%s can't be blanked, as required by --screen_off", kind)
+		}
+	}
+
+	if err := client.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		return fmt.Errorf("failed to register metrics: %w", err)
+	}
+	if ip.Ifaces != "" {
+		if err := prometheus.Register(newWiFiCollector(ip)); err != nil {
+			return fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", pioled.HTTPHandler(pioled.WithLocation(location), pioled.WithClockLayout(layout)))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/api", serveJSON)
+	authenticator, err := auth.New()
+	if err != nil {
+		return fmt.Errorf("failed to set up authentication: %w", err)
+	}
+	for path, h := range scr.adminHandlers() {
+		mux.HandleFunc(path, auth.Required(authenticator, h))
+	}
+	mux.HandleFunc("/api/message", auth.Required(authenticator, serveMessage))
+	debugSrv := debugserver.Setup(mux)
+	l, err := listen.Listen(port)
+	if err != nil {
+		return err
+	}
+	srv := http.Server{Handler: accesslog.Handler(authenticator.Handler(mux))}
+
+	opts, err := clientOptions()
+	if err != nil {
+		return err
+	}
+	opts.Servers, opts.Rooms = servers, roomList
+	opts.Update = update
+	opts.FetchInterval, opts.UpdateInterval = *fetchInterval, interval
+	opts.Outdoor = *outdoorRoom
+	if *localDHTPin != 0 {
+		opts.Local = readLocalDHT
+	}
+	if weather != nil {
+		opts.Forecast, opts.ForecastInterval = weather.Fetch, *forecastInterval
+	}
+	if *sunPage {
+		opts.Sun = daylight
+	}
+	if *qrPage {
+		opts.WebURL = func() string { return webURL(ip) }
+	}
+	if *systemPage {
+		opts.System = sysinfo.Read
+	}
+
+	ctx, stop := shutdown.OnSignal(context.Background())
+	defer stop()
+	workers, ctx := sync.WithContext(ctx)
+
+	workers.Run("HTTP server", func() error {
+		if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	if debugSrv != nil {
+		workers.Run("pprof server", func() error { return listen.Serve(debugSrv) })
+	}
+	workers.Supervise(ctx, "screen", func() { scr.run(ctx) })
+	workers.Supervise(ctx, "rtc", func() { rtc.Sync(ctx) })
+	workers.Supervise(ctx, "eco", func() { eco.WatchMotion(ctx) })
+
+	slog.Info("Starting client")
+	workers.Run("client", func() error { return client.Run(ctx, opts) })
+
+	<-ctx.Done()
+	slog.Info("Shutting down")
+
+	shutdownCtx, cancel := shutdown.Context()
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to cleanly shut down HTTP server", "err", err)
+	}
+	if debugSrv != nil {
+		if err := debugSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to cleanly shut down pprof server", "err", err)
+		}
+	}
+	// Wait for the client, so that the display isn't closed mid-update
+	if err := workers.Wait(shutdownCtx); err != nil {
+		slog.Warn("Gave up waiting for background work to stop", "err", err)
+	}
+	return workers.Err()
+}
+
+// clockLayout returns the time.Format layout for the clock line
+func clockLayout(clockFormat, dateLayout string) (string, error) {
+	var layout string
+	switch clockFormat {
+	case "24h":
+		layout = "15:04:05"
+	case "12h":
+		layout = "3:04:05PM"
+	default:
+		return "", fmt.Errorf("invalid --clock_format %q, expected 24h or 12h", clockFormat)
+	}
+	if dateLayout != "" {
+		layout = dateLayout + " " + layout
+	}
+	return layout, nil
+}
+
+// daylight returns the sunrise and sunset at --latitude and --longitude on
+// the day of t
+func daylight(t time.Time) client.Daylight {
+	lat, lon, _ := geo.Position()
+	sunrise, sunset, length := sun.Times(t, lat, lon)
+	return client.Daylight{Sunrise: sunrise, Sunset: sunset, Length: length}
+}
+
+// webURL returns the URL of the web UI for --qr_page, at the preferred
+// address of ip, or "" if there is none
+func webURL(ip displays.IPSelection) string {
+	addrs, err := ip.Addresses()
+	if err != nil || len(addrs) == 0 {
+		return ""
+	}
+	host := addrs[0].Addr
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		// With --ip_prefix_length
+		host = host[:i]
+	}
+	u := url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(*qrPort)), Path: "/"}
+	return u.String()
+}
+
+// readLocalDHT reads the local DHT11
+func readLocalDHT(ctx context.Context) (*state.State, error) {
+	temperature, humidity, _, err := dht11.Hardware.ReadDHT11(ctx, *localDHTPin, false, *localDHTRetries)
+	if err != nil {
+		return nil, err
+	}
+	var s state.State
+	now := time.Now()
+	s.SetReading("temperature", state.Reading{Value: temperature, Unit: "celsius", Sensor: "dht11", MeasuredAt: now})
+	s.SetReading("humidity", state.Reading{Value: humidity, Unit: "percent", Sensor: "dht11", MeasuredAt: now})
+	return &s, nil
+}
+
+// serveJSON serves the state shown on the main page, in the wire format
+func serveJSON(w http.ResponseWriter, _ *http.Request) {
+	page := client.Main()
+	resp := struct {
+		wire.State
+		Local bool `json:"local"`
+	}{wire.FromState(page.State), page.Local}
+	resp.Location = page.Label
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+	}
+}
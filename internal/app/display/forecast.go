@@ -0,0 +1,64 @@
+package display
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/geo"
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/internal/forecast"
+)
+
+// Forecast providers for --forecast
+const (
+	OpenMeteo      = "open-meteo"
+	OpenWeatherMap = "openweathermap"
+)
+
+var (
+	forecastProvider = flag.String("forecast", "", "Show the outdoor temperature and tomorrow's forecast for --latitude and --longitude on a page of their own, from open-meteo or openweathermap (which requires --forecast_key_file); empty for none")
+	forecastKeyFile  = flag.String("forecast_key_file", "", "File containing the API key for --forecast=openweathermap")
+	forecastURL      = flag.String("forecast_url", "", "Forecast endpoint, defaulting to the provider's public API; change e.g. for a self-hosted Open-Meteo")
+	forecastInterval = flag.Duration("forecast_interval", 30*time.Minute, "How often to fetch the forecast")
+)
+
+// checkForecastFlags checks the --forecast flags
+func checkForecastFlags(checks *startup.Checks) {
+	geo.CheckFlags(checks)
+	switch *forecastProvider {
+	case "", OpenMeteo, OpenWeatherMap:
+	default:
+		checks.Errorf("--forecast must be %s, %s or empty, got %q", OpenMeteo, OpenWeatherMap, *forecastProvider)
+	}
+	checks.Positive("forecast_interval", *forecastInterval)
+	checks.URL("forecast_url", *forecastURL)
+}
+
+// newForecast returns the provider given by --forecast, or nil for none
+func newForecast() (forecast.Provider, error) {
+	if *forecastProvider == "" {
+		return nil, nil
+	}
+	lat, lon, ok := geo.Position()
+	if !ok {
+		return nil, errors.New("--forecast requires --latitude and --longitude")
+	}
+	switch *forecastProvider {
+	case OpenMeteo:
+		return &forecast.OpenMeteo{URL: *forecastURL, Latitude: lat, Longitude: lon}, nil
+	case OpenWeatherMap:
+		if *forecastKeyFile == "" {
+			return nil, errors.New("--forecast=openweathermap requires --forecast_key_file")
+		}
+		b, err := os.ReadFile(*forecastKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read forecast API key: %w", err)
+		}
+		return &forecast.OpenWeatherMap{URL: *forecastURL, Key: strings.TrimSpace(string(b)), Latitude: lat, Longitude: lon}, nil
+	}
+	return nil, fmt.Errorf("unknown --forecast %q", *forecastProvider)
+}
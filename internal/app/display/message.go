@@ -0,0 +1,69 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/client"
+)
+
+// maxMessageLine is the number of lines of the largest built-in display,
+// the LCD
+const maxMessageLine = 4
+
+// serveMessage shows a message on the display on POST, given by a JSON
+// object such as {"text": "Washing done", "line": 3, "expires_in": "1h"} in
+// which only text is required, and clears it on DELETE
+func serveMessage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		client.SetMessage(client.Message{})
+		client.Refresh()
+		slog.Info("Cleared message")
+		fmt.Fprintln(w, "Cleared message")
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Text      string `json:"text"`
+		Line      int    `json:"line"`
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid message: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		http.Error(w, "Invalid message: text is required (use DELETE to clear the message)", http.StatusBadRequest)
+		return
+	}
+	m := client.Message{Text: req.Text, Line: *messageLine}
+	if req.Line != 0 {
+		if req.Line < 1 || req.Line > maxMessageLine {
+			http.Error(w, fmt.Sprintf("Invalid message: line must be 1-%d, got %d", maxMessageLine, req.Line), http.StatusBadRequest)
+			return
+		}
+		m.Line = req.Line
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid message: expires_in must be a positive duration, e.g. 30m, got %q", req.ExpiresIn), http.StatusBadRequest)
+			return
+		}
+		m.Expires = time.Now().Add(d)
+	}
+
+	client.SetMessage(m)
+	client.Refresh()
+	slog.Info("Showing message", "text", m.Text, "line", m.Line, "expires", m.Expires)
+	fmt.Fprintln(w, "Showing message")
+}
@@ -0,0 +1,41 @@
+package display
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/wifi"
+	displays "github.com/lutzky/pitemp/pkg/display"
+)
+
+// wifiCollector exports the signal level of the selected wireless
+// interfaces, read on every scrape; nothing is exported for interfaces
+// whose level can't be read (e.g. wired ones)
+type wifiCollector struct {
+	ip   displays.IPSelection
+	desc *prometheus.Desc
+}
+
+func newWiFiCollector(ip displays.IPSelection) wifiCollector {
+	return wifiCollector{
+		ip: ip,
+		desc: prometheus.NewDesc("pitemp_client_wifi_signal_dbm",
+			"Signal level of the wireless interfaces given by --ip_iface, by interface",
+			[]string{"iface"}, nil),
+	}
+}
+
+func (c wifiCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c wifiCollector) Collect(ch chan<- prometheus.Metric) {
+	names, err := c.ip.Interfaces()
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		if level, err := wifi.Signal(name); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, level, name)
+		}
+	}
+}
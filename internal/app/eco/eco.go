@@ -0,0 +1,144 @@
+// Package eco implements --eco, a low-power mode for battery-powered nodes
+// and displays: sensor reads, display updates and fetches are slowed down
+// while the readings are stable, or while nobody is around to see them (as
+// told by a PIR motion sensor or a light sensor).
+package eco
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/internal/gpio"
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var (
+	enabled  = flag.Bool("eco", false, "Low-power mode: read sensors, update the display and fetch from servers --eco_factor times less often while readings are stable (see --eco_max_trend) or nobody is present (see --eco_pir_pin and --eco_dark_lux)")
+	factor   = flag.Int("eco_factor", 4, "How many times less often to do things in --eco mode")
+	maxTrend = flag.Float64("eco_max_trend", 0.5, "Readings count as stable for --eco while none of them changes faster than this, per hour (e.g. 0.5°C/h)")
+
+	pirPin          = flag.Int("eco_pir_pin", 0, "GPIO pin of a PIR motion sensor; --eco mode only applies while it hasn't detected motion for --eco_presence_timeout, and otherwise applies regardless of the readings. 0 for none")
+	presenceTimeout = flag.Duration("eco_presence_timeout", 10*time.Minute, "How long someone counts as present after --eco_pir_pin detects motion")
+	darkLux         = flag.Float64("eco_dark_lux", 0, "Nobody counts as present for --eco while the \"illuminance\" reading is below this many lux, unless --eco_pir_pin detected motion; 0 to ignore the light level")
+)
+
+// pirPollInterval is how often --eco_pir_pin is read; PIR sensors hold
+// their output high for a few seconds after detecting motion
+const pirPollInterval = time.Second
+
+// illuminance is the name of the reading compared against --eco_dark_lux
+const illuminance = "illuminance"
+
+var (
+	// lastMotion is when --eco_pir_pin last detected motion, in Unix
+	// nanoseconds; someone is assumed to be present at startup, e.g.
+	// whoever started it
+	lastMotion = time.Now().UnixNano()
+
+	// active is 1 while in eco mode, to log changes
+	active int32
+)
+
+// CheckFlags adds checks for the eco flags to checks
+func CheckFlags(checks *startup.Checks) {
+	if !*enabled {
+		return
+	}
+	checks.Range("eco_factor", *factor, 1, 100)
+	if *pirPin != 0 {
+		checks.Positive("eco_presence_timeout", *presenceTimeout)
+	}
+}
+
+// Enabled returns whether eco mode is enabled, by --eco
+func Enabled() bool {
+	return *enabled
+}
+
+// Schedule returns options slowing down a sync.RepeatUntilCancelled loop
+// while in eco mode, if enabled
+func Schedule() []sync.RepeatOption {
+	if !*enabled {
+		return nil
+	}
+	return []sync.RepeatOption{sync.WithSlowdown(Factor)}
+}
+
+// Factor returns how many times less often to do things: --eco_factor while
+// in eco mode, and 1 otherwise
+func Factor() int {
+	on, reason := inEco(state.Get(), time.Now())
+	var v int32
+	if on {
+		v = 1
+	}
+	if atomic.SwapInt32(&active, v) != v {
+		if on {
+			slog.Info("Entering eco mode", "reason", reason)
+		} else {
+			slog.Info("Leaving eco mode", "reason", reason)
+		}
+	}
+	if !on {
+		return 1
+	}
+	return *factor
+}
+
+// inEco returns whether to be in eco mode given s, and why
+func inEco(s state.State, now time.Time) (bool, string) {
+	if !*enabled {
+		return false, "disabled"
+	}
+	if *pirPin != 0 {
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&lastMotion))) < *presenceTimeout {
+			return false, "motion"
+		}
+		return true, "no motion"
+	}
+	if *darkLux > 0 {
+		if r, ok := s.Reading(illuminance); ok && float64(r.Value) < *darkLux {
+			return true, "dark"
+		}
+	}
+	if len(s.Trends) == 0 {
+		return false, "no trends"
+	}
+	for _, t := range s.Trends {
+		if math.Abs(float64(t)) > *maxTrend {
+			return false, "changing"
+		}
+	}
+	return true, "stable"
+}
+
+// WatchMotion reads --eco_pir_pin, if set, until ctx is done. Motion
+// leaves eco mode as of the next wait.
+func WatchMotion(ctx context.Context) {
+	if !*enabled || *pirPin == 0 {
+		return
+	}
+	pir, err := gpio.OpenInput(*pirPin)
+	if err != nil {
+		slog.Error("Failed to open PIR sensor, assuming someone is present", "err", err)
+		atomic.StoreInt64(&lastMotion, math.MaxInt64)
+		return
+	}
+	defer pir.Close()
+	sync.RepeatUntilCancelled(ctx, func() {
+		motion, err := pir.Get()
+		if err != nil {
+			slog.Error("Failed to read PIR sensor", "err", err)
+			return
+		}
+		if motion {
+			atomic.StoreInt64(&lastMotion, time.Now().UnixNano())
+		}
+	}, pirPollInterval)
+}
@@ -0,0 +1,31 @@
+// Package geo holds where the node is, as given by --latitude and
+// --longitude, for features depending on it such as the displays' forecast
+// page, and the sunrise and sunset served on /api.
+package geo
+
+import (
+	"flag"
+
+	"github.com/lutzky/pitemp/internal/app/startup"
+)
+
+var (
+	latitude  = flag.Float64("latitude", 0, "Latitude of the node in degrees, north positive (e.g. 51.5); used by --forecast and --sun_page, and for the sunrise and sunset served on /api")
+	longitude = flag.Float64("longitude", 0, "Longitude of the node in degrees, east positive (e.g. -0.12)")
+)
+
+// Position returns the node's latitude and longitude, or ok = false if they
+// aren't set
+func Position() (lat, lon float64, ok bool) {
+	return *latitude, *longitude, *latitude != 0 || *longitude != 0
+}
+
+// CheckFlags checks --latitude and --longitude
+func CheckFlags(checks *startup.Checks) {
+	if *latitude < -90 || *latitude > 90 {
+		checks.Errorf("--latitude must be between -90 and 90, got %v", *latitude)
+	}
+	if *longitude < -180 || *longitude > 180 {
+		checks.Errorf("--longitude must be between -180 and 180, got %v", *longitude)
+	}
+}
@@ -0,0 +1,28 @@
+// Package ipaddr holds which of the node's IP addresses to show, as given by
+// --ip_iface and friends, for the displays and the web UI served by pitemp
+// serve.
+package ipaddr
+
+import (
+	"flag"
+
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/pkg/display"
+)
+
+var (
+	iface     = flag.String("ip_iface", "wlan0", "Network interface whose IP address is shown on the display and the web UI, a comma-separated list of them (skipping those which don't exist), or * for all; the signal level of wireless ones is shown on the display and exported as a metric too")
+	prefer    = flag.String("ip_prefer", display.PreferIPv4, "Which IP address to show first: ipv4 or ipv6 (global addresses of that family first) or any (in the order listed by the system); link-local addresses are skipped")
+	all       = flag.Bool("ip_all", false, "Cycle through all IP addresses of --ip_iface on the display, rather than showing only the preferred one")
+	prefixLen = flag.Bool("ip_prefix_length", false, "Show the CIDR prefix length of IP addresses, e.g. 192.168.1.2/24")
+)
+
+// Selection returns the selection of IP addresses given by the flags
+func Selection() display.IPSelection {
+	return display.IPSelection{Ifaces: *iface, Prefer: *prefer, All: *all, PrefixLength: *prefixLen}
+}
+
+// CheckFlags checks --ip_prefer
+func CheckFlags(checks *startup.Checks) {
+	checks.Check("--ip_prefer", display.CheckPrefer(*prefer))
+}
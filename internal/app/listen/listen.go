@@ -0,0 +1,78 @@
+// Package listen opens the main HTTP listener, as configured by the
+// --listen flag, or the binary's own --port flag, and serves secondary
+// servers (such as for metrics) on addresses of their own.
+package listen
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	addr       = flag.String("listen", "", "Address for HTTP, e.g. 127.0.0.1:8080 to only accept local connections, or unix:/run/pitemp.sock for a Unix socket; overrides --port")
+	socketMode = flag.String("listen_socket_mode", "0660", "Permissions for the Unix socket, if listening on one")
+)
+
+// Listen listens on --listen if set, or otherwise on port on all
+// interfaces.
+func Listen(port int) (net.Listener, error) {
+	a := *addr
+	if a == "" {
+		a = fmt.Sprintf(":%d", port)
+	}
+	if path := strings.TrimPrefix(a, "unix:"); path != a {
+		return listenUnix(path)
+	}
+
+	l, err := net.Listen("tcp", a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", a, err)
+	}
+	return l, nil
+}
+
+// listenUnix listens on a Unix socket at path, replacing any stale socket
+// left behind by an unclean shutdown. The socket is removed when the
+// listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --listen_socket_mode %q: %w", *socketMode, err)
+	}
+
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%q exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", path, err)
+	}
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return l, nil
+}
+
+// Serve listens on srv.Addr and serves srv until it's shut down, returning
+// nil then, or an error if it fails (e.g. as the address is in use)
+func Serve(srv *http.Server) error {
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve on %q: %w", srv.Addr, err)
+	}
+	return nil
+}
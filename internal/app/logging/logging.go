@@ -0,0 +1,119 @@
+// Package logging sets up structured logging with log/slog, which all
+// binaries log through.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/d2r2/go-logger"
+)
+
+var (
+	level     = flag.String("log_level", "info", "Minimum level of log messages: debug, info, warn or error")
+	format    = flag.String("log_format", "text", "Log format: text (logfmt) or json, e.g. for Loki or journald pipelines")
+	syslogTag = flag.String("log_syslog", "", "If set, log to the local syslog daemon with this tag (e.g. pitemp) instead of stderr")
+)
+
+// d2r2Packages are the packages logging through github.com/d2r2/go-logger
+var d2r2Packages = []string{"dht", "i2c"}
+
+// Setup configures the default slog logger from flags; call it after
+// flag.Parse. Messages logged with the standard log package are also handled
+// by it, at the info level. Logs go to stderr, or to syslog with
+// --log_syslog; under systemd, they are marked with their severity for the
+// journal.
+//
+// The d2r2 sensor and i2c libraries write their own log messages straight to
+// stdout, so only their level is set here; debug messages from them
+// (e.g. about DHT11 checksum failures) appear with --log_level=debug.
+func Setup() error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(*level)); err != nil {
+		return fmt.Errorf("invalid --log_level %q: %w", *level, err)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       lvl,
+		AddSource:   true,
+		ReplaceAttr: shortSource,
+	}
+	if *syslogTag != "" || underJournald() {
+		// Timestamps are added by journald or syslog
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return shortSource(groups, a)
+		}
+	}
+
+	var newHandler func(io.Writer) slog.Handler
+	switch *format {
+	case "text":
+		newHandler = func(w io.Writer) slog.Handler { return slog.NewTextHandler(w, opts) }
+	case "json":
+		newHandler = func(w io.Writer) slog.Handler { return slog.NewJSONHandler(w, opts) }
+	default:
+		return fmt.Errorf("invalid --log_format %q, expected text or json", *format)
+	}
+
+	// Under systemd, stderr goes to the journal, which takes the severity
+	// of each line from a "<N>" prefix
+	var h slog.Handler
+	switch {
+	case *syslogTag != "":
+		write, err := syslogWriter(*syslogTag)
+		if err != nil {
+			return err
+		}
+		h = newSeverityHandler(newHandler, write)
+	case underJournald():
+		h = newSeverityHandler(newHandler, writeJournald)
+	default:
+		h = newHandler(os.Stderr)
+	}
+	slog.SetDefault(slog.New(h))
+
+	d2r2Level := logger.InfoLevel
+	switch {
+	case lvl <= slog.LevelDebug:
+		d2r2Level = logger.DebugLevel
+	case lvl >= slog.LevelError:
+		d2r2Level = logger.ErrorLevel
+	case lvl >= slog.LevelWarn:
+		d2r2Level = logger.WarnLevel
+	}
+	for _, p := range d2r2Packages {
+		logger.ChangePackageLogLevel(p, d2r2Level)
+	}
+	return nil
+}
+
+// shortSource trims source file paths to the file name, like log.Lshortfile
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey && len(groups) == 0 {
+		if src, ok := a.Value.Any().(*slog.Source); ok {
+			a.Value = slog.StringValue(fmt.Sprintf("%s:%d", filepath.Base(src.File), src.Line))
+		}
+	}
+	return a
+}
+
+// Fatal logs msg and args (as for slog.Error) at the error level, and exits
+func Fatal(msg string, args ...interface{}) {
+	// Report the caller as the source, rather than this function
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	r := slog.NewRecord(time.Now(), slog.LevelError, msg, pcs[0])
+	r.Add(args...)
+	slog.Default().Handler().Handle(context.Background(), r)
+	os.Exit(1)
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// severityHandler formats records with an inner handler, and passes each
+// formatted record along with its level to write, for destinations which
+// track severity themselves (journald and syslog).
+type severityHandler struct {
+	mu    *sync.Mutex
+	buf   *bytes.Buffer
+	h     slog.Handler // Writes to buf
+	write func(slog.Level, string) error
+}
+
+func newSeverityHandler(newHandler func(io.Writer) slog.Handler, write func(slog.Level, string) error) *severityHandler {
+	buf := &bytes.Buffer{}
+	return &severityHandler{&sync.Mutex{}, buf, newHandler(buf), write}
+}
+
+func (h *severityHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.h.Enabled(ctx, l)
+}
+
+func (h *severityHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.h.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.write(r.Level, h.buf.String())
+}
+
+func (h *severityHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &severityHandler{h.mu, h.buf, h.h.WithAttrs(attrs), h.write}
+}
+
+func (h *severityHandler) WithGroup(name string) slog.Handler {
+	return &severityHandler{h.mu, h.buf, h.h.WithGroup(name), h.write}
+}
+
+// underJournald reports whether stderr is connected to the systemd journal
+func underJournald() bool {
+	return os.Getenv("JOURNAL_STREAM") != ""
+}
+
+// journaldPriority returns the syslog priority for l, as understood by
+// journald in "<N>" line prefixes
+func journaldPriority(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3
+	case l >= slog.LevelWarn:
+		return 4
+	case l >= slog.LevelInfo:
+		return 6
+	}
+	return 7
+}
+
+// writeJournald writes line to stderr with a priority prefix
+func writeJournald(l slog.Level, line string) error {
+	_, err := fmt.Fprintf(os.Stderr, "<%d>%s", journaldPriority(l), line)
+	return err
+}
+
+// syslogWriter returns a function sending lines to the local syslog daemon
+// with tag, at the severity matching their level
+func syslogWriter(tag string) (func(slog.Level, string) error, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return func(l slog.Level, line string) error {
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case l >= slog.LevelError:
+			return w.Err(line)
+		case l >= slog.LevelWarn:
+			return w.Warning(line)
+		case l >= slog.LevelInfo:
+			return w.Info(line)
+		}
+		return w.Debug(line)
+	}, nil
+}
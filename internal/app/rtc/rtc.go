@@ -0,0 +1,82 @@
+// Package rtc sets the system clock from an I²C real-time clock, as
+// configured by --rtc, for Pis which may boot without network access (and
+// therefore without NTP). Either that or --trust_clock mark the clock as
+// valid (see package clock) before NTP syncs it.
+package rtc
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lutzky/pitemp/internal/app/startup"
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/internal/sync"
+)
+
+var (
+	kind = flag.String("rtc", "", "Real-time clock to set the system clock from at startup, unless NTP already synchronized it: ds3231, or empty for none. Setting the clock requires CAP_SYS_TIME; while NTP keeps the clock synchronized, the RTC is updated from it")
+	bus  = flag.Int("rtc_bus", 1, "I2C bus of the --rtc")
+
+	trustClock = flag.Bool("trust_clock", false, "Trust the system clock even if the kernel doesn't consider it synchronized, e.g. on hosts whose clock is kept accurate without NTP; otherwise displays show no clock (and no ages of readings) until it is")
+)
+
+// syncInterval is how often the RTC is updated from the system clock
+const syncInterval = time.Hour
+
+// CheckFlags adds checks for the RTC flags to checks
+func CheckFlags(checks *startup.Checks) {
+	switch *kind {
+	case "", "ds3231":
+	default:
+		checks.Errorf("--rtc must be ds3231 or empty, got %q", *kind)
+	}
+}
+
+// Setup sets the system clock from the RTC, if one is configured and the
+// clock isn't synchronized yet; call it after flag.Parse.
+func Setup() error {
+	if *trustClock {
+		clock.MarkValid()
+	}
+	if *kind == "" {
+		return nil
+	}
+	if clock.Synced() {
+		slog.Info("Clock already synchronized, not setting it from the RTC")
+		return nil
+	}
+	t, err := clock.ReadDS3231(*bus)
+	if err != nil {
+		return err
+	}
+	tv := unix.NsecToTimeval(t.UnixNano())
+	if err := unix.Settimeofday(&tv); err != nil {
+		return fmt.Errorf("failed to set the clock from the RTC (which requires CAP_SYS_TIME): %w", err)
+	}
+	clock.MarkValid()
+	slog.Info("Set the clock from the RTC", "time", t)
+	return nil
+}
+
+// Sync updates the RTC from the system clock every hour while the latter is
+// synchronized, until ctx is done
+func Sync(ctx context.Context) {
+	if *kind == "" {
+		return
+	}
+	sync.RepeatUntilCancelled(ctx, func() {
+		if !clock.Synced() {
+			return
+		}
+		if err := clock.WriteDS3231(*bus, time.Now()); err != nil {
+			slog.Error("Failed to update the RTC", "err", err)
+			return
+		}
+		slog.Debug("Updated the RTC")
+	}, syncInterval)
+}
@@ -0,0 +1,231 @@
+// Package settings makes selected flags adjustable at runtime through an
+// HTTP API, so that tuning a node doesn't require a restart. Changes are
+// saved to the configuration file given by --config (see package config),
+// so that they outlive the process.
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/app/config"
+)
+
+var (
+	// mu serializes changes, so that concurrent requests don't interleave
+	// their changes or writes to the configuration file
+	mu sync.Mutex
+
+	adjustable = map[string]bool{}
+	onChange   = map[string][]func(){}
+)
+
+// Register makes the existing flag name adjustable at runtime. Its
+// flag.Value must be safe for concurrent use, as Duration and Choice are.
+func Register(name string) {
+	if flag.Lookup(name) == nil {
+		panic(fmt.Sprintf("settings: no flag %q", name))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	adjustable[name] = true
+}
+
+// OnChange calls f after name is changed at runtime, e.g. to apply the new
+// value elsewhere
+func OnChange(name string, f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	onChange[name] = append(onChange[name], f)
+}
+
+// Get returns the current values of all adjustable settings, by name
+func Get() map[string]string {
+	mu.Lock()
+	defer mu.Unlock()
+	return current()
+}
+
+func current() map[string]string {
+	values := make(map[string]string, len(adjustable))
+	for name := range adjustable {
+		values[name] = flag.Lookup(name).Value.String()
+	}
+	return values
+}
+
+// ErrInvalid is returned by Set for settings which aren't adjustable, or
+// invalid values
+var ErrInvalid = errors.New("invalid setting")
+
+// Set changes settings, given by name, and saves them to the configuration
+// file. Either all changes are applied and saved, or none are.
+func Set(changes map[string]string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		if !adjustable[name] {
+			return fmt.Errorf("%w: %s can't be changed at runtime; adjustable settings are %s", ErrInvalid, name, strings.Join(adjustableNames(), ", "))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	old := current()
+	revert := func() {
+		for _, name := range names {
+			flag.Lookup(name).Value.Set(old[name])
+		}
+	}
+	for _, name := range names {
+		// Not flag.Set, which also records the flag as set on the command
+		// line, racing with anything else looking at flags
+		if err := flag.Lookup(name).Value.Set(changes[name]); err != nil {
+			revert()
+			return fmt.Errorf("%w: invalid value %q for %s: %v", ErrInvalid, changes[name], name, err)
+		}
+	}
+	if err := config.Save(changes); err != nil {
+		revert()
+		return err
+	}
+
+	for _, name := range names {
+		slog.Info("Changed setting", "name", name, "old", old[name], "new", flag.Lookup(name).Value.String())
+		for _, f := range onChange[name] {
+			f()
+		}
+	}
+	return nil
+}
+
+// adjustableNames returns the names of the adjustable settings, sorted;
+// call it with mu held
+func adjustableNames() []string {
+	result := make([]string, 0, len(adjustable))
+	for name := range adjustable {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Handler serves the adjustable settings as a JSON object of strings, by
+// name, on GET; and changes the settings given by such an object on POST,
+// serving the resulting settings. It doesn't authenticate requests, so it
+// must only be served behind authentication.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		var changes map[string]string
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&changes); err != nil {
+			http.Error(w, fmt.Sprintf("Expected a JSON object of strings: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := Set(changes); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalid) {
+				code = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Get()); err != nil {
+		slog.Error("Error encoding JSON", "err", err)
+	}
+}
+
+// Duration is a positive duration flag, safe for concurrent use
+type Duration struct {
+	v int64
+}
+
+// NewDuration defines an adjustable positive duration flag
+func NewDuration(name string, value time.Duration, usage string) *Duration {
+	d := &Duration{v: int64(value)}
+	flag.Var(d, name, usage)
+	Register(name)
+	return d
+}
+
+// Load returns the current value
+func (d *Duration) Load() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.v))
+}
+
+func (d *Duration) String() string {
+	if d == nil {
+		return "0s"
+	}
+	return d.Load().String()
+}
+
+// Set parses s, e.g. 1m30s
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v <= 0 {
+		return errors.New("must be positive")
+	}
+	atomic.StoreInt64(&d.v, int64(v))
+	return nil
+}
+
+// Choice is a flag taking one of a few values, safe for concurrent use
+type Choice struct {
+	v       atomic.Value
+	choices []string
+}
+
+// NewChoice defines an adjustable flag taking one of choices
+func NewChoice(name, value string, choices []string, usage string) *Choice {
+	c := &Choice{choices: choices}
+	c.v.Store(value)
+	flag.Var(c, name, usage)
+	Register(name)
+	return c
+}
+
+// Load returns the current value
+func (c *Choice) Load() string {
+	return c.v.Load().(string)
+}
+
+func (c *Choice) String() string {
+	if c == nil || c.v.Load() == nil {
+		return ""
+	}
+	return c.Load()
+}
+
+// Set sets the value to s, which must be one of the choices
+func (c *Choice) Set(s string) error {
+	for _, choice := range c.choices {
+		if s == choice {
+			c.v.Store(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(c.choices, ", "))
+}
@@ -0,0 +1,30 @@
+// Package shutdown coordinates graceful shutdown: background work is
+// cancelled on SIGTERM or SIGINT, and then given up to --shutdown_timeout to
+// wind down before the process exits.
+package shutdown
+
+import (
+	"context"
+	"flag"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var timeout = flag.Duration("shutdown_timeout", 10*time.Second, "Maximum time to wait for pending work (sensor reads, writes to outputs, HTTP requests) when shutting down")
+
+// OnSignal returns a copy of parent which is cancelled on SIGTERM or SIGINT
+func OnSignal(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, syscall.SIGTERM, syscall.SIGINT)
+}
+
+// Timeout returns --shutdown_timeout
+func Timeout() time.Duration {
+	return *timeout
+}
+
+// Context returns a context for winding down, which expires after
+// --shutdown_timeout.
+func Context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), *timeout)
+}
@@ -0,0 +1,21 @@
+// Package staleness configures, by the --stale_after flag, how old state has
+// to be to be considered stale. The API, web UI and displays all use it. The
+// flag is adjustable at runtime (see package settings).
+package staleness
+
+import (
+	"github.com/lutzky/pitemp/internal/app/settings"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var after = settings.NewDuration("stale_after", state.StaleAfter(), "Readings older than this are considered stale, and marked as such by the API, web UI and displays; adjustable at runtime")
+
+func init() {
+	settings.OnChange("stale_after", Apply)
+}
+
+// Apply sets state.StaleAfter according to --stale_after; call it after
+// flag.Parse.
+func Apply() {
+	state.SetStaleAfter(after.Load())
+}
@@ -0,0 +1,87 @@
+// Package startup validates flags and hardware when a binary starts. By
+// default, problems are only logged, so that e.g. a node with a flaky sensor
+// still serves its UI; with --strict_init they are fatal, for deployments
+// which should rather fail loudly (and get restarted by systemd).
+package startup
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+var strict = flag.Bool("strict_init", false, "Validate flags and probe configured hardware at startup, exiting non-zero on any problem instead of logging it and carrying on")
+
+// Strict reports whether --strict_init is set, in which case configured
+// hardware should be probed at startup.
+func Strict() bool {
+	return *strict
+}
+
+// Checks collects problems found at startup
+type Checks struct {
+	problems []string
+}
+
+// Errorf records a problem
+func (c *Checks) Errorf(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// Check records err as a problem with what, if it isn't nil
+func (c *Checks) Check(what string, err error) {
+	if err != nil {
+		c.Errorf("%s: %v", what, err)
+	}
+}
+
+// Positive checks that the duration given by flag name is positive
+func (c *Checks) Positive(name string, d time.Duration) {
+	if d <= 0 {
+		c.Errorf("--%s must be positive, got %v", name, d)
+	}
+}
+
+// Range checks that the value given by flag name is within [min, max]
+func (c *Checks) Range(name string, v, min, max int) {
+	if v < min || v > max {
+		c.Errorf("--%s must be between %d and %d, got %d", name, min, max, v)
+	}
+}
+
+// Address checks that the address given by flag name, if set, is a valid
+// host:port
+func (c *Checks) Address(name, addr string) {
+	if addr == "" {
+		return
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		c.Errorf("--%s: invalid address %q: %v", name, addr, err)
+	}
+}
+
+// URL checks that the URL given by flag name, if set, is an absolute URL
+func (c *Checks) URL(name, s string) {
+	if s == "" {
+		return
+	}
+	if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+		c.Errorf("--%s: invalid URL %q", name, s)
+	}
+}
+
+// Done logs the problems found. With --strict_init, it exits if there were
+// any.
+func (c *Checks) Done() {
+	for _, p := range c.problems {
+		slog.Warn("Startup problem", "problem", p)
+	}
+	if *strict && len(c.problems) > 0 {
+		slog.Error("Exiting due to --strict_init", "problems", len(c.problems))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,97 @@
+// Package sysinfo reads stats about the host, such as its load average and
+// SoC temperature, for pitemp's --system_metrics and the displays'
+// --system_page.
+package sysinfo
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var disk = flag.String("system_disk", "/", "Filesystem whose usage is reported by --system_metrics and --system_page")
+
+// socTemperature is the thermal zone of the SoC on a Raspberry Pi (and most
+// other single-board computers)
+const socTemperature = "/sys/class/thermal/thermal_zone0/temp"
+
+// Read returns the current stats. The SoC temperature is left unset if it's
+// unavailable, e.g. in a VM.
+func Read() (state.System, error) {
+	var s state.System
+
+	loadavg, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return s, fmt.Errorf("failed to read load average: %w", err)
+	}
+	// e.g. "0.52 0.48 0.40 1/123 4567"
+	fields := strings.Fields(string(loadavg))
+	if len(fields) < 3 {
+		return s, fmt.Errorf("invalid /proc/loadavg %q", loadavg)
+	}
+	for i, p := range []*float64{&s.Load1, &s.Load5, &s.Load15} {
+		if *p, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return s, fmt.Errorf("invalid /proc/loadavg %q", loadavg)
+		}
+	}
+
+	if s.MemoryUsedPercent, err = memoryUsedPercent(); err != nil {
+		return s, err
+	}
+
+	var fs unix.Statfs_t
+	if err := unix.Statfs(*disk, &fs); err != nil {
+		return s, fmt.Errorf("failed to get usage of %s: %w", *disk, err)
+	}
+	// Like df, count space reserved for root as neither used nor available
+	if used, avail := fs.Blocks-fs.Bfree, fs.Bavail; used+avail > 0 {
+		s.DiskUsedPercent = float64(used) / float64(used+avail) * 100
+	}
+
+	if b, err := os.ReadFile(socTemperature); err == nil {
+		if millis, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+			s.SoCTemperature = float64(millis) / 1000
+		}
+	}
+	return s, nil
+}
+
+// memoryUsedPercent returns the percentage of memory in use, not counting
+// what can be reclaimed (e.g. the page cache)
+func memoryUsedPercent() (float64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	defer f.Close()
+
+	// e.g. "MemAvailable:     123456 kB"
+	var total, available float64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, _ = strconv.ParseFloat(fields[1], 64)
+		case "MemAvailable:":
+			available, _ = strconv.ParseFloat(fields[1], 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+	return (total - available) / total * 100, nil
+}
@@ -0,0 +1,38 @@
+// Package tuning configures the Go runtime for long-running processes on
+// small-memory devices such as the Pi Zero. By default, it leaves Go's own
+// settings alone, except for a memory limit for display clients.
+package tuning
+
+import (
+	"flag"
+	"os"
+	"runtime/debug"
+)
+
+// DisplayMemoryLimitMB is the default --memory_limit_mb for display clients,
+// keeping them under ~15MiB on a Pi Zero
+const DisplayMemoryLimitMB = 12
+
+var (
+	gcPercent     = flag.Int("gogc", 100, "GC target percentage (like GOGC), e.g. 50 to trade CPU for memory on a Pi Zero; ignored if GOGC is set in the environment")
+	memoryLimitMB = flag.Int64("memory_limit_mb", 0, "Soft memory limit in MiB (like GOMEMLIMIT), 0 to disable; if unset, 12 for display clients and none for serve, which needs far more with MQTT, Postgres or a long --history_size. Ignored if GOMEMLIMIT is set in the environment")
+)
+
+// Apply applies the GC settings from flags; call it after flag.Parse (and
+// loading the config file). defaultMemoryLimitMB applies unless
+// --memory_limit_mb is set. The GOGC and GOMEMLIMIT environment variables
+// take precedence, as they're already applied by the runtime.
+func Apply(defaultMemoryLimitMB int64) {
+	if _, ok := os.LookupEnv("GOGC"); !ok {
+		debug.SetGCPercent(*gcPercent)
+	}
+	limit := defaultMemoryLimitMB
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "memory_limit_mb" {
+			limit = *memoryLimitMB
+		}
+	})
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); !ok && limit > 0 {
+		debug.SetMemoryLimit(limit << 20)
+	}
+}
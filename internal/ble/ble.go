@@ -0,0 +1,130 @@
+// Package ble broadcasts readings as Bluetooth LE advertisements in the
+// BTHome v2 format (https://bthome.io), which Home Assistant and various
+// phone apps decode out of the box, without any pairing or network setup.
+// It also receives readings advertised by BLE sensors, such as RuuviTags.
+//
+// The controller is driven directly over a raw HCI socket, which requires
+// CAP_NET_ADMIN (or root).
+package ble
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// bthomeUUID is the 16-bit service UUID for BTHome service data
+const bthomeUUID = 0xfcd2
+
+// Advertiser broadcasts readings from an HCI device
+type Advertiser struct {
+	mu       sync.Mutex
+	hci      *hci
+	name     string
+	packetID byte
+	enabled  bool
+}
+
+// Open prepares HCI device dev (e.g. 0 for hci0) for advertising, using
+// name as the advertised local name. Advertising starts with the first
+// Write.
+func Open(dev int, name string, interval time.Duration) (*Advertiser, error) {
+	h, err := openHCI(dev, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Advertising intervals are in units of 0.625ms
+	units := uint16(interval / (625 * time.Microsecond))
+	if units < 0x20 {
+		units = 0x20
+	}
+	params := make([]byte, 15)
+	binary.LittleEndian.PutUint16(params[0:], units) // Minimum interval
+	binary.LittleEndian.PutUint16(params[2:], units) // Maximum interval
+	params[4] = advNonConnInd
+	params[13] = 0x07 // All three advertising channels
+	if err := h.command(ocfSetAdvertisingParameters, params); err != nil {
+		h.close()
+		return nil, fmt.Errorf("failed to set advertising parameters: %w", err)
+	}
+	return &Advertiser{hci: h, name: name}, nil
+}
+
+// advertisingData encodes s as BTHome v2 advertising data
+func (a *Advertiser) advertisingData(s state.State) []byte {
+	serviceData := []byte{
+		0x16, // Service Data - 16-bit UUID
+		bthomeUUID & 0xff, bthomeUUID >> 8,
+		0x40, // BTHome v2, unencrypted, regular interval
+		0x00, a.packetID,
+		0x02, 0, 0, // Temperature, 0.01°C
+		0x03, 0, 0, // Humidity, 0.01%
+	}
+	binary.LittleEndian.PutUint16(serviceData[7:], uint16(int16(math.Round(float64(s.Temperature)*100))))
+	binary.LittleEndian.PutUint16(serviceData[10:], uint16(math.Round(float64(s.Humidity)*100)))
+
+	data := []byte{
+		2, 0x01, 0x06, // Flags: LE General Discoverable, BR/EDR not supported
+		byte(len(serviceData)),
+	}
+	data = append(data, serviceData...)
+
+	// Advertising data is limited to 31 bytes; truncate the name to fit
+	if room := 31 - len(data) - 2; room > 0 && a.name != "" {
+		name, nameType := a.name, byte(0x09) // Complete Local Name
+		if len(name) > room {
+			name, nameType = name[:room], 0x08 // Shortened Local Name
+		}
+		data = append(data, byte(len(name)+1), nameType)
+		data = append(data, name...)
+	}
+	return data
+}
+
+// Write starts advertising s, replacing any previously advertised reading
+func (a *Advertiser) Write(s state.State) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Receivers use the packet ID to tell new readings from repeated
+	// advertisements of the same one
+	a.packetID++
+
+	data := a.advertisingData(s)
+	params := make([]byte, 32)
+	params[0] = byte(len(data))
+	copy(params[1:], data)
+	if err := a.hci.command(ocfSetAdvertisingData, params); err != nil {
+		return fmt.Errorf("failed to set advertising data: %w", err)
+	}
+
+	// Enabling advertising when it's already enabled is an error on some
+	// controllers; new data is picked up regardless.
+	if !a.enabled {
+		if err := a.hci.command(ocfSetAdvertisingEnable, []byte{1}); err != nil {
+			return fmt.Errorf("failed to enable advertising: %w", err)
+		}
+		a.enabled = true
+	}
+	return nil
+}
+
+// Close stops advertising and closes the HCI socket
+func (a *Advertiser) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var err error
+	if a.enabled {
+		err = a.hci.command(ocfSetAdvertisingEnable, []byte{0})
+	}
+	if closeErr := a.hci.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package ble
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	hciCommandPkt = 0x01
+	hciEventPkt   = 0x04
+
+	evtCmdComplete = 0x0e
+	evtCmdStatus   = 0x0f
+	evtLEMeta      = 0x3e
+
+	// hciFilter is the HCI_FILTER socket option, missing from x/sys/unix
+	hciFilter = 2
+
+	ogfLE = 0x08
+
+	ocfSetAdvertisingParameters = 0x0006
+	ocfSetAdvertisingData       = 0x0008
+	ocfSetAdvertisingEnable     = 0x000a
+	ocfSetScanParameters        = 0x000b
+	ocfSetScanEnable            = 0x000c
+
+	advNonConnInd = 0x03
+
+	// commandTimeout bounds how long to wait for the controller to
+	// acknowledge a command
+	commandTimeout = 2 * time.Second
+)
+
+// hci is a raw HCI socket bound to a single device
+type hci struct {
+	fd int
+}
+
+// openHCI opens HCI device dev (e.g. 0 for hci0). Only command completion
+// events are received, plus LE meta events (such as advertising reports) if
+// leMeta is set.
+func openHCI(dev int, leMeta bool) (*hci, error) {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_HCI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open HCI socket: %w", err)
+	}
+	h := &hci{fd}
+
+	if err := h.setup(dev, leMeta); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *hci) setup(dev int, leMeta bool) error {
+	if err := unix.Bind(h.fd, &unix.SockaddrHCI{Dev: uint16(dev), Channel: unix.HCI_CHANNEL_RAW}); err != nil {
+		return fmt.Errorf("failed to bind to hci%d: %w", dev, err)
+	}
+
+	// struct hci_filter { u32 type_mask; u32 event_mask[2]; u16 opcode; }
+	var filter [14]byte
+	binary.LittleEndian.PutUint32(filter[0:], 1<<hciEventPkt)
+	binary.LittleEndian.PutUint32(filter[4:], 1<<evtCmdComplete|1<<evtCmdStatus)
+	if leMeta {
+		binary.LittleEndian.PutUint32(filter[8:], 1<<(evtLEMeta-32))
+	}
+	if err := unix.SetsockoptString(h.fd, unix.SOL_HCI, hciFilter, string(filter[:])); err != nil {
+		return fmt.Errorf("failed to set HCI filter: %w", err)
+	}
+	tv := unix.NsecToTimeval(commandTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(h.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("failed to set HCI socket timeout: %w", err)
+	}
+	return nil
+}
+
+// readEvent reads a single HCI event, returning its code and parameters. It
+// returns errTimeout if no event arrives within commandTimeout.
+func (h *hci) readEvent(buf []byte) (code byte, params []byte, err error) {
+	for {
+		n, err := unix.Read(h.fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			return 0, nil, errTimeout
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if n >= 3 && buf[0] == hciEventPkt {
+			return buf[1], buf[3:n], nil
+		}
+	}
+}
+
+var errTimeout = errors.New("timed out waiting for HCI event")
+
+// command sends an LE controller command and waits for its completion
+func (h *hci) command(ocf uint16, params []byte) error {
+	opcode := uint16(ogfLE<<10 | ocf)
+	pkt := []byte{hciCommandPkt, byte(opcode), byte(opcode >> 8), byte(len(params))}
+	pkt = append(pkt, params...)
+	if _, err := unix.Write(h.fd, pkt); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 260)
+	deadline := time.Now().Add(commandTimeout)
+	for time.Now().Before(deadline) {
+		code, evt, err := h.readEvent(buf)
+		if err != nil {
+			return fmt.Errorf("no response from controller: %w", err)
+		}
+		switch code {
+		case evtCmdComplete:
+			// Num_HCI_Command_Packets, Command_Opcode, Status
+			if len(evt) < 4 || binary.LittleEndian.Uint16(evt[1:]) != opcode {
+				continue
+			}
+			if status := evt[3]; status != 0 {
+				return fmt.Errorf("controller returned error 0x%02x", status)
+			}
+			return nil
+		case evtCmdStatus:
+			// Status, Num_HCI_Command_Packets, Command_Opcode
+			if len(evt) < 4 || binary.LittleEndian.Uint16(evt[2:]) != opcode {
+				continue
+			}
+			if status := evt[0]; status != 0 {
+				return fmt.Errorf("controller returned error 0x%02x", status)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no response from controller: %w", errTimeout)
+}
+
+func (h *hci) close() error {
+	return unix.Close(h.fd)
+}
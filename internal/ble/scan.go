@@ -0,0 +1,118 @@
+package ble
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+const evtLEAdvertisingReport = 0x02
+
+// Reading is a reading decoded from a BLE sensor's advertisement
+type Reading struct {
+	// Addr is the sensor's address, e.g. "AA:BB:CC:DD:EE:FF"
+	Addr string
+
+	// Model is the sensor type, e.g. "ruuvitag"
+	Model string
+
+	Temperature, Humidity float32
+}
+
+// Scan passively scans for advertisements from supported sensors on HCI
+// device dev, calling f with each decoded reading, until ctx is cancelled.
+func Scan(ctx context.Context, dev int, f func(Reading)) error {
+	h, err := openHCI(dev, true)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+
+	// Passive scanning, 10ms interval and window (in units of 0.625ms)
+	params := []byte{0x00, 0x10, 0x00, 0x10, 0x00, 0x00, 0x00}
+	if err := h.command(ocfSetScanParameters, params); err != nil {
+		return fmt.Errorf("failed to set scan parameters: %w", err)
+	}
+
+	// Duplicate filtering would hide updated readings from the same sensor
+	if err := h.command(ocfSetScanEnable, []byte{1, 0}); err != nil {
+		return fmt.Errorf("failed to enable scanning: %w", err)
+	}
+	defer func() {
+		if err := h.command(ocfSetScanEnable, []byte{0, 0}); err != nil {
+			slog.Error("Failed to disable BLE scanning", "err", err)
+		}
+	}()
+
+	buf := make([]byte, 260)
+	for ctx.Err() == nil {
+		code, evt, err := h.readEvent(buf)
+		if errors.Is(err, errTimeout) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read HCI event: %w", err)
+		}
+		if code != evtLEMeta || len(evt) < 2 || evt[0] != evtLEAdvertisingReport {
+			continue
+		}
+		parseAdvertisingReports(evt[1:], f)
+	}
+	return nil
+}
+
+// parseAdvertisingReports parses the reports in an LE Advertising Report
+// event, calling f for any from supported sensors.
+func parseAdvertisingReports(evt []byte, f func(Reading)) {
+	numReports := int(evt[0])
+	evt = evt[1:]
+
+	// Each report is Event_Type, Address_Type, Address (6 bytes, little
+	// endian), Data_Length, Data, RSSI
+	for i := 0; i < numReports && len(evt) >= 9; i++ {
+		addr := evt[2:8]
+		dataLen := int(evt[8])
+		if len(evt) < 9+dataLen+1 {
+			return
+		}
+		data := evt[9 : 9+dataLen]
+		evt = evt[9+dataLen+1:]
+
+		if r, ok := decode(data); ok {
+			r.Addr = fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X",
+				addr[5], addr[4], addr[3], addr[2], addr[1], addr[0])
+			f(r)
+		}
+	}
+}
+
+const (
+	adServiceData16    = 0x16
+	adManufacturerData = 0xff
+)
+
+// decode decodes advertising data from supported sensors
+func decode(data []byte) (Reading, bool) {
+	for len(data) >= 2 {
+		n := int(data[0])
+		if n == 0 || len(data) < n+1 {
+			break
+		}
+		adType, ad := data[1], data[2:n+1]
+		data = data[n+1:]
+
+		switch {
+		case adType == adManufacturerData && len(ad) >= 2 && binary.LittleEndian.Uint16(ad) == ruuviCompanyID:
+			if r, ok := decodeRuuvi(ad[2:]); ok {
+				return r, true
+			}
+		case adType == adServiceData16 && len(ad) >= 2 && binary.LittleEndian.Uint16(ad) == environmentalSensingUUID:
+			if r, ok := decodeXiaomi(ad[2:]); ok {
+				return r, true
+			}
+		}
+	}
+	return Reading{}, false
+}
@@ -0,0 +1,63 @@
+package ble
+
+import "encoding/binary"
+
+const ruuviCompanyID = 0x0499
+
+// decodeRuuvi decodes RuuviTag manufacturer data in data formats 3 (RAWv1)
+// and 5 (RAWv2). See https://docs.ruuvi.com/communication/bluetooth-advertisements
+func decodeRuuvi(d []byte) (Reading, bool) {
+	if len(d) < 1 {
+		return Reading{}, false
+	}
+	r := Reading{Model: "ruuvitag"}
+
+	switch d[0] {
+	case 3:
+		if len(d) < 14 {
+			return Reading{}, false
+		}
+		r.Humidity = float32(d[1]) / 2
+		r.Temperature = float32(d[2]&0x7f) + float32(d[3])/100
+		if d[2]&0x80 != 0 {
+			r.Temperature = -r.Temperature
+		}
+	case 5:
+		if len(d) < 24 {
+			return Reading{}, false
+		}
+		temp, humidity := int16(binary.BigEndian.Uint16(d[1:])), binary.BigEndian.Uint16(d[3:])
+		// These values indicate an invalid reading
+		if temp == -0x8000 || humidity == 0xffff {
+			return Reading{}, false
+		}
+		r.Temperature = float32(temp) * 0.005
+		r.Humidity = float32(humidity) * 0.0025
+	default:
+		return Reading{}, false
+	}
+	return r, true
+}
+
+// environmentalSensingUUID is the 16-bit service UUID used by custom
+// firmware for Xiaomi thermometers
+const environmentalSensingUUID = 0x181a
+
+// decodeXiaomi decodes service data from Xiaomi LYWSD03MMC thermometers
+// running the ATC1441 or pvvx custom firmware; the stock firmware encrypts
+// its advertisements. See https://github.com/pvvx/ATC_MiThermometer
+func decodeXiaomi(d []byte) (Reading, bool) {
+	r := Reading{Model: "lywsd03mmc"}
+
+	switch len(d) {
+	case 13: // ATC1441
+		r.Temperature = float32(int16(binary.BigEndian.Uint16(d[6:]))) / 10
+		r.Humidity = float32(d[8])
+	case 15: // pvvx
+		r.Temperature = float32(int16(binary.LittleEndian.Uint16(d[6:]))) / 100
+		r.Humidity = float32(binary.LittleEndian.Uint16(d[8:])) / 100
+	default:
+		return Reading{}, false
+	}
+	return r, true
+}
@@ -0,0 +1,48 @@
+// Package clock tells whether the system clock can be trusted. A Pi has no
+// battery-backed clock of its own, so until NTP syncs it after boot, its
+// time is whatever fake-hwclock saved at shutdown (or 1970); readings
+// timestamped then, and ages computed from them, are meaningless. An I²C
+// RTC, such as a DS3231, can set the clock at boot instead.
+package clock
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/unix"
+)
+
+// known is 1 once the clock is known to be valid, regardless of NTP
+var known int32
+
+// MarkValid records that the clock is valid even if it isn't synchronized,
+// e.g. because it was set from an RTC
+func MarkValid() {
+	atomic.StoreInt32(&known, 1)
+}
+
+// Synced returns true if the kernel considers the clock synchronized, e.g.
+// by NTP
+func Synced() bool {
+	state, err := unix.Adjtimex(&unix.Timex{})
+	return err == nil && state != unix.TIME_ERROR
+}
+
+// Valid returns true if the clock can be trusted: it's synchronized, or
+// was marked valid
+func Valid() bool {
+	return atomic.LoadInt32(&known) == 1 || Synced()
+}
+
+// SyncedGauge is 1 while the clock is synchronized, and 0 otherwise, e.g.
+// after a power outage on a Pi without an RTC; programs register it along
+// with their other metrics
+var SyncedGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "clock_synchronized",
+	Help: "Whether the kernel considers the system clock synchronized, e.g. by NTP (1) or not (0)",
+}, func() float64 {
+	if Synced() {
+		return 1
+	}
+	return 0
+})
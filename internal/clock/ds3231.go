@@ -0,0 +1,90 @@
+package clock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+)
+
+// ds3231Addr is the fixed I²C address of the DS3231
+const ds3231Addr = 0x68
+
+// ds3231Century is set in the month register for years from 2100
+const ds3231Century = 0x80
+
+// ReadDS3231 reads the time from a DS3231 RTC on I²C bus. The DS3231 keeps
+// UTC, as set by WriteDS3231.
+func ReadDS3231(bus int) (time.Time, error) {
+	c, err := i2c.NewI2C(ds3231Addr, bus)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to open I2C: %w", err)
+	}
+	defer c.Close()
+
+	// Seconds, minutes, hours, weekday, day, month and year, in BCD
+	b, _, err := c.ReadRegBytes(0x00, 7)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read DS3231: %w", err)
+	}
+	if b[2]&0x40 != 0 {
+		return time.Time{}, fmt.Errorf("DS3231 is in 12-hour mode, which is unsupported")
+	}
+	year := 2000 + fromBCD(b[6])
+	if b[5]&ds3231Century != 0 {
+		year += 100
+	}
+	t := time.Date(year, time.Month(fromBCD(b[5]&0x1f)), fromBCD(b[4]),
+		fromBCD(b[2]&0x3f), fromBCD(b[1]), fromBCD(b[0]&0x7f), 0, time.UTC)
+
+	// The oscillator stop flag means the time was lost, e.g. to a flat
+	// battery
+	status, err := c.ReadRegU8(0x0f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read DS3231 status: %w", err)
+	}
+	if status&0x80 != 0 {
+		return time.Time{}, fmt.Errorf("DS3231 oscillator stopped, its time %v is invalid", t)
+	}
+	return t, nil
+}
+
+// WriteDS3231 sets a DS3231 RTC on I²C bus to t, and clears its oscillator
+// stop flag
+func WriteDS3231(bus int, t time.Time) error {
+	c, err := i2c.NewI2C(ds3231Addr, bus)
+	if err != nil {
+		return fmt.Errorf("failed to open I2C: %w", err)
+	}
+	defer c.Close()
+
+	t = t.UTC()
+	month := toBCD(int(t.Month()))
+	if t.Year() >= 2100 {
+		month |= ds3231Century
+	}
+	b := []byte{0x00, // Register address
+		toBCD(t.Second()), toBCD(t.Minute()), toBCD(t.Hour()),
+		byte(t.Weekday()) + 1, toBCD(t.Day()), month, toBCD(t.Year() % 100),
+	}
+	if _, err := c.WriteBytes(b); err != nil {
+		return fmt.Errorf("failed to write DS3231: %w", err)
+	}
+
+	status, err := c.ReadRegU8(0x0f)
+	if err != nil {
+		return fmt.Errorf("failed to read DS3231 status: %w", err)
+	}
+	if err := c.WriteRegU8(0x0f, status&^0x80); err != nil {
+		return fmt.Errorf("failed to clear DS3231 oscillator stop flag: %w", err)
+	}
+	return nil
+}
+
+func fromBCD(b byte) int {
+	return int(b>>4)*10 + int(b&0x0f)
+}
+
+func toBCD(n int) byte {
+	return byte(n/10)<<4 | byte(n%10)
+}
@@ -0,0 +1,234 @@
+// Package coap implements a minimal CoAP (RFC 7252) server over UDP,
+// supporting GET requests on fixed resources. This is enough for
+// microcontroller-class clients to fetch readings without an HTTP stack.
+package coap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// Content formats, see RFC 7252 section 12.3
+const (
+	TextPlain       = 0
+	ApplicationJSON = 50
+)
+
+const (
+	typeCON = 0
+	typeNON = 1
+	typeACK = 2
+	typeRST = 3
+
+	codeEmpty            = 0x00
+	codeGET              = 0x01
+	codeContent          = 0x45 // 2.05
+	codeBadRequest       = 0x80 // 4.00
+	codeNotFound         = 0x84 // 4.04
+	codeMethodNotAllowed = 0x85 // 4.05
+
+	optionURIPath       = 11
+	optionContentFormat = 12
+
+	payloadMarker = 0xff
+)
+
+// Resource returns the current representation of a resource, and its content
+// format.
+type Resource func() (payload []byte, contentFormat uint16)
+
+// ListenAndServe serves resources (keyed by path, e.g. "state" or
+// "sensors/temperature") on the UDP address addr until ctx is cancelled.
+func ListenAndServe(ctx context.Context, addr string, resources map[string]Resource) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	var messageID uint16
+	buf := make([]byte, 1152) // Maximum recommended CoAP message size
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read: %w", err)
+		}
+
+		req, err := parse(buf[:n])
+		if err != nil {
+			// Malformed messages are silently ignored, per RFC 7252 4.2
+			continue
+		}
+
+		messageID++
+		resp := handle(req, resources, messageID)
+		if resp == nil {
+			continue
+		}
+		if _, err := conn.WriteTo(resp.marshal(), peer); err != nil {
+			slog.Warn("CoAP: failed to respond", "peer", peer.String(), "err", err)
+		}
+	}
+}
+
+func handle(req *message, resources map[string]Resource, nextID uint16) *message {
+	if req.typ == typeACK || req.typ == typeRST {
+		return nil
+	}
+
+	resp := &message{token: req.token}
+	if req.typ == typeCON {
+		resp.typ = typeACK
+		resp.messageID = req.messageID
+	} else {
+		resp.typ = typeNON
+		resp.messageID = nextID
+	}
+
+	switch {
+	case req.code == codeEmpty:
+		// "CoAP ping"
+		return &message{typ: typeRST, messageID: req.messageID}
+	case req.code != codeGET:
+		resp.code = codeMethodNotAllowed
+	default:
+		resource, ok := resources[req.path()]
+		if !ok {
+			resp.code = codeNotFound
+			break
+		}
+		resp.code = codeContent
+		resp.payload, resp.contentFormat = resource()
+		resp.hasContentFormat = true
+	}
+	return resp
+}
+
+type option struct {
+	number uint16
+	value  []byte
+}
+
+type message struct {
+	typ       byte
+	code      byte
+	messageID uint16
+	token     []byte
+	options   []option
+	payload   []byte
+
+	// Only used when marshalling
+	contentFormat    uint16
+	hasContentFormat bool
+}
+
+func (m *message) path() string {
+	var parts []string
+	for _, o := range m.options {
+		if o.number == optionURIPath {
+			parts = append(parts, string(o.value))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+var errMalformed = errors.New("malformed CoAP message")
+
+func parse(b []byte) (*message, error) {
+	if len(b) < 4 || b[0]>>6 != 1 {
+		return nil, errMalformed
+	}
+	tkl := int(b[0] & 0x0f)
+	if tkl > 8 || len(b) < 4+tkl {
+		return nil, errMalformed
+	}
+	m := &message{
+		typ:       (b[0] >> 4) & 0x03,
+		code:      b[1],
+		messageID: binary.BigEndian.Uint16(b[2:4]),
+		token:     append([]byte(nil), b[4:4+tkl]...),
+	}
+
+	b = b[4+tkl:]
+	var number uint16
+	for len(b) > 0 {
+		if b[0] == payloadMarker {
+			m.payload = b[1:]
+			break
+		}
+		delta, length := int(b[0]>>4), int(b[0]&0x0f)
+		b = b[1:]
+		var err error
+		if delta, b, err = extended(delta, b); err != nil {
+			return nil, err
+		}
+		if length, b, err = extended(length, b); err != nil {
+			return nil, err
+		}
+		if len(b) < length {
+			return nil, errMalformed
+		}
+		number += uint16(delta)
+		m.options = append(m.options, option{number, b[:length]})
+		b = b[length:]
+	}
+	return m, nil
+}
+
+// extended decodes an option delta or length nibble, consuming any extended
+// bytes from b
+func extended(v int, b []byte) (int, []byte, error) {
+	switch v {
+	case 13:
+		if len(b) < 1 {
+			return 0, nil, errMalformed
+		}
+		return int(b[0]) + 13, b[1:], nil
+	case 14:
+		if len(b) < 2 {
+			return 0, nil, errMalformed
+		}
+		return int(binary.BigEndian.Uint16(b)) + 269, b[2:], nil
+	case 15:
+		return 0, nil, errMalformed
+	}
+	return v, b, nil
+}
+
+func (m *message) marshal() []byte {
+	b := []byte{1<<6 | m.typ<<4 | byte(len(m.token)), m.code, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], m.messageID)
+	b = append(b, m.token...)
+
+	if m.hasContentFormat {
+		// Content-Format is the only option we send; the delta from 0 fits
+		// in the nibble, and the value is encoded as a minimal uint.
+		var value []byte
+		switch {
+		case m.contentFormat == 0:
+		case m.contentFormat < 256:
+			value = []byte{byte(m.contentFormat)}
+		default:
+			value = []byte{byte(m.contentFormat >> 8), byte(m.contentFormat)}
+		}
+		b = append(b, optionContentFormat<<4|byte(len(value)))
+		b = append(b, value...)
+	}
+
+	if len(m.payload) > 0 {
+		b = append(b, payloadMarker)
+		b = append(b, m.payload...)
+	}
+	return b
+}
@@ -0,0 +1,26 @@
+package cron
+
+import (
+	"context"
+	"time"
+)
+
+// Window is a recurring period from one schedule to another, e.g. nights
+// from "0 23 * * *" to "30 6 * * *"
+type Window struct {
+	Start, End Schedule
+}
+
+// Contains reports whether t is within the window, i.e. whether the window
+// is due to end before it's next due to start
+func (w Window) Contains(t time.Time) bool {
+	nextEnd, nextStart := w.End.Next(t), w.Start.Next(t)
+	return !nextEnd.IsZero() && (nextStart.IsZero() || nextEnd.Before(nextStart))
+}
+
+// Run calls f(true) whenever the window starts and f(false) whenever it
+// ends, until ctx is cancelled
+func (w Window) Run(ctx context.Context, f func(in bool)) {
+	go Run(ctx, w.Start, func() { f(true) })
+	Run(ctx, w.End, func() { f(false) })
+}
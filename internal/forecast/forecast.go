@@ -0,0 +1,61 @@
+// Package forecast fetches the outdoor weather and tomorrow's forecast for
+// the displays' forecast page, from Open-Meteo (which needs no API key) or
+// OpenWeatherMap.
+package forecast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/client"
+)
+
+// Provider fetches the weather for a location
+type Provider interface {
+	Fetch(ctx context.Context) (client.Weather, error)
+}
+
+// httpClient is used by all providers
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// getJSON gets endpoint with query params, decoding the JSON response into
+// v. The query may hold an API key, so it's left out of errors.
+func getJSON(ctx context.Context, endpoint string, params url.Values, v interface{}) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = ""
+	shown := u.Redacted()
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("invalid request to %s", shown)
+	}
+	req.Header.Set("User-Agent", "pitemp")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("GET %s: %w", shown, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", shown, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", shown, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package forecast
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenWeatherMapErrorsHideKey(t *testing.T) {
+	const key = "secret-api-key"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct{ name, url string }{
+		{"error status", ts.URL},
+		{"unreachable", "http://127.0.0.1:1/forecast"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := OpenWeatherMap{URL: tc.url, Key: key, Latitude: 51.5, Longitude: -0.12}
+			_, err := o.Fetch(context.Background())
+			if err == nil {
+				t.Fatal("Fetch succeeded, want an error")
+			}
+			if strings.Contains(err.Error(), key) {
+				t.Errorf("Error %q includes the API key", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/client"
+)
+
+// OpenMeteoURL is the default endpoint for Open-Meteo forecasts
+const OpenMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// OpenMeteo fetches forecasts from Open-Meteo, https://open-meteo.com
+type OpenMeteo struct {
+	// URL defaults to OpenMeteoURL
+	URL string
+
+	Latitude, Longitude float64
+}
+
+// Fetch returns the current temperature and tomorrow's forecast, where
+// tomorrow is in the location's time zone
+func (o *OpenMeteo) Fetch(ctx context.Context) (client.Weather, error) {
+	params := url.Values{
+		"latitude":      {strconv.FormatFloat(o.Latitude, 'f', -1, 64)},
+		"longitude":     {strconv.FormatFloat(o.Longitude, 'f', -1, 64)},
+		"current":       {"temperature_2m"},
+		"daily":         {"temperature_2m_max,temperature_2m_min,precipitation_probability_max"},
+		"timezone":      {"auto"},
+		"forecast_days": {"2"},
+	}
+	u := o.URL
+	if u == "" {
+		u = OpenMeteoURL
+	}
+
+	// e.g. {"current": {"temperature_2m": 8.1}, "daily": {"time":
+	// ["2024-03-05", "2024-03-06"], "temperature_2m_max": [12.3, 11.0],
+	// ...}}, where the second day is tomorrow
+	var resp struct {
+		Current struct {
+			Temperature *float64 `json:"temperature_2m"`
+		} `json:"current"`
+		Daily struct {
+			Max        []float64 `json:"temperature_2m_max"`
+			Min        []float64 `json:"temperature_2m_min"`
+			RainChance []float64 `json:"precipitation_probability_max"`
+		} `json:"daily"`
+	}
+	if err := getJSON(ctx, u, params, &resp); err != nil {
+		return client.Weather{}, err
+	}
+	d := resp.Daily
+	if resp.Current.Temperature == nil || len(d.Max) < 2 || len(d.Min) < 2 || len(d.RainChance) < 2 {
+		return client.Weather{}, fmt.Errorf("incomplete forecast from %s", u)
+	}
+	return client.Weather{
+		Temperature: *resp.Current.Temperature,
+		High:        d.Max[1],
+		Low:         d.Min[1],
+		RainChance:  d.RainChance[1],
+		FetchedAt:   time.Now(),
+	}, nil
+}
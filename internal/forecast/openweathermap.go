@@ -0,0 +1,88 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/client"
+)
+
+// OpenWeatherMapURL is the default endpoint for OpenWeatherMap's 5 day / 3
+// hour forecasts, available with a free API key
+const OpenWeatherMapURL = "https://api.openweathermap.org/data/2.5/forecast"
+
+// OpenWeatherMap fetches forecasts from OpenWeatherMap,
+// https://openweathermap.org
+type OpenWeatherMap struct {
+	// URL defaults to OpenWeatherMapURL
+	URL string
+
+	Key                 string
+	Latitude, Longitude float64
+}
+
+// Fetch returns tomorrow's forecast, where tomorrow is in the location's
+// time zone. The forecast only comes in 3-hour steps, so the current
+// temperature is that of the nearest one.
+func (o *OpenWeatherMap) Fetch(ctx context.Context) (client.Weather, error) {
+	params := url.Values{
+		"lat":   {strconv.FormatFloat(o.Latitude, 'f', -1, 64)},
+		"lon":   {strconv.FormatFloat(o.Longitude, 'f', -1, 64)},
+		"appid": {o.Key},
+		"units": {"metric"},
+	}
+	u := o.URL
+	if u == "" {
+		u = OpenWeatherMapURL
+	}
+
+	// e.g. {"list": [{"dt": 1709647200, "main": {"temp": 8.1, "temp_min":
+	// 7.5, "temp_max": 8.4}, "pop": 0.2}, ...], "city": {"timezone": 3600}}
+	var resp struct {
+		List []struct {
+			Time int64 `json:"dt"`
+			Main struct {
+				Temperature float64 `json:"temp"`
+				Min         float64 `json:"temp_min"`
+				Max         float64 `json:"temp_max"`
+			} `json:"main"`
+			RainChance float64 `json:"pop"`
+		} `json:"list"`
+		City struct {
+			// Timezone is the location's offset from UTC, in seconds
+			Timezone int `json:"timezone"`
+		} `json:"city"`
+	}
+	if err := getJSON(ctx, u, params, &resp); err != nil {
+		return client.Weather{}, err
+	}
+	if len(resp.List) == 0 {
+		return client.Weather{}, fmt.Errorf("empty forecast from %s", u)
+	}
+
+	now := time.Now()
+	zone := time.FixedZone("", resp.City.Timezone)
+	tomorrow := now.In(zone).AddDate(0, 0, 1).Format("2006-01-02")
+	w := client.Weather{
+		Temperature: resp.List[0].Main.Temperature,
+		High:        math.Inf(-1),
+		Low:         math.Inf(1),
+		FetchedAt:   now,
+	}
+	for _, step := range resp.List {
+		if time.Unix(step.Time, 0).In(zone).Format("2006-01-02") != tomorrow {
+			continue
+		}
+		w.High = math.Max(w.High, step.Main.Max)
+		w.Low = math.Min(w.Low, step.Main.Min)
+		w.RainChance = math.Max(w.RainChance, step.RainChance*100)
+	}
+	if math.IsInf(w.High, 0) {
+		return client.Weather{}, fmt.Errorf("no forecast for tomorrow from %s", u)
+	}
+	return w, nil
+}
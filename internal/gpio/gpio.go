@@ -0,0 +1,141 @@
+// Package gpio drives GPIO pins through the sysfs interface
+// (/sys/class/gpio), e.g. to switch a relay or read a motion sensor.
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// sysfs is the root of the sysfs GPIO interface
+const sysfs = "/sys/class/gpio"
+
+// Output is a GPIO pin configured as an output
+type Output struct {
+	pin   int
+	value *os.File
+}
+
+// OpenOutput exports pin and configures it as an output, initially off. If
+// activeLow is set, "on" drives the pin low, as many relay boards expect.
+func OpenOutput(pin int, activeLow bool) (*Output, error) {
+	dir, err := export(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	activeLowValue := "0"
+	if activeLow {
+		activeLowValue = "1"
+	}
+	// Permissions of newly exported pins are set asynchronously by udev
+	for i := 0; i < 10; i++ {
+		if err = write(filepath.Join(dir, "active_low"), activeLowValue); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure GPIO %d: %w", pin, err)
+	}
+	// Setting the direction along with the initial value (which is raw,
+	// ignoring active_low) makes it atomic, so that the relay doesn't glitch
+	// on
+	initial := "low"
+	if activeLow {
+		initial = "high"
+	}
+	if err := write(filepath.Join(dir, "direction"), initial); err != nil {
+		return nil, fmt.Errorf("failed to configure GPIO %d: %w", pin, err)
+	}
+
+	value, err := os.OpenFile(filepath.Join(dir, "value"), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GPIO %d: %w", pin, err)
+	}
+	return &Output{pin: pin, value: value}, nil
+}
+
+// export exports pin, if it isn't already, returning its directory
+func export(pin int) (string, error) {
+	dir := filepath.Join(sysfs, fmt.Sprintf("gpio%d", pin))
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := write(filepath.Join(sysfs, "export"), strconv.Itoa(pin)); err != nil {
+			return "", fmt.Errorf("failed to export GPIO %d: %w", pin, err)
+		}
+	}
+	return dir, nil
+}
+
+func write(path, value string) error {
+	return os.WriteFile(path, []byte(value), 0)
+}
+
+// Set switches the output on or off
+func (o *Output) Set(on bool) error {
+	v := "0"
+	if on {
+		v = "1"
+	}
+	if _, err := o.value.WriteAt([]byte(v), 0); err != nil {
+		return fmt.Errorf("failed to set GPIO %d: %w", o.pin, err)
+	}
+	return nil
+}
+
+// Close switches the output off, and closes it
+func (o *Output) Close() error {
+	err := o.Set(false)
+	if closeErr := o.value.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Input is a GPIO pin configured as an input
+type Input struct {
+	pin   int
+	value *os.File
+}
+
+// OpenInput exports pin and configures it as an input
+func OpenInput(pin int) (*Input, error) {
+	dir, err := export(pin)
+	if err != nil {
+		return nil, err
+	}
+	// As in OpenOutput, udev may not have set the permissions yet
+	for i := 0; i < 10; i++ {
+		if err = write(filepath.Join(dir, "direction"), "in"); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure GPIO %d: %w", pin, err)
+	}
+
+	value, err := os.Open(filepath.Join(dir, "value"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GPIO %d: %w", pin, err)
+	}
+	return &Input{pin: pin, value: value}, nil
+}
+
+// Get returns whether the input is high
+func (i *Input) Get() (bool, error) {
+	b := make([]byte, 1)
+	if _, err := i.value.ReadAt(b, 0); err != nil {
+		return false, fmt.Errorf("failed to read GPIO %d: %w", i.pin, err)
+	}
+	return b[0] == '1', nil
+}
+
+// Close closes the input
+func (i *Input) Close() error {
+	return i.value.Close()
+}
@@ -0,0 +1,59 @@
+// Package history keeps an in-memory record of recent readings.
+package history
+
+import (
+	"sync"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+var history = struct {
+	mu sync.RWMutex
+
+	readings []state.State
+	size     int
+}{size: 1440}
+
+// SetSize sets the maximum number of readings kept, discarding the oldest
+// readings if there are too many; thread-safe
+func SetSize(n int) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.size = n
+	trim()
+}
+
+// Add a reading; thread-safe
+func Add(s state.State) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.readings = append(history.readings, s)
+	trim()
+}
+
+// Get all readings, oldest first; thread-safe
+func Get() []state.State {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+
+	return append([]state.State(nil), history.readings...)
+}
+
+// Set replaces all readings, e.g. when restoring a snapshot; thread-safe
+func Set(readings []state.State) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.readings = append([]state.State(nil), readings...)
+	trim()
+}
+
+// trim discards the oldest readings beyond the maximum size; history.mu
+// must be held
+func trim() {
+	if extra := len(history.readings) - history.size; extra > 0 {
+		history.readings = append(history.readings[:0], history.readings[extra:]...)
+	}
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Query returns the readings taken between from and to (inclusive), oldest
+// first; a zero from or to leaves that end open. If step is positive, the
+// readings are downsampled to one per step, starting at from (or the oldest
+// reading): the last reading in each step, with the values of its readings
+// averaged over the step. If limit is positive, step is widened as needed
+// for at most limit readings to be returned. Thread-safe.
+func Query(from, to time.Time, step time.Duration, limit int) []state.State {
+	history.mu.RLock()
+	var readings []state.State
+	for _, s := range history.readings {
+		t := s.LastSensorUpdate
+		if (!from.IsZero() && t.Before(from)) || (!to.IsZero() && t.After(to)) {
+			continue
+		}
+		readings = append(readings, s)
+	}
+	history.mu.RUnlock()
+
+	if len(readings) == 0 {
+		return nil
+	}
+	if from.IsZero() {
+		from = readings[0].LastSensorUpdate
+	}
+	if limit > 0 {
+		end := to
+		if end.IsZero() {
+			end = readings[len(readings)-1].LastSensorUpdate
+		}
+		// Just over a limit'th of the time, so that the last reading
+		// doesn't start a step of its own past the limit
+		if minStep := end.Sub(from)/time.Duration(limit) + 1; step < minStep {
+			step = minStep
+		}
+	}
+	if step <= 0 {
+		return readings
+	}
+
+	var result []state.State
+	var bucket []state.State
+	current := int64(-1)
+	for _, s := range readings {
+		i := int64(s.LastSensorUpdate.Sub(from) / step)
+		if i != current && len(bucket) > 0 {
+			result = append(result, average(bucket))
+			bucket = bucket[:0]
+		}
+		current = i
+		bucket = append(bucket, s)
+	}
+	return append(result, average(bucket))
+}
+
+// average returns the last of states, with the values of its readings
+// averaged over all of states having them
+func average(states []state.State) state.State {
+	type sum struct {
+		total float64
+		n     int
+		last  state.Reading
+	}
+	sums := map[string]*sum{}
+	var names []string
+	for _, s := range states {
+		for _, name := range readingNames(s) {
+			r, _ := s.Reading(name)
+			sm, ok := sums[name]
+			if !ok {
+				sm = &sum{}
+				sums[name] = sm
+				names = append(names, name)
+			}
+			sm.total += float64(r.Value)
+			sm.n++
+			sm.last = r
+		}
+	}
+
+	result := states[len(states)-1]
+	for _, name := range names {
+		sm := sums[name]
+		r := sm.last
+		r.Value = float32(sm.total / float64(sm.n))
+		result.SetReading(name, r)
+	}
+	return result
+}
+
+// readingNames returns the names of the readings of s, including those of
+// states built using only the legacy fields
+func readingNames(s state.State) []string {
+	var names []string
+	if s.Readings == nil {
+		for _, name := range []string{"temperature", "humidity"} {
+			if _, ok := s.Reading(name); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	for name := range s.Readings {
+		names = append(names, name)
+	}
+	return names
+}
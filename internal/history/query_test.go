@@ -0,0 +1,57 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func TestQuery(t *testing.T) {
+	start := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	var readings []state.State
+	for i := 0; i < 60; i++ {
+		var s state.State
+		s.SetReading("temperature", state.Reading{Value: float32(i), Unit: "celsius", MeasuredAt: start.Add(time.Duration(i) * time.Minute)})
+		readings = append(readings, s)
+	}
+	defer Set(nil)
+	Set(readings)
+
+	temperatures := func(states []state.State) []float32 {
+		var result []float32
+		for _, s := range states {
+			result = append(result, s.Temperature)
+		}
+		return result
+	}
+	at := func(minute int) time.Time { return start.Add(time.Duration(minute) * time.Minute) }
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		step     time.Duration
+		limit    int
+		want     []float32
+	}{
+		{"range", at(10), at(12), 0, 0, []float32{10, 11, 12}},
+		{"step", at(0), at(5), 2 * time.Minute, 0, []float32{0.5, 2.5, 4.5}},
+		{"open", at(56), time.Time{}, 2 * time.Minute, 0, []float32{56.5, 58.5}},
+		{"limit", time.Time{}, time.Time{}, 0, 3, []float32{9.5, 29.5, 49.5}},
+		{"limit_step", at(0), at(9), time.Minute, 2, []float32{2, 7}},
+		{"empty", at(100), time.Time{}, 0, 0, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := temperatures(Query(tc.from, tc.to, tc.step, tc.limit))
+			if len(got) != len(tc.want) {
+				t.Fatalf("Got temperatures %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("Got temperatures %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package history
+
+import "time"
+
+// Trend returns the rate of change per hour of the reading called name, over
+// the readings in the window before now, by least squares. There is no trend
+// if the readings don't cover at least half of the window, as the sensor's
+// coarse resolution would make it too noisy.
+func Trend(name string, window time.Duration, now time.Time) (float32, bool) {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+
+	var n, sumX, sumY, sumXY, sumXX float64
+	var first, last time.Time
+	for _, s := range history.readings {
+		r, ok := s.Reading(name)
+		if !ok || r.MeasuredAt.Before(now.Add(-window)) || r.MeasuredAt.After(now) {
+			continue
+		}
+		if first.IsZero() {
+			first = r.MeasuredAt
+		}
+		last = r.MeasuredAt
+
+		x, y := r.MeasuredAt.Sub(now).Hours(), float64(r.Value)
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	if n < 2 || last.Sub(first) < window/2 {
+		return 0, false
+	}
+	return float32((n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)), true
+}
+
+// Trends returns the trends of the temperature and humidity; see Trend
+func Trends(window time.Duration, now time.Time) map[string]float32 {
+	var trends map[string]float32
+	for _, name := range []string{"temperature", "humidity"} {
+		if t, ok := Trend(name, window, now); ok {
+			if trends == nil {
+				trends = map[string]float32{}
+			}
+			trends[name] = t
+		}
+	}
+	return trends
+}
@@ -0,0 +1,202 @@
+// Package jsonlog implements an append-only, newline-delimited JSON log of
+// readings with size- and age-based rotation. Rotated files are renamed
+// with numeric suffixes (readings.jsonl.1, readings.jsonl.2, ...), the same
+// way logrotate does, so that tools tailing the log can follow it.
+package jsonlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Log is a rotating JSONL log
+type Log struct {
+	path string
+
+	// MaxSize is the size in bytes after which the log is rotated; 0 to
+	// disable size-based rotation.
+	MaxSize int64
+
+	// MaxAge is the age after which the log is rotated; 0 to disable
+	// age-based rotation.
+	MaxAge time.Duration
+
+	// Keep is the number of rotated files to keep
+	Keep int
+
+	// Time, if set, returns the time of the entry in line. The age of a log
+	// which already has entries when opened is taken from its first one, so
+	// that restarts don't restart the clock for MaxAge; without Time, it's
+	// taken from the file's modification time.
+	Time func(line []byte) (time.Time, error)
+
+	mu      sync.Mutex
+	f       *os.File
+	closed  bool
+	size    int64
+	created time.Time // zero until known, for a log opened with entries
+}
+
+// Open opens (or creates) the log at path for appending
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, Keep: 5}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", l.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat %q: %w", l.path, err)
+	}
+	l.f = f
+	l.size = info.Size()
+	l.created = time.Time{}
+	if l.size == 0 {
+		l.created = time.Now()
+	}
+	return nil
+}
+
+// firstEntryTime returns the time of the first entry in the active log,
+// falling back to its modification time
+func (l *Log) firstEntryTime() time.Time {
+	info, err := l.f.Stat()
+	if err != nil {
+		return time.Now()
+	}
+	if l.Time == nil {
+		return info.ModTime()
+	}
+	f, err := os.Open(l.path)
+	if err != nil {
+		return info.ModTime()
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return info.ModTime()
+	}
+	t, err := l.Time(line)
+	if err != nil || t.IsZero() {
+		return info.ModTime()
+	}
+	return t
+}
+
+// Write appends v as a single JSON line, rotating the log first if needed
+func (l *Log) Write(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("log %q is closed", l.path)
+	}
+	if l.f == nil {
+		// A failed rotation couldn't reopen it
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+
+	// If rotating fails, the entry is still written to the active log
+	var rotateErr error
+	if l.needsRotation(int64(len(line))) {
+		rotateErr = l.rotate()
+		if l.f == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to %q: %w", l.path, err)
+	}
+	return rotateErr
+}
+
+func (l *Log) needsRotation(nextWrite int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.MaxSize > 0 && l.size+nextWrite > l.MaxSize {
+		return true
+	}
+	if l.MaxAge > 0 && l.created.IsZero() {
+		l.created = l.firstEntryTime()
+	}
+	if l.MaxAge > 0 && time.Since(l.created) > l.MaxAge {
+		return true
+	}
+	return false
+}
+
+// rotate rotates the log. The active log is reopened even if rotating
+// fails, so that later writes can still succeed.
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("failed to close %q: %w", l.path, err)
+	}
+	l.f = nil
+
+	err := l.shift()
+	if openErr := l.open(); err == nil {
+		err = openErr
+	}
+	return err
+}
+
+// shift renames each log to the next rotated path, removing the oldest
+func (l *Log) shift() error {
+	// With Keep == 0, this just removes the active log
+	if err := os.Remove(l.rotatedPath(l.Keep)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove oldest log: %w", err)
+	}
+	for i := l.Keep - 1; i >= 0; i-- {
+		err := os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log: %w", err)
+		}
+	}
+	return nil
+}
+
+// rotatedPath returns the path of the i'th rotated file; 0 is the active log
+func (l *Log) rotatedPath(i int) string {
+	if i == 0 {
+		return l.path
+	}
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,84 @@
+package jsonlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	Time time.Time
+}
+
+func entryTime(line []byte) (time.Time, error) {
+	var e entry
+	err := json.Unmarshal(line, &e)
+	return e.Time, err
+}
+
+func TestAgeSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "readings.jsonl")
+	old := time.Now().Add(-2 * time.Hour)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(entry{old}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	// As after a restart
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.MaxAge = time.Hour
+	l.Time = entryTime
+	if err := l.Write(entry{time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("Log with an entry older than MaxAge wasn't rotated on reopening: %v", err)
+	}
+}
+
+func TestWriteAfterFailedRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "readings.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.MaxSize = 1
+	l.Keep = 1
+	if err := l.Write(entry{time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in the way of the rotated log makes renaming fail
+	if err := os.Mkdir(path+".1", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path+".1", "x"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(entry{time.Now()}); err == nil {
+		t.Error("Write succeeded despite failing to rotate")
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(entry{time.Now()}); err != nil {
+		t.Errorf("Write after a failed rotation failed: %v", err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("Log wasn't rotated once possible: %v", err)
+	}
+}
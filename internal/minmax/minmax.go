@@ -0,0 +1,95 @@
+// Package minmax tracks the minimum and maximum readings of each location
+// over the last day. Readings are aggregated into hourly buckets, so memory
+// use doesn't depend on how often sensors report.
+package minmax
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+const (
+	bucketSize = time.Hour
+
+	// Window is the period over which minimums and maximums are tracked
+	Window = 24 * bucketSize
+)
+
+// Range is the range of readings over Window
+type Range struct {
+	MinTemperature, MaxTemperature float32
+	MinHumidity, MaxHumidity       float32
+}
+
+func (r *Range) add(other Range) {
+	if other.MinTemperature < r.MinTemperature {
+		r.MinTemperature = other.MinTemperature
+	}
+	if other.MaxTemperature > r.MaxTemperature {
+		r.MaxTemperature = other.MaxTemperature
+	}
+	if other.MinHumidity < r.MinHumidity {
+		r.MinHumidity = other.MinHumidity
+	}
+	if other.MaxHumidity > r.MaxHumidity {
+		r.MaxHumidity = other.MaxHumidity
+	}
+}
+
+type bucket struct {
+	start time.Time
+	Range
+}
+
+var locations = struct {
+	mu sync.Mutex
+
+	buckets map[string][]bucket
+}{buckets: map[string][]bucket{}}
+
+// Add records a reading for location; thread-safe
+func Add(location string, s state.State) {
+	locations.mu.Lock()
+	defer locations.mu.Unlock()
+
+	r := Range{s.Temperature, s.Temperature, s.Humidity, s.Humidity}
+	start := s.LastSensorUpdate.Truncate(bucketSize)
+	buckets := expire(locations.buckets[location], s.LastSensorUpdate)
+
+	if n := len(buckets); n > 0 && buckets[n-1].start.Equal(start) {
+		buckets[n-1].add(r)
+	} else {
+		buckets = append(buckets, bucket{start, r})
+	}
+	locations.buckets[location] = buckets
+}
+
+// expire discards buckets that ended more than Window before now
+func expire(buckets []bucket, now time.Time) []bucket {
+	i := 0
+	for i < len(buckets) && now.Sub(buckets[i].start) >= Window+bucketSize {
+		i++
+	}
+	return append(buckets[:0], buckets[i:]...)
+}
+
+// Get returns the range of readings for location over the last Window, and
+// false if there are none; thread-safe
+func Get(location string) (Range, bool) {
+	locations.mu.Lock()
+	defer locations.mu.Unlock()
+
+	buckets := expire(locations.buckets[location], time.Now())
+	locations.buckets[location] = buckets
+	if len(buckets) == 0 {
+		return Range{}, false
+	}
+
+	r := buckets[0].Range
+	for _, b := range buckets[1:] {
+		r.add(b.Range)
+	}
+	return r, true
+}
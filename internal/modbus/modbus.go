@@ -0,0 +1,137 @@
+// Package modbus implements a minimal read-only Modbus TCP server, exposing
+// fixed registers to building-management systems and PLCs.
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+const (
+	funcReadHoldingRegisters = 0x03
+	funcReadInputRegisters   = 0x04
+
+	exceptionIllegalFunction    = 0x01
+	exceptionIllegalDataAddress = 0x02
+	exceptionIllegalDataValue   = 0x03
+	exceptionGatewayNoResponse  = 0x0b
+
+	// maxQuantity is the maximum number of registers in a single read
+	maxQuantity = 125
+
+	// maxPDU is the maximum size of a Modbus PDU
+	maxPDU = 253
+
+	// idleTimeout closes connections from clients that stopped polling
+	idleTimeout = 5 * time.Minute
+)
+
+// Register returns the current value of a register
+type Register func() uint16
+
+// ListenAndServe serves registers (keyed by address) as both holding and
+// input registers for unitID, on the TCP address addr until ctx is
+// cancelled. Reads of unmapped addresses fail with an illegal data address
+// exception.
+func ListenAndServe(ctx context.Context, addr string, unitID byte, registers map[uint16]Register) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept: %w", err)
+		}
+		go func() {
+			defer conn.Close()
+			if err := serveConn(conn, unitID, registers); err != nil {
+				slog.Warn("Modbus: connection failed", "remote", conn.RemoteAddr().String(), "err", err)
+			}
+		}()
+	}
+}
+
+func serveConn(conn net.Conn, unitID byte, registers map[uint16]Register) error {
+	// MBAP header: Transaction ID, Protocol ID, Length, Unit ID
+	header := make([]byte, 7)
+	pdu := make([]byte, maxPDU)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if binary.BigEndian.Uint16(header[2:]) != 0 {
+			return fmt.Errorf("unsupported protocol ID")
+		}
+		length := int(binary.BigEndian.Uint16(header[4:])) - 1
+		if length < 1 || length > maxPDU {
+			return fmt.Errorf("invalid length %d", length+1)
+		}
+		if _, err := io.ReadFull(conn, pdu[:length]); err != nil {
+			return err
+		}
+
+		var resp []byte
+		if header[6] != unitID {
+			resp = exception(pdu[0], exceptionGatewayNoResponse)
+		} else {
+			resp = handle(pdu[:length], registers)
+		}
+
+		binary.BigEndian.PutUint16(header[4:], uint16(len(resp)+1))
+		if _, err := conn.Write(append(header, resp...)); err != nil {
+			return err
+		}
+	}
+}
+
+func exception(function, code byte) []byte {
+	return []byte{function | 0x80, code}
+}
+
+// handle returns the response PDU for the request PDU req
+func handle(req []byte, registers map[uint16]Register) []byte {
+	function := req[0]
+	if function != funcReadHoldingRegisters && function != funcReadInputRegisters {
+		return exception(function, exceptionIllegalFunction)
+	}
+	if len(req) != 5 {
+		return exception(function, exceptionIllegalDataValue)
+	}
+
+	start := binary.BigEndian.Uint16(req[1:])
+	quantity := binary.BigEndian.Uint16(req[3:])
+	if quantity < 1 || quantity > maxQuantity {
+		return exception(function, exceptionIllegalDataValue)
+	}
+
+	resp := make([]byte, 2+2*quantity)
+	resp[0] = function
+	resp[1] = byte(2 * quantity)
+	for i := uint16(0); i < quantity; i++ {
+		r, ok := registers[start+i]
+		if !ok {
+			return exception(function, exceptionIllegalDataAddress)
+		}
+		binary.BigEndian.PutUint16(resp[2+2*i:], r())
+	}
+	return resp
+}
@@ -0,0 +1,88 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// haSensor describes a single entity for Home Assistant MQTT discovery; see
+// https://www.home-assistant.io/integrations/sensor.mqtt/
+type haSensor struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	StateClass        string   `json:"state_class,omitempty"`
+	UnitOfMeasurement string   `json:"unit_of_measurement,omitempty"`
+	EntityCategory    string   `json:"entity_category,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Model        string   `json:"model"`
+	Manufacturer string   `json:"manufacturer"`
+
+	// SuggestedArea is the Home Assistant area to put the device in
+	SuggestedArea string `json:"suggested_area,omitempty"`
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// publishDiscovery publishes retained Home Assistant discovery messages for
+// all entities, so they show up in Home Assistant automatically.
+func (p *Publisher) publishDiscovery(c paho.Client) {
+	nodeID := nonIDChars.ReplaceAllString(p.opts.ClientID, "_")
+	device := haDevice{
+		Identifiers:  []string{nodeID},
+		Name:         p.opts.ClientID,
+		Model:        "DHT11",
+		Manufacturer: "pitemp",
+
+		SuggestedArea: p.opts.Location,
+	}
+
+	sensors := []struct {
+		object string
+		haSensor
+	}{
+		{"temperature", haSensor{
+			Name:              "Temperature",
+			DeviceClass:       "temperature",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "°C",
+		}},
+		{"humidity", haSensor{
+			Name:              "Humidity",
+			DeviceClass:       "humidity",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "%",
+		}},
+		{"staleness", haSensor{
+			Name:              "Staleness",
+			DeviceClass:       "duration",
+			UnitOfMeasurement: "s",
+			EntityCategory:    "diagnostic",
+		}},
+	}
+
+	for _, s := range sensors {
+		s.UniqueID = nodeID + "_" + s.object
+		s.StateTopic = p.Topic(s.object)
+		s.AvailabilityTopic = p.Topic(StatusTopic)
+		s.Device = device
+
+		payload, err := json.Marshal(s.haSensor)
+		if err != nil {
+			slog.Error("Failed to encode Home Assistant discovery", "object", s.object, "err", err)
+			continue
+		}
+		topic := p.opts.DiscoveryPrefix + "/sensor/" + nodeID + "/" + s.object + "/config"
+		c.Publish(topic, 1, true, payload)
+	}
+}
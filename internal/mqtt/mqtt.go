@@ -0,0 +1,136 @@
+// Package mqtt publishes readings to an MQTT broker, for home automation
+// systems.
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Options configures the MQTT Publisher
+type Options struct {
+	// Broker URL, e.g. tcp://localhost:1883
+	Broker             string
+	ClientID           string
+	Username, Password string
+
+	// TopicPrefix is prepended to all topics, e.g. pitemp/bedroom
+	TopicPrefix string
+
+	// Location is where the node is, e.g. bedroom, suggested to Home
+	// Assistant as the area of its device; it may be empty
+	Location string
+
+	QoS    byte
+	Retain bool
+
+	// DiscoveryPrefix, if set, enables Home Assistant MQTT discovery under
+	// that prefix (normally "homeassistant").
+	DiscoveryPrefix string
+}
+
+// Publisher publishes readings to an MQTT broker. Published topics (under
+// TopicPrefix) are:
+//
+//	status          "online", or "offline" (via last will) when disconnected
+//	temperature     °C
+//	humidity        %
+//	staleness       seconds since the last successful sensor read
+//	last_update     RFC3339 time of the last successful sensor read
+type Publisher struct {
+	opts   Options
+	client paho.Client
+}
+
+// StatusTopic is the topic (relative to TopicPrefix) of the availability
+// status
+const StatusTopic = "status"
+
+// Connect connects to the broker. The connection is retried in the
+// background if the broker is unavailable.
+func Connect(opts Options) (*Publisher, error) {
+	p := &Publisher{opts: opts}
+
+	co := paho.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetWill(p.Topic(StatusTopic), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(c paho.Client) {
+			if opts.DiscoveryPrefix != "" {
+				p.publishDiscovery(c)
+			}
+			c.Publish(p.Topic(StatusTopic), 1, true, "online")
+		})
+
+	p.client = paho.NewClient(co)
+	t := p.client.Connect()
+	// With ConnectRetry, the token only completes once connected; don't wait
+	// forever for a broker that's down.
+	if t.WaitTimeout(10*time.Second) && t.Error() != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker %q: %w", opts.Broker, t.Error())
+	}
+	return p, nil
+}
+
+// Topic returns the full topic name for name
+func (p *Publisher) Topic(name string) string {
+	return p.opts.TopicPrefix + "/" + name
+}
+
+// Publish publishes payload on the topic name (relative to the prefix),
+// waiting for completion or for ctx to be done.
+func (p *Publisher) Publish(ctx context.Context, name string, retain bool, payload interface{}) error {
+	t := p.client.Publish(p.Topic(name), p.opts.QoS, retain, payload)
+	select {
+	case <-t.Done():
+		if err := t.Error(); err != nil {
+			return fmt.Errorf("failed to publish %q: %w", p.Topic(name), err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to publish %q: %w", p.Topic(name), ctx.Err())
+	}
+}
+
+// Write publishes the reading in s
+func (p *Publisher) Write(ctx context.Context, s state.State) error {
+	messages := []struct{ topic, payload string }{
+		{"temperature", strconv.FormatFloat(float64(s.Temperature), 'f', 1, 32)},
+		{"humidity", strconv.FormatFloat(float64(s.Humidity), 'f', 1, 32)},
+		{"last_update", s.LastSensorUpdate.Format(time.RFC3339)},
+	}
+	for _, m := range messages {
+		if err := p.Publish(ctx, m.topic, p.opts.Retain, m.payload); err != nil {
+			return err
+		}
+	}
+	return p.WriteStaleness(ctx, s)
+}
+
+// WriteStaleness publishes only the staleness of s; used when the sensor
+// read fails, so subscribers can tell the last reading is getting old.
+func (p *Publisher) WriteStaleness(ctx context.Context, s state.State) error {
+	if s.LastSensorUpdate.IsZero() {
+		return nil
+	}
+	staleness := time.Since(s.LastSensorUpdate).Seconds()
+	return p.Publish(ctx, "staleness", p.opts.Retain, strconv.FormatFloat(staleness, 'f', 0, 64))
+}
+
+// Close publishes the offline status and disconnects from the broker
+func (p *Publisher) Close() error {
+	t := p.client.Publish(p.Topic(StatusTopic), 1, true, "offline")
+	t.WaitTimeout(time.Second)
+	p.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package notify implements notification backends for alerts.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/alert"
+)
+
+// httpClient is used by all HTTP-based notifiers
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Message formats e as a short title and a body
+func Message(e alert.Event) (title, body string) {
+	hostname, _ := os.Hostname()
+	value := fmt.Sprintf("%.1f", e.Value)
+	if e.Rule.Metric == alert.Staleness {
+		value = time.Duration(e.Value * float64(time.Second)).Round(time.Second).String()
+	}
+
+	if e.Firing {
+		title = fmt.Sprintf("pitemp alert: %s", e.Rule.Name)
+		body = fmt.Sprintf("%s on %s is %s (rule: %s)", e.Rule.Metric, hostname, value, e.Rule)
+	} else {
+		title = fmt.Sprintf("pitemp resolved: %s", e.Rule.Name)
+		body = fmt.Sprintf("%s on %s is back to %s", e.Rule.Metric, hostname, value)
+	}
+	if e.Held {
+		body += fmt.Sprintf(" at %s (held back during quiet hours)", e.Time.Format("15:04"))
+	}
+	return title, body
+}
+
+// send performs req, returning an error for non-2xx responses
+func send(ctx context.Context, req *http.Request) error {
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status,
+			strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
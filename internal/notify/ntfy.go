@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lutzky/pitemp/internal/alert"
+)
+
+// Ntfy sends notifications through ntfy (https://ntfy.sh)
+type Ntfy struct {
+	// TopicURL is the full URL of the topic, e.g. https://ntfy.sh/my-pitemp
+	TopicURL string
+
+	// Token is an optional access token
+	Token string
+}
+
+// Notify implements alert.Notifier
+func (n *Ntfy) Notify(ctx context.Context, e alert.Event) error {
+	title, body := Message(e)
+
+	req, err := http.NewRequest(http.MethodPost, n.TopicURL, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	req.Header.Set("Title", title)
+	if e.Firing {
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	} else {
+		req.Header.Set("Tags", "white_check_mark")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	if err := send(ctx, req); err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lutzky/pitemp/internal/alert"
+)
+
+const pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends notifications through Pushover (https://pushover.net)
+type Pushover struct {
+	// Token is the application API token
+	Token string
+
+	// User is the user (or group) key
+	User string
+}
+
+// Notify implements alert.Notifier
+func (p *Pushover) Notify(ctx context.Context, e alert.Event) error {
+	title, body := Message(e)
+
+	priority := "0"
+	if e.Firing {
+		priority = "1"
+	}
+	form := url.Values{
+		"token":    {p.Token},
+		"user":     {p.User},
+		"title":    {title},
+		"message":  {body},
+		"priority": {priority},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if err := send(ctx, req); err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package otlp
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64     `json:"timeUnixNano,string"`
+	AsDouble          float64    `json:"asDouble"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Gauge       *gauge `json:"gauge,omitempty"`
+	Sum         *sum   `json:"sum,omitempty"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type exportMetricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+const aggregationTemporalityCumulative = 2
+
+// processStart is reported as the start time of cumulative sums
+var processStart = time.Now()
+
+// ExportMetrics exports gauges and counters from gatherer whose names start
+// with prefix (e.g. "pitemp_").
+func (e *Exporter) ExportMetrics(ctx context.Context, gatherer prometheus.Gatherer, prefix string) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	now := uint64(time.Now().UnixNano())
+	start := uint64(processStart.UnixNano())
+
+	var metrics []metric
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), prefix) {
+			continue
+		}
+		m := metric{Name: mf.GetName(), Description: mf.GetHelp()}
+
+		var points []numberDataPoint
+		for _, pm := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range pm.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			p := numberDataPoint{Attributes: attributes(labels), TimeUnixNano: now}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				p.AsDouble = pm.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				p.AsDouble = pm.GetCounter().GetValue()
+				p.StartTimeUnixNano = start
+			default:
+				continue
+			}
+			points = append(points, p)
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		if mf.GetType() == dto.MetricType_COUNTER {
+			m.Sum = &sum{points, aggregationTemporalityCumulative, true}
+		} else {
+			m.Gauge = &gauge{points}
+		}
+		metrics = append(metrics, m)
+	}
+
+	req := exportMetricsRequest{[]resourceMetrics{{
+		Resource:     e.resource(),
+		ScopeMetrics: []scopeMetrics{{pitempScope, metrics}},
+	}}}
+	return e.post(ctx, "/v1/metrics", req)
+}
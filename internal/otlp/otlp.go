@@ -0,0 +1,95 @@
+// Package otlp exports metrics and traces using the OpenTelemetry protocol
+// (OTLP/HTTP with JSON encoding), so pitemp can feed OpenTelemetry-based
+// observability pipelines without pulling in the full OpenTelemetry SDK.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Exporter sends OTLP data to a collector
+type Exporter struct {
+	// Endpoint is the base URL of the collector's OTLP/HTTP receiver, e.g.
+	// http://localhost:4318; /v1/metrics and /v1/traces are appended to it.
+	Endpoint string
+
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+
+	// Attributes are additional resource attributes
+	Attributes map[string]string
+
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func attributes(m map[string]string) []keyValue {
+	var kvs []keyValue
+	for k, v := range m {
+		kvs = append(kvs, keyValue{k, anyValue{v}})
+	}
+	return kvs
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+var pitempScope = scope{Name: "github.com/lutzky/pitemp"}
+
+func (e *Exporter) resource() resource {
+	attrs := map[string]string{"service.name": e.ServiceName}
+	for k, v := range e.Attributes {
+		attrs[k] = v
+	}
+	return resource{attributes(attrs)}
+}
+
+func (e *Exporter) post(ctx context.Context, path string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode: %w", err)
+	}
+
+	url := strings.TrimSuffix(e.Endpoint, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP export to %q failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP export to %q failed: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package otlp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxQueuedSpans bounds memory use if the collector is unreachable; spans
+// beyond this are dropped until the next successful export.
+const maxQueuedSpans = 1024
+
+// Span kinds, as defined by the OTLP Span.SpanKind enum
+const (
+	KindInternal = 1
+	KindServer   = 2
+)
+
+const statusCodeError = 2
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano   uint64     `json:"endTimeUnixNano,string"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type exportTraceRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+// Tracer records spans and exports them in batches. A nil *Tracer is valid
+// and records nothing, so callers don't need to check whether tracing is
+// enabled.
+type Tracer struct {
+	exporter *Exporter
+
+	mu      sync.Mutex
+	pending []span
+	dropped int
+}
+
+// NewTracer returns a Tracer exporting via e
+func NewTracer(e *Exporter) *Tracer {
+	return &Tracer{exporter: e}
+}
+
+// Span is an in-progress span; call End when the operation completes. A nil
+// *Span is valid and does nothing.
+type Span struct {
+	tracer *Tracer
+	span   span
+	attrs  map[string]string
+}
+
+type spanKey struct{}
+
+// Start starts a span as a child of the span in ctx, if any, and returns a
+// context carrying the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		tracer: t,
+		span: span{
+			SpanID:            randomID(8),
+			Name:              name,
+			Kind:              kind,
+			StartTimeUnixNano: uint64(time.Now().UnixNano()),
+		},
+		attrs: map[string]string{},
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.span.TraceID = parent.span.TraceID
+		s.span.ParentSpanID = parent.span.SpanID
+	} else {
+		s.span.TraceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute sets a string attribute on the span
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed, if err is non-nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.Status = status{Code: statusCodeError, Message: err.Error()}
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.EndTimeUnixNano = uint64(time.Now().UnixNano())
+	s.span.Attributes = attributes(s.attrs)
+
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, s.span)
+}
+
+// Flush exports all queued spans
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("OTLP: dropped spans due to full queue", "spans", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	req := exportTraceRequest{[]resourceSpans{{
+		Resource:   t.exporter.resource(),
+		ScopeSpans: []scopeSpans{{pitempScope, spans}},
+	}}}
+	return t.exporter.post(ctx, "/v1/traces", req)
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate ID: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Handler wraps h, recording a server span for each request. Long-lived
+// connections (e.g. websockets) are passed through without wrapping the
+// ResponseWriter, since that would hide http.Hijacker.
+func (t *Tracer) Handler(h http.Handler) http.Handler {
+	if t == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := t.Start(r.Context(), r.Method+" "+r.URL.Path, KindServer)
+		s.SetAttribute("http.method", r.Method)
+		s.SetAttribute("http.target", r.URL.Path)
+		defer s.End()
+
+		if r.Header.Get("Upgrade") != "" {
+			h.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		rec := &statusRecorder{w, http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+		s.SetAttribute("http.status_code", fmt.Sprint(rec.status))
+		if rec.status >= 500 {
+			s.SetError(fmt.Errorf("%s", http.StatusText(rec.status)))
+		}
+	})
+}
@@ -0,0 +1,239 @@
+// Package plugin runs external programs, written in any language, as
+// sensors and outputs. pitemp talks to a plugin over its stdin and stdout,
+// one JSON object per line: it writes a request, and the plugin writes
+// exactly one response to each request, in order. Anything the plugin
+// writes to stderr is logged.
+//
+// Requests have a type, and possibly more fields depending on it:
+//
+//	{"type": "read"}
+//	{"type": "state", "state": {...}}
+//	{"type": "alert", "alert": {"rule": "hot", "firing": true, "value": 28.5, "time": "..."}}
+//
+// A "read" asks a sensor plugin for its readings, in the format used for
+// readings in /api; measured_at defaults to the time of the response:
+//
+//	{"readings": {"temperature": {"value": 21.5, "unit": "celsius"}}}
+//
+// "state" gives an output plugin a new reading (in the format of /api), and
+// "alert" an alert notification; both are acknowledged with an empty
+// response. Any request can be failed by responding with an error:
+//
+//	{"error": "sensor not found"}
+//
+// The plugin is started on the first request. If it exits, writes an
+// invalid response or doesn't respond in time, it is killed and restarted
+// on a later request, backing off after repeated failures.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Request is a request to a plugin
+type Request struct {
+	Type  string      `json:"type"`
+	State *wire.State `json:"state,omitempty"`
+	Alert *Alert      `json:"alert,omitempty"`
+}
+
+// Alert is an alert event, as sent to plugins
+type Alert struct {
+	Rule   string    `json:"rule"`
+	Firing bool      `json:"firing"`
+	Value  float64   `json:"value"`
+	Time   time.Time `json:"time"`
+}
+
+// Response is a plugin's response to a request
+type Response struct {
+	Readings map[string]wire.Reading `json:"readings,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// Process is a plugin process, started when needed
+type Process struct {
+	name    string
+	args    []string
+	timeout time.Duration
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan []byte
+	exited    chan struct{}
+	failures  int
+	nextStart time.Time
+}
+
+// New returns a plugin running command, split into arguments at spaces,
+// which must respond to each request within timeout
+func New(command string, timeout time.Duration) (*Process, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("no plugin command given")
+	}
+	return &Process{name: filepath.Base(args[0]), args: args, timeout: timeout}, nil
+}
+
+// Name returns the plugin's program name, for logs
+func (p *Process) Name() string {
+	return p.name
+}
+
+// start starts the process; p.mu must be held
+func (p *Process) start() error {
+	if now := time.Now(); now.Before(p.nextStart) {
+		return fmt.Errorf("plugin %s failed, restarting in %s", p.name, p.nextStart.Sub(now).Round(time.Second))
+	}
+
+	cmd := exec.Command(p.args[0], p.args[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		p.failed()
+		return fmt.Errorf("failed to start plugin %s: %w", p.name, err)
+	}
+	slog.Info("Started plugin", "plugin", p.name, "pid", cmd.Process.Pid)
+
+	responses, exited := make(chan []byte), make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			slog.Warn("Plugin error output", "plugin", p.name, "line", scanner.Text())
+		}
+	}()
+	go func() {
+		defer close(exited)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case responses <- append([]byte(nil), scanner.Bytes()...):
+			case <-time.After(p.timeout):
+				// Unrequested output; the process is killed for it
+				slog.Warn("Plugin wrote an unexpected response", "plugin", p.name)
+				return
+			}
+		}
+	}()
+	go func() {
+		<-exited
+		err := cmd.Wait()
+		slog.Warn("Plugin exited", "plugin", p.name, "err", err)
+	}()
+
+	p.cmd, p.stdin, p.responses, p.exited = cmd, stdin, responses, exited
+	return nil
+}
+
+// failed records a failure, delaying the next start; p.mu must be held
+func (p *Process) failed() {
+	backoff := minBackoff << p.failures
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	} else {
+		p.failures++
+	}
+	p.nextStart = time.Now().Add(backoff)
+}
+
+// stop kills the process, if running; p.mu must be held
+func (p *Process) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd = nil
+}
+
+// Call sends req to the plugin, starting it if needed, and returns its
+// response. Error responses are returned as errors.
+func (p *Process) Call(ctx context.Context, req Request) (Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return Response{}, err
+		}
+	}
+
+	resp, err := p.call(ctx, req)
+	if err != nil {
+		slog.Warn("Killing plugin", "plugin", p.name, "err", err)
+		p.stop()
+		p.failed()
+		return Response{}, err
+	}
+	p.failures = 0
+	if resp.Error != "" {
+		return resp, fmt.Errorf("plugin %s: %s", p.name, resp.Error)
+	}
+	return resp, nil
+}
+
+// call makes a request of the running process; any error means it must be
+// restarted
+func (p *Process) call(ctx context.Context, req Request) (Response, error) {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		return Response{}, fmt.Errorf("failed to write to plugin %s: %w", p.name, err)
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	var resp Response
+	select {
+	case line := <-p.responses:
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return Response{}, fmt.Errorf("invalid response from plugin %s: %w", p.name, err)
+		}
+		return resp, nil
+	case <-p.exited:
+		return Response{}, fmt.Errorf("plugin %s exited", p.name)
+	case <-timer.C:
+		return Response{}, fmt.Errorf("plugin %s did not respond within %s", p.name, p.timeout)
+	case <-ctx.Done():
+		return Response{}, ctx.Err()
+	}
+}
+
+// Close stops the plugin
+func (p *Process) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package postgres writes readings to a PostgreSQL (optionally TimescaleDB)
+// table, so that readings from several nodes can be stored centrally.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/lib/pq"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Writer inserts readings into a table
+type Writer struct {
+	db        *sql.DB
+	table     string
+	timescale bool
+	insert    string
+	node      string
+
+	mu    sync.Mutex
+	ready bool // whether the table has been created
+}
+
+// Open connects to the database at dsn and creates table if it doesn't exist.
+// If timescale is true, the table is also converted to a TimescaleDB
+// hypertable. Rows are tagged with node. If the database can't be reached,
+// creating the table is retried on each Write, so that pitemp can start
+// before its database; other errors, such as a missing TimescaleDB
+// extension, are returned.
+func Open(ctx context.Context, dsn, table, node string, timescale bool) (*Writer, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	w := &Writer{
+		db:        db,
+		table:     table,
+		timescale: timescale,
+		insert:    `INSERT INTO ` + pq.QuoteIdentifier(table) + ` (time, node, temperature, humidity) VALUES ($1, $2, $3, $4)`,
+		node:      node,
+	}
+	if err := w.setup(ctx); err != nil {
+		if !transient(err) {
+			db.Close()
+			return nil, err
+		}
+		slog.Warn("Failed to set up PostgreSQL table; will retry", "err", err)
+	}
+	return w, nil
+}
+
+// transient returns whether err may go away by retrying, e.g. as the
+// database can't be reached (yet)
+func transient(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", "53", "57":
+			// Connection exceptions, insufficient resources, and operator
+			// intervention, such as the database starting up
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// setup creates the table, unless already done
+func (w *Writer) setup(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ready {
+		return nil
+	}
+
+	quoted := pq.QuoteIdentifier(w.table)
+	_, err := w.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+quoted+` (
+		time        TIMESTAMPTZ      NOT NULL,
+		node        TEXT             NOT NULL,
+		temperature DOUBLE PRECISION,
+		humidity    DOUBLE PRECISION
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create table %q: %w", w.table, err)
+	}
+
+	if w.timescale {
+		// create_hypertable takes a regclass, parsed like an identifier in
+		// SQL, so it needs the same quoting
+		_, err = w.db.ExecContext(ctx,
+			`SELECT create_hypertable($1, 'time', if_not_exists => TRUE)`, quoted)
+		if err != nil {
+			return fmt.Errorf("failed to create hypertable %q: %w", w.table, err)
+		}
+	}
+
+	w.ready = true
+	return nil
+}
+
+// Write inserts the reading in s, first creating the table if that failed
+// so far
+func (w *Writer) Write(ctx context.Context, s state.State) error {
+	if err := w.setup(ctx); err != nil {
+		return err
+	}
+	_, err := w.db.ExecContext(ctx, w.insert,
+		s.LastSensorUpdate, w.node, s.Temperature, s.Humidity)
+	if err != nil {
+		return fmt.Errorf("failed to insert reading: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database connection
+func (w *Writer) Close() error {
+	return w.db.Close()
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused", fmt.Errorf("failed to create table: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"bad_conn", driver.ErrBadConn, true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"starting_up", &pq.Error{Code: "57P03"}, true},
+		{"too_many_connections", &pq.Error{Code: "53300"}, true},
+		{"no_timescale", fmt.Errorf("failed to create hypertable: %w", &pq.Error{Code: "42883"}), false},
+		{"bad_password", &pq.Error{Code: "28P01"}, false},
+		{"ssl", errors.New("pq: SSL is not enabled on the server"), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := transient(tc.err); got != tc.want {
+				t.Errorf("transient(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package remotewrite pushes Prometheus metrics to a remote_write endpoint,
+// for setups where Prometheus can't scrape pitemp directly (e.g. behind NAT).
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Client pushes all metrics from Gatherer to URL
+type Client struct {
+	URL string
+
+	// Username and Password are used for basic auth, if Username is set
+	Username, Password string
+
+	// Gatherer defaults to prometheus.DefaultGatherer
+	Gatherer prometheus.Gatherer
+
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+
+	// ExternalLabels are added to every series which doesn't have them
+	// already, e.g. an instance label, without which the series of nodes
+	// writing to the same endpoint would overwrite each other
+	ExternalLabels map[string]string
+}
+
+// Push gathers the current metrics and sends them to the remote_write
+// endpoint as a single request.
+func (c *Client) Push(ctx context.Context) error {
+	gatherer := c.Gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(families, c.ExternalLabels, time.Now()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "pitemp")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote_write request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote_write to %q failed: %s: %s", c.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+type label struct{ name, value string }
+
+type series struct {
+	labels []label
+	value  float64
+}
+
+// flatten converts metric families into individual series, expanding
+// histograms and summaries the same way the text exposition format does, and
+// adding external labels which the series don't have.
+func flatten(families []*dto.MetricFamily, external map[string]string) []series {
+	var result []series
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			labels := []label{}
+			has := map[string]bool{}
+			for _, lp := range m.GetLabel() {
+				// An empty label is the same as none
+				if lp.GetValue() != "" {
+					labels = append(labels, label{lp.GetName(), lp.GetValue()})
+					has[lp.GetName()] = true
+				}
+			}
+			for name, value := range external {
+				if !has[name] {
+					labels = append(labels, label{name, value})
+				}
+			}
+			add := func(suffix string, value float64, extra ...label) {
+				l := append([]label{{"__name__", name + suffix}}, labels...)
+				l = append(l, extra...)
+				sort.Slice(l, func(i, j int) bool { return l[i].name < l[j].name })
+				result = append(result, series{l, value})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add("_bucket", float64(b.GetCumulativeCount()), label{"le", formatFloat(b.GetUpperBound())})
+				}
+				add("_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return result
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes a prometheus.WriteRequest protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []*dto.MetricFamily, external map[string]string, now time.Time) []byte {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+
+	var req []byte
+	for _, s := range flatten(families, external) {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(timestamp))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
@@ -0,0 +1,30 @@
+package remotewrite
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFlattenExternalLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "temperature"}, []string{"instance"})
+	reg.MustRegister(g)
+	g.WithLabelValues("").Set(21)
+	g.WithLabelValues("garage").Set(8)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := flatten(families, map[string]string{"instance": "bedroom", "job": "pitemp"})
+	want := []series{
+		// An empty label is the same as none, so it's added
+		{[]label{{"__name__", "temperature"}, {"instance", "bedroom"}, {"job", "pitemp"}}, 21},
+		{[]label{{"__name__", "temperature"}, {"instance", "garage"}, {"job", "pitemp"}}, 8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+// Package sun computes sunrise and sunset times, using the sunrise equation
+// as used by NOAA, which is accurate to within a minute or two away from the
+// poles.
+package sun
+
+import (
+	"math"
+	"time"
+)
+
+// julianUnixEpoch is the Julian date of the Unix epoch, and j2000 that of
+// 2000-01-01 12:00 UTC
+const (
+	julianUnixEpoch = 2440587.5
+	j2000           = 2451545.0
+)
+
+// Times returns the sunrise and sunset on the day of date (in its
+// location) at the given latitude and longitude, in degrees north and
+// east, and the length of the day. Where the sun doesn't rise or set that
+// day, the times are zero, and the day is 24h (midnight sun) or 0 (polar
+// night) long.
+func Times(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, day time.Duration) {
+	y, m, d := date.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, date.Location())
+	n := math.Round(julian(noon) - j2000 + longitude/360)
+
+	// Mean solar noon, the sun's mean anomaly, equation of the center and
+	// ecliptic longitude
+	meanNoon := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	center := 1.9148*sin(anomaly) + 0.0200*sin(2*anomaly) + 0.0003*sin(3*anomaly)
+	ecliptic := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := j2000 + meanNoon + 0.0053*sin(anomaly) - 0.0069*sin(2*ecliptic)
+
+	// The hour angle at which the sun's upper limb touches the horizon,
+	// allowing for refraction
+	declination := math.Asin(sin(ecliptic) * sin(23.4397))
+	cosHourAngle := (sin(-0.833) - sin(latitude)*math.Sin(declination)) / (cos(latitude) * math.Cos(declination))
+	switch {
+	case cosHourAngle < -1:
+		return time.Time{}, time.Time{}, 24 * time.Hour
+	case cosHourAngle > 1:
+		return time.Time{}, time.Time{}, 0
+	}
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+
+	sunrise = fromJulian(transit - hourAngle/360).In(date.Location())
+	sunset = fromJulian(transit + hourAngle/360).In(date.Location())
+	return sunrise, sunset, sunset.Sub(sunrise)
+}
+
+func julian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(int64(math.Round((j-julianUnixEpoch)*86400)), 0)
+}
+
+func sin(degrees float64) float64 {
+	return math.Sin(degrees * math.Pi / 180)
+}
+
+func cos(degrees float64) float64 {
+	return math.Cos(degrees * math.Pi / 180)
+}
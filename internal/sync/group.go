@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Group runs goroutines and waits for them to return, so that shutdown can
+// proceed in order (e.g. sensor reads finish before their outputs close).
+// A group created by WithContext also stops all of its goroutines when one
+// of them fails.
+type Group struct {
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// WithContext returns a group, and a context derived from ctx which is
+// cancelled when a goroutine started by Run fails. Goroutines are expected
+// to stop when it is cancelled.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// Go runs f in a new goroutine
+func (g *Group) Go(f func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		f()
+	}()
+}
+
+// Run runs f, a component called name (for logs), in a new goroutine. If f
+// returns an error, the group's context is cancelled, so that the other
+// components stop too, and the first such error is returned by Err.
+func (g *Group) Run(name string, f func() error) {
+	g.Go(func() {
+		err := f()
+		if err == nil {
+			return
+		}
+		slog.Error("Component failed", "component", name, "err", err)
+		g.mu.Lock()
+		if g.err == nil {
+			g.err = err
+		}
+		g.mu.Unlock()
+		if g.cancel != nil {
+			g.cancel()
+		}
+	})
+}
+
+// Err returns the first error returned by a goroutine started by Run, if
+// any
+func (g *Group) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+// Wait waits for all goroutines started by Go or Run to return, or for ctx
+// to be cancelled, in which case it returns ctx.Err().
+func (g *Group) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
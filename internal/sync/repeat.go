@@ -2,21 +2,121 @@ package sync
 
 import (
 	"context"
+	"math/rand"
 	"time"
 )
 
-// RepeatUntilCancelled runs f every interval until ctx is cancelled.
-func RepeatUntilCancelled(ctx context.Context, f func(), interval time.Duration) {
-	for {
+// RepeatOption modifies the schedule of RepeatUntilCancelled
+type RepeatOption func(*repeatOptions)
+
+type repeatOptions struct {
+	jitter       time.Duration
+	initialDelay time.Duration
+	aligned      bool
+	trigger      <-chan struct{}
+	slowdown     func() int
+	interval     func() time.Duration
+}
+
+// WithJitter delays each run by a further random duration of up to jitter,
+// so that many nodes started together don't all run in lockstep (e.g.
+// polling the same server).
+func WithJitter(jitter time.Duration) RepeatOption {
+	return func(o *repeatOptions) { o.jitter = jitter }
+}
+
+// WithInitialDelay delays the first run by d, rather than running f
+// immediately; use the interval itself to skip the first run.
+func WithInitialDelay(d time.Duration) RepeatOption {
+	return func(o *repeatOptions) { o.initialDelay = d }
+}
+
+// Aligned runs f at wall-clock multiples of the interval (e.g. at :00
+// seconds of every minute for an interval of a minute) after the first
+// run, so that the readings of several nodes line up. Intervals should
+// divide a day evenly; alignment is in UTC, which matches local time
+// for intervals of up to an hour in most time zones.
+func Aligned() RepeatOption {
+	return func(o *repeatOptions) { o.aligned = true }
+}
+
+// WithTrigger also runs f whenever trigger receives, e.g. to react to
+// changes immediately while otherwise running every interval; the wait for
+// the next run starts over after each triggered run.
+func WithTrigger(trigger <-chan struct{}) RepeatOption {
+	return func(o *repeatOptions) { o.trigger = trigger }
+}
+
+// WithSlowdown multiplies the interval by slowdown() before each wait,
+// e.g. to run less often in a low-power mode; values below 1 are ignored.
+func WithSlowdown(slowdown func() int) RepeatOption {
+	return func(o *repeatOptions) { o.slowdown = slowdown }
+}
+
+// WithInterval takes the interval from interval() before each wait, rather
+// than using the fixed one, e.g. for intervals adjustable at runtime; a
+// change takes effect after the wait already in progress.
+func WithInterval(interval func() time.Duration) RepeatOption {
+	return func(o *repeatOptions) { o.interval = interval }
+}
+
+// RepeatUntilCancelled runs f every interval until ctx is cancelled. By
+// default, the first run is immediate.
+func RepeatUntilCancelled(ctx context.Context, f func(), interval time.Duration, opts ...RepeatOption) {
+	var o repeatOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	delay := func(d time.Duration) time.Duration {
+		if o.jitter > 0 {
+			d += time.Duration(rand.Int63n(int64(o.jitter)))
+		}
+		return d
+	}
+	// next returns the delay until the next run after the first one
+	next := func() time.Duration {
+		interval := interval
+		if o.interval != nil {
+			interval = o.interval()
+		}
+		if o.slowdown != nil {
+			if n := o.slowdown(); n > 1 {
+				interval *= time.Duration(n)
+			}
+		}
+		if o.aligned {
+			now := time.Now()
+			return delay(now.Truncate(interval).Add(interval).Sub(now))
+		}
+		return delay(interval)
+	}
+
+	// Jitter applies to the first run too, as that's when nodes started
+	// together are most in lockstep
+	first := delay(o.initialDelay)
+	if first <= 0 {
 		f()
-		{
-			t := time.NewTimer(interval)
-			defer t.Stop()
+		first = next()
+	}
+
+	// A single timer is reused for the life of the loop, so that long-running
+	// processes don't allocate (and defer-stop) a new timer for every run.
+	t := time.NewTimer(first)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-o.trigger:
+			t.Stop()
+			// Discard the timer firing concurrently, if it did
 			select {
-			case <-ctx.Done():
-				return
 			case <-t.C:
+			default:
 			}
 		}
+		f()
+		t.Reset(next())
 	}
 }
@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = 5 * time.Minute
+)
+
+// RestartsCounter counts restarts of supervised goroutines after panics;
+// programs register it along with their other metrics
+var RestartsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "goroutine_restarts_total",
+	Help: "Restarts of background goroutines after panics, by component",
+}, []string{"component"})
+
+// Supervise runs f, a component called name (for logs and metrics), until
+// it returns. If f panics, it is restarted after a delay, which doubles
+// with every consecutive panic, so that e.g. a sensor driver bug doesn't
+// take down the whole process. It stops restarting once ctx is cancelled.
+func Supervise(ctx context.Context, name string, f func()) {
+	delay := minRestartDelay
+	for {
+		start := time.Now()
+		if !panicked(name, f) {
+			return
+		}
+		// Panics that far apart aren't consecutive
+		if time.Since(start) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		RestartsCounter.WithLabelValues(name).Inc()
+		slog.Warn("Restarting component", "component", name)
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// panicked runs f, reporting whether it panicked
+func panicked(name string, f func()) (p bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Component panicked", "component", name, "panic", r, "stack", string(debug.Stack()))
+			p = true
+		}
+	}()
+	f()
+	return false
+}
+
+// Supervise runs f in a new goroutine, restarting it if it panics (see
+// Supervise)
+func (g *Group) Supervise(ctx context.Context, name string, f func()) {
+	g.Go(func() { Supervise(ctx, name, f) })
+}
@@ -0,0 +1,111 @@
+// Package thermostat switches a relay (e.g. a heater's) to keep a reading
+// near a setpoint, with hysteresis and minimum on and off times protecting
+// the equipment from rapid cycling.
+package thermostat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Mode is the kind of equipment controlled
+type Mode string
+
+// Modes
+const (
+	Heater       Mode = "heater"
+	Humidifier   Mode = "humidifier"
+	Dehumidifier Mode = "dehumidifier"
+)
+
+// ParseMode parses a mode name
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case Heater, Humidifier, Dehumidifier:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown thermostat mode %q, expected heater, humidifier or dehumidifier", s)
+}
+
+// Reading returns the name of the reading the mode controls
+func (m Mode) Reading() string {
+	if m == Heater {
+		return "temperature"
+	}
+	return "humidity"
+}
+
+// raises reports whether the equipment raises the reading
+func (m Mode) raises() bool {
+	return m != Dehumidifier
+}
+
+// Relay switches the equipment
+type Relay interface {
+	Set(on bool) error
+}
+
+// Config configures a Controller
+type Config struct {
+	Mode Mode
+
+	// The relay switches on once the reading is Hysteresis beyond Setpoint
+	// in the wrong direction (e.g. below it, for a heater), and off once it
+	// is Hysteresis beyond it in the right one.
+	Setpoint, Hysteresis float32
+
+	// MinOn and MinOff are the shortest time the relay stays on or off
+	MinOn, MinOff time.Duration
+}
+
+// Controller switches a relay according to readings. It is not safe for
+// concurrent use.
+type Controller struct {
+	cfg   Config
+	relay Relay
+
+	on      bool
+	changed time.Time
+}
+
+// New returns a controller for relay, which must initially be off
+func New(cfg Config, relay Relay) *Controller {
+	return &Controller{cfg: cfg, relay: relay}
+}
+
+// Update switches the relay according to s at now, and returns whether it
+// is on. Without a current reading, the relay is switched off.
+func (c *Controller) Update(s state.State, now time.Time) (bool, error) {
+	want := c.on
+	r, ok := s.Reading(c.cfg.Mode.Reading())
+	if !ok || s.IsStale(state.StaleAfter()) {
+		want = false
+	} else {
+		switch {
+		case r.Value < c.cfg.Setpoint-c.cfg.Hysteresis:
+			want = c.cfg.Mode.raises()
+		case r.Value > c.cfg.Setpoint+c.cfg.Hysteresis:
+			want = !c.cfg.Mode.raises()
+		}
+	}
+
+	if want == c.on {
+		return c.on, nil
+	}
+	if !c.changed.IsZero() {
+		minTime := c.cfg.MinOff
+		if c.on {
+			minTime = c.cfg.MinOn
+		}
+		if now.Sub(c.changed) < minTime {
+			return c.on, nil
+		}
+	}
+	if err := c.relay.Set(want); err != nil {
+		return c.on, err
+	}
+	c.on, c.changed = want, now
+	return c.on, nil
+}
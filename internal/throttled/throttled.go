@@ -0,0 +1,100 @@
+// Package throttled reads the Raspberry Pi firmware's undervoltage and
+// throttling flags, as reported by "vcgencmd get_throttled". An inadequate
+// power supply is a common cause of flaky DHT11 reads, and these flags are
+// the easiest way to catch one.
+package throttled
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Flags are the bits reported by get_throttled. The low bits are current
+// conditions; the same conditions having occurred since boot are reported
+// 16 bits higher.
+type Flags uint32
+
+// Conditions
+const (
+	Undervoltage Flags = 1 << iota
+	FrequencyCapped
+	Throttled
+	SoftTempLimit
+)
+
+// sinceBootShift is how far the since-boot bits are from the current ones
+const sinceBootShift = 16
+
+// Conditions lists the conditions, with their names (e.g. for metric
+// labels)
+var Conditions = []struct {
+	Flag Flags
+	Name string
+}{
+	{Undervoltage, "undervoltage"},
+	{FrequencyCapped, "frequency_capped"},
+	{Throttled, "throttled"},
+	{SoftTempLimit, "soft_temp_limit"},
+}
+
+// Now returns whether condition c currently holds
+func (f Flags) Now(c Flags) bool { return f&c != 0 }
+
+// SinceBoot returns whether condition c has held at any point since boot
+func (f Flags) SinceBoot(c Flags) bool { return f&(c<<sinceBootShift) != 0 }
+
+// Names returns the names of the conditions which currently hold
+func (f Flags) Names() []string {
+	var names []string
+	for _, c := range Conditions {
+		if f.Now(c.Flag) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// sysfs is where recent kernels expose get_throttled, without needing
+// vcgencmd (or access to /dev/vchiq)
+const sysfs = "/sys/devices/platform/soc/soc:firmware/get_throttled"
+
+// ErrUnsupported is returned by Read when neither the sysfs file nor
+// vcgencmd are available, e.g. when not running on a Raspberry Pi
+var ErrUnsupported = errors.New("get_throttled is unavailable")
+
+// Read returns the current flags, from sysfs if possible and otherwise from
+// vcgencmd
+func Read(ctx context.Context) (Flags, error) {
+	if b, err := os.ReadFile(sysfs); err == nil {
+		return parse(strings.TrimSpace(string(b)))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read %s: %w", sysfs, err)
+	}
+
+	out, err := exec.CommandContext(ctx, "vcgencmd", "get_throttled").Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return 0, ErrUnsupported
+	} else if err != nil {
+		return 0, fmt.Errorf("vcgencmd failed: %w", err)
+	}
+	// e.g. "throttled=0x50005"
+	v := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(v, "throttled=") {
+		return 0, fmt.Errorf("unexpected vcgencmd output %q", v)
+	}
+	return parse(strings.TrimPrefix(v, "throttled="))
+}
+
+// parse parses a hexadecimal get_throttled value, with or without 0x
+func parse(s string) (Flags, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid get_throttled value %q", s)
+	}
+	return Flags(n), nil
+}
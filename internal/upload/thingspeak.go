@@ -0,0 +1,71 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// ThingSpeakURL is the default ThingSpeak update endpoint
+const ThingSpeakURL = "https://api.thingspeak.com/update"
+
+// ThingSpeak uploads readings to a ThingSpeak channel
+type ThingSpeak struct {
+	// URL defaults to ThingSpeakURL
+	URL string
+
+	// APIKey is the channel's write API key
+	APIKey string
+
+	// TemperatureField and HumidityField are the channel field numbers
+	// (1-8) for each reading; 0 skips that reading.
+	TemperatureField, HumidityField int
+}
+
+// Write uploads s to the channel
+func (t *ThingSpeak) Write(ctx context.Context, s state.State) error {
+	form := url.Values{
+		"api_key":    {t.APIKey},
+		"created_at": {s.LastSensorUpdate.UTC().Format(time.RFC3339)},
+	}
+	if t.TemperatureField != 0 {
+		form.Set(fmt.Sprintf("field%d", t.TemperatureField), fmt.Sprintf("%.1f", s.Temperature))
+	}
+	if t.HumidityField != 0 {
+		form.Set(fmt.Sprintf("field%d", t.HumidityField), fmt.Sprintf("%.1f", s.Humidity))
+	}
+
+	u := t.URL
+	if u == "" {
+		u = ThingSpeakURL
+	}
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// ThingSpeak responds with the new entry ID, or 0 if the update was
+	// rejected (e.g. due to rate limiting)
+	body, err := do(ctx, req)
+	if err != nil {
+		return err
+	}
+	if body == "0" {
+		return fmt.Errorf("ThingSpeak rejected update (rate limited?)")
+	}
+	return nil
+}
+
+// ValidateField checks that f is a valid ThingSpeak field number, or 0
+func ValidateField(f int) error {
+	if f < 0 || f > 8 {
+		return fmt.Errorf("invalid ThingSpeak field %d, must be 1-8 (or 0 to disable)", f)
+	}
+	return nil
+}
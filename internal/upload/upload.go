@@ -0,0 +1,50 @@
+// Package upload implements uploaders of readings to third-party services.
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient is used by all uploaders
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// do performs req, returning the response body, or an error for non-2xx
+// responses. Errors don't include req's query, which may hold credentials.
+func do(ctx context.Context, req *http.Request) (string, error) {
+	req.Header.Set("User-Agent", "pitemp")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		// A *url.Error would include the full URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("%s %s: %w", req.Method, redacted(req.URL), err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%s %s: %s: %s", req.Method, redacted(req.URL), resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// redacted returns u without its query or userinfo, for errors; some
+// services take credentials in the query, e.g. Weather Underground's
+// PASSWORD
+func redacted(u *url.URL) string {
+	c := *u
+	c.User = nil
+	c.RawQuery = ""
+	c.ForceQuery = false
+	return c.String()
+}
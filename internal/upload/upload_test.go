@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func TestWundergroundErrorsHideKey(t *testing.T) {
+	const key = "secret-station-key"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	s := state.State{Temperature: 21, Humidity: 40, LastSensorUpdate: time.Now()}
+	for _, tc := range []struct{ name, url string }{
+		{"error status", ts.URL},
+		{"unreachable", "http://127.0.0.1:1/update"},
+		{"invalid URL", ts.URL + "/%zz"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := Wunderground{URL: tc.url, StationID: "KTEST1", StationKey: key}
+			err := w.Write(context.Background(), s)
+			if err == nil {
+				t.Fatal("Write succeeded, want an error")
+			}
+			if strings.Contains(err.Error(), key) {
+				t.Errorf("Error %q includes the station key", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// WundergroundURL is the default endpoint for the Weather Underground
+// personal weather station (PWS) upload protocol
+const WundergroundURL = "https://weatherstation.wunderground.com/weatherstation/updateweatherstation.php"
+
+// Wunderground uploads readings using the Weather Underground PWS protocol,
+// which other weather networks accept as well.
+type Wunderground struct {
+	// URL defaults to WundergroundURL
+	URL string
+
+	StationID, StationKey string
+}
+
+// Write uploads s as an observation from the station
+func (w *Wunderground) Write(ctx context.Context, s state.State) error {
+	params := url.Values{
+		"ID":           {w.StationID},
+		"PASSWORD":     {w.StationKey},
+		"action":       {"updateraw"},
+		"softwaretype": {"pitemp"},
+		"dateutc":      {s.LastSensorUpdate.UTC().Format("2006-01-02 15:04:05")},
+		"tempf":        {fmt.Sprintf("%.1f", fahrenheit(float64(s.Temperature)))},
+		"humidity":     {fmt.Sprintf("%.0f", s.Humidity)},
+	}
+	if s.Humidity > 0 {
+		dewPoint := dewPoint(float64(s.Temperature), float64(s.Humidity))
+		params.Set("dewptf", fmt.Sprintf("%.1f", fahrenheit(dewPoint)))
+	}
+
+	rawURL := w.URL
+	if rawURL == "" {
+		rawURL = WundergroundURL
+	}
+	// Parsed before adding the query, so that errors don't include the
+	// station key
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.New("invalid upload request")
+	}
+
+	// Errors are reported with a 200 status and a message in the body
+	body, err := do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	if body != "success" {
+		return fmt.Errorf("upload rejected: %q", body)
+	}
+	return nil
+}
+
+func fahrenheit(celsius float64) float64 {
+	return celsius*9/5 + 32
+}
+
+// dewPoint approximates the dew point in °C using the Magnus formula
+func dewPoint(celsius, humidity float64) float64 {
+	const b, c = 17.62, 243.12
+	gamma := math.Log(humidity/100) + b*celsius/(c+celsius)
+	return c * gamma / (b - gamma)
+}
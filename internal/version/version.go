@@ -0,0 +1,88 @@
+// Package version reports the version of the running binary.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Version and BuildDate are set at build time, e.g.:
+//
+//	go build -ldflags "-X github.com/lutzky/pitemp/internal/version.Version=v1.2.3"
+var (
+	Version   string
+	BuildDate string
+)
+
+// Get returns the version set at build time, falling back to the module
+// version recorded by the Go toolchain (e.g. when installed with go
+// install), or "dev".
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetInfo returns the version along with the VCS revision and build date.
+// These come from the build info embedded by the Go toolchain, except for a
+// build date set at build time; otherwise, the commit time is used.
+func GetInfo() Info {
+	info := Info{
+		Version:   Get(),
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// String formats i on one line, e.g. "v1.2.3 (revision 0123abc, built
+// 2024-01-02T03:04:05Z, go1.21.5)"
+func (i Info) String() string {
+	var details []string
+	if i.Revision != "" {
+		rev := i.Revision
+		if len(rev) > 12 {
+			rev = rev[:12]
+		}
+		if i.Modified {
+			rev += "-dirty"
+		}
+		details = append(details, "revision "+rev)
+	}
+	if i.BuildDate != "" {
+		details = append(details, "built "+i.BuildDate)
+	}
+	details = append(details, i.GoVersion)
+	return fmt.Sprintf("%s (%s)", i.Version, strings.Join(details, ", "))
+}
@@ -0,0 +1,52 @@
+// Package wifi reads the signal level of wireless interfaces, from
+// /proc/net/wireless. A weak signal is the usual reason for a node going
+// stale, or a display showing its server as unreachable.
+package wifi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNotWireless is returned by Signal for interfaces which aren't listed
+// in /proc/net/wireless, e.g. wired ones, or wireless ones which are down
+var ErrNotWireless = errors.New("not a wireless interface")
+
+// procWireless lists the wireless interfaces and their link quality
+const procWireless = "/proc/net/wireless"
+
+// Signal returns the signal level of iface, in dBm (e.g. -56; above -67 is
+// good, below -80 unreliable)
+func Signal(iface string) (float64, error) {
+	f, err := os.Open(procWireless)
+	if errors.Is(err, os.ErrNotExist) {
+		// No wireless interfaces at all
+		return 0, ErrNotWireless
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// After two header lines, e.g.
+	// " wlan0: 0000   54.  -56.  -256        0      0      0      0      0        0"
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != iface+":" {
+			continue
+		}
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[3], "."), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid signal level %q for %s", fields[3], iface)
+		}
+		return level, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, ErrNotWireless
+}
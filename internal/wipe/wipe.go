@@ -0,0 +1,79 @@
+// Package wipe securely deletes files, for decommissioning a node without
+// leaving its accumulated data behind.
+package wipe
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File overwrites the contents of the file at path with random data, syncs
+// it to disk and removes it. A missing file is not an error.
+//
+// Note that on flash storage (such as SD cards), wear leveling means that
+// overwritten blocks may survive physically; this is a best-effort measure.
+func File(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat %q: %w", path, err)
+	}
+
+	if _, err := io.CopyN(f, rand.Reader, info.Size()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to overwrite %q: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %q: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %q: %w", path, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove %q: %w", path, err)
+	}
+	return nil
+}
+
+// Dir wipes every regular file under dir using File, then removes dir and
+// everything left in it. A missing dir is not an error. The paths of wiped
+// files are passed to progress, if it isn't nil.
+func Dir(dir string, progress func(path string)) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if progress != nil {
+			progress(path)
+		}
+		return File(path)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove %q: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package zabbix sends readings to a Zabbix server or proxy using the
+// zabbix_sender protocol, for Zabbix trapper items.
+package zabbix
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// maxResponseSize bounds the size of the server's response
+const maxResponseSize = 1 << 16
+
+// Sender sends readings as trapper item values
+type Sender struct {
+	// Addr is the Zabbix server or proxy's trapper address, e.g.
+	// zabbix:10051
+	Addr string
+
+	// Host is the host name as configured in Zabbix
+	Host string
+
+	// KeyPrefix is prepended to item keys, e.g. "pitemp" for
+	// pitemp.temperature and pitemp.humidity
+	KeyPrefix string
+}
+
+type item struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+type request struct {
+	Request string `json:"request"`
+	Data    []item `json:"data"`
+}
+
+type response struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// header precedes each message: "ZBXD", protocol flags (0x01 for the
+// standard protocol), and the little-endian 64-bit data length.
+var header = []byte("ZBXD\x01")
+
+// failedRE extracts the number of failed items from a response's info
+var failedRE = regexp.MustCompile(`failed: (\d+)`)
+
+// Write sends s as values for the temperature and humidity items
+func (z *Sender) Write(ctx context.Context, s state.State) error {
+	clock := s.LastSensorUpdate.Unix()
+	req := request{
+		Request: "sender data",
+		Data: []item{
+			{z.Host, z.KeyPrefix + ".temperature", strconv.FormatFloat(float64(s.Temperature), 'f', 1, 32), clock},
+			{z.Host, z.KeyPrefix + ".humidity", strconv.FormatFloat(float64(s.Humidity), 'f', 1, 32), clock},
+		},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", z.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %q: %w", z.Addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := make([]byte, len(header)+8, len(header)+8+len(data))
+	copy(msg, header)
+	binary.LittleEndian.PutUint64(msg[len(header):], uint64(len(data)))
+	msg = append(msg, data...)
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+
+	resp, err := readResponse(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.Response != "success" {
+		return fmt.Errorf("server responded %q: %s", resp.Response, resp.Info)
+	}
+	if m := failedRE.FindStringSubmatch(resp.Info); m != nil && m[1] != "0" {
+		return fmt.Errorf("server rejected items (are they configured as trapper items for host %q?): %s", z.Host, resp.Info)
+	}
+	return nil
+}
+
+func readResponse(r io.Reader) (*response, error) {
+	h := make([]byte, len(header)+8)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	if string(h[:4]) != "ZBXD" {
+		return nil, fmt.Errorf("invalid response header")
+	}
+	n := binary.LittleEndian.Uint64(h[len(header):])
+	if n > maxResponseSize {
+		return nil, fmt.Errorf("response too large (%d bytes)", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
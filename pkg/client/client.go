@@ -0,0 +1,813 @@
+// Package client fetches the state from pitemp servers, e.g. to show it on a
+// display.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/sync"
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+// Defaults for Options
+const (
+	DefaultFetchInterval    = time.Minute
+	DefaultUpdateInterval   = time.Second
+	DefaultForecastInterval = 30 * time.Minute
+	DefaultFetchTimeout     = 10 * time.Second
+	DefaultFetchRetries     = 2
+	DefaultFetchBackoff     = time.Second
+	DefaultPageInterval     = 5 * time.Second
+	DefaultUnreachableAfter = 3
+	DefaultShutdownTimeout  = 10 * time.Second
+)
+
+// These are set by Run from its Options, with the defaults applied
+var (
+	fetchTimeout     = DefaultFetchTimeout
+	fetchRetries     = DefaultFetchRetries
+	fetchBackoff     = DefaultFetchBackoff
+	fetchJitter      time.Duration
+	pageInterval     = DefaultPageInterval
+	unreachableAfter = DefaultUnreachableAfter
+	sourceLabel      string
+	slowdown         func() int
+)
+
+// maxBackoff caps the delay between retries
+const maxBackoff = 30 * time.Second
+
+// token is sent as a bearer token, if set
+var token string
+
+// httpClient is used for requests to servers
+var httpClient *http.Client
+
+// source is the 1-based index of the server state was last fetched from
+var source int32
+
+// Source returns the 1-based index of the server the current state was
+// fetched from (1 being the primary), or 0 if no state was fetched yet.
+func Source() int {
+	return int(atomic.LoadInt32(&source))
+}
+
+// failures is the number of consecutive fetches that failed for all servers
+var failures int32
+
+// Failures returns the number of consecutive fetches that failed for all
+// servers, or 0 if the last fetch succeeded.
+func Failures() int {
+	return int(atomic.LoadInt32(&failures))
+}
+
+// Unreachable returns true if the last Options.UnreachableAfter fetches
+// failed, meaning the state shown is out of date.
+func Unreachable() bool {
+	return Failures() >= unreachableAfter
+}
+
+// Room is a pitemp API server shown on a page of its own, labeled with its
+// name.
+type Room struct {
+	Name, URL string
+}
+
+// ParseRooms parses a comma-separated list of NAME=URL rooms
+func ParseRooms(s string) ([]Room, error) {
+	var rooms []Room
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid --rooms entry %q, expected NAME=URL", entry)
+		}
+		rooms = append(rooms, Room{parts[0], parts[1]})
+	}
+	return rooms, nil
+}
+
+// Page is a page of the display
+type Page struct {
+	// Label names the source of the state; it may be empty for the main
+	// page
+	Label string
+
+	// Main is true for the main page, showing the state from the servers
+	Main bool
+
+	// Local is true if the main page shows the local sensor, because the
+	// servers are unreachable
+	Local bool
+
+	// System is true for the page showing stats about the display's host,
+	// in State.System (nil until they're first read)
+	System bool
+
+	// Forecast is true for the page showing the weather forecast, in
+	// Weather (nil until it's first fetched), alongside the main page's
+	// state
+	Forecast bool
+	Weather  *Weather
+
+	// Compare is true for the page comparing the main page's state, from
+	// indoors, with Outside: the state of the outdoor room, or the
+	// forecast's outdoor temperature
+	Compare bool
+	Outside state.State
+
+	// Sun is true for the page showing today's sunrise and sunset, in
+	// Daylight (nil until this host's clock is valid)
+	Sun      bool
+	Daylight *Daylight
+
+	// Web is true for the page showing URL, the web UI's, so that visitors
+	// can open it on their phones; URL is empty while unknown, e.g. without
+	// an IP address
+	Web bool
+	URL string
+
+	State state.State
+
+	// Message is the custom message to show, if its Text isn't empty
+	Message Message
+}
+
+var (
+	// mainServers are the servers for the main page
+	mainServers []string
+
+	// rooms are the names of the rooms to rotate through after the main page
+	rooms []string
+
+	// locations holds the location (if any) reported by each server, as a
+	// map[string]string. It is replaced rather than modified.
+	locations atomic.Value
+
+	// local holds the last reading of the local sensor, as a *state.State
+	local atomic.Value
+
+	// outdoor is the room compared with the main page, or OutdoorForecast;
+	// empty for no comparison page
+	outdoor string
+
+	// showForecast is true if the forecast page is shown after the rooms
+	showForecast bool
+
+	// weather holds the last Weather fetched for the forecast page, as a
+	// *Weather
+	weather atomic.Value
+
+	// sun returns the sunrise and sunset on the day of a time, for the sun
+	// page after the rooms (and the forecast page); nil for no sun page
+	sun func(time.Time) Daylight
+
+	// webURL returns the URL of the web UI, for the web page after the sun
+	// page; nil for no web page
+	webURL func() string
+
+	// showSystem is true if the system page is shown after the rooms (and
+	// the forecast, sun and web pages)
+	showSystem bool
+
+	// system holds the last stats read for the system page, as a
+	// *state.System
+	system atomic.Value
+
+	// pageOffset is added to the page due by the rotation, as set by
+	// NextPage
+	pageOffset int64
+
+	// refresh is signalled by Refresh
+	refresh = make(chan struct{}, 1)
+)
+
+// Current returns the page to show now, with the message set by SetMessage
+// if any. Pages change every Options.PageInterval.
+func Current() Page {
+	p := currentPage()
+	p.Message = currentMessage(time.Now())
+	return p
+}
+
+func currentPage() Page {
+	pages := len(rooms) + 1
+	for _, shown := range []bool{outdoor != "", showForecast, sun != nil, webURL != nil, showSystem} {
+		if shown {
+			pages++
+		}
+	}
+	i := 0
+	if pages > 1 {
+		i = int((time.Now().UnixNano()/int64(pageInterval) + atomic.LoadInt64(&pageOffset)) % int64(pages))
+	}
+
+	if i == 0 {
+		return Main()
+	}
+	i--
+	if outdoor != "" {
+		if i == 0 {
+			return comparePage()
+		}
+		i--
+	}
+	if i < len(rooms) {
+		return Page{Label: rooms[i], State: state.Sources()[rooms[i]]}
+	}
+	i -= len(rooms)
+	if showForecast {
+		if i == 0 {
+			p := Main()
+			p.Forecast, p.Main = true, false
+			if w, _ := weather.Load().(*Weather); w != nil {
+				w := *w
+				p.Weather = &w
+			}
+			return p
+		}
+		i--
+	}
+	if sun != nil {
+		if i == 0 {
+			return sunPage()
+		}
+		i--
+	}
+	if webURL != nil && i == 0 {
+		return Page{Label: "web", Web: true, URL: webURL()}
+	}
+	p := Page{Label: "system", System: true}
+	if s, _ := system.Load().(*state.System); s != nil {
+		s := *s
+		p.State.System = &s
+	}
+	return p
+}
+
+// comparePage returns the page comparing the main page's state with the
+// outdoor room's, or the forecast's
+func comparePage() Page {
+	p := Main()
+	p.Compare, p.Main = true, false
+	if outdoor != OutdoorForecast {
+		p.Outside = state.Sources()[outdoor]
+		return p
+	}
+	if w, _ := weather.Load().(*Weather); w != nil {
+		// The forecast's estimate of the current temperature is as good
+		// now as when it was fetched, so it's never stale
+		p.Outside.SetReading("temperature", state.Reading{Value: float32(w.Temperature), Unit: "celsius", Sensor: "forecast", MeasuredAt: time.Now()})
+	}
+	return p
+}
+
+// NextPage switches to the next page now, rather than at the end of
+// --page_interval; the rotation carries on from there. Call Refresh to show
+// it immediately.
+func NextPage() {
+	atomic.AddInt64(&pageOffset, 1)
+}
+
+// Refresh updates the display now, rather than at the next update interval
+// or state change, e.g. after NextPage
+func Refresh() {
+	select {
+	case refresh <- struct{}{}:
+	default:
+		// A refresh is already pending
+	}
+}
+
+// Main returns the main page, showing the state from the servers, or from the
+// local sensor (if any) while the servers are unreachable.
+func Main() Page {
+	if s, _ := local.Load().(*state.State); s != nil && Unreachable() {
+		return Page{Label: "local", Main: true, Local: true, State: *s}
+	}
+
+	p := Page{Label: sourceLabel, Main: true, State: state.Get()}
+	if source := Source(); p.Label == "" && source > 0 {
+		m, _ := locations.Load().(map[string]string)
+		p.Label = m[mainServers[source-1]]
+	}
+	return p
+}
+
+// setLocation records the location reported by server
+func setLocation(server, location string) {
+	old, _ := locations.Load().(map[string]string)
+	if old[server] == location {
+		return
+	}
+	m := map[string]string{server: location}
+	for k, v := range old {
+		if k != server {
+			m[k] = v
+		}
+	}
+	locations.Store(m)
+}
+
+// ParseServers splits a comma-separated list of server URLs
+func ParseServers(s string) []string {
+	var servers []string
+	for _, server := range strings.Split(s, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// schedule returns the options for scheduling fetches and updates
+func schedule() []sync.RepeatOption {
+	if slowdown == nil {
+		return nil
+	}
+	return []sync.RepeatOption{sync.WithSlowdown(slowdown)}
+}
+
+// Fetcher fetches the state from server
+type Fetcher func(ctx context.Context, server string) (*state.State, error)
+
+// Options configure Run
+type Options struct {
+	// Servers are tried in order, so later servers are only used while
+	// earlier ones are unreachable
+	Servers []string
+
+	// Rooms are shown on pages of their own
+	Rooms []Room
+
+	// Update is run to refresh a display whenever the state changes, and
+	// otherwise every UpdateInterval (e.g. to tick the clock). An error
+	// stops the client, and is returned by Run.
+	Update func() error
+
+	// FetchInterval (default DefaultFetchInterval) is how often the state
+	// is fetched, and UpdateInterval (default DefaultUpdateInterval) how
+	// often Update is run regardless
+	FetchInterval, UpdateInterval time.Duration
+
+	// HTTPClient is used to fetch state and receive updates; if nil, a
+	// client with a timeout of FetchTimeout is used
+	HTTPClient *http.Client
+
+	// Token, if set, is sent to servers as a bearer token
+	Token string
+
+	// FetchTimeout bounds each attempt to fetch state from a server
+	// (default DefaultFetchTimeout). Failed attempts are retried
+	// FetchRetries times (default DefaultFetchRetries; negative for none),
+	// with exponential backoff starting at FetchBackoff (default
+	// DefaultFetchBackoff), before failing over to the next server.
+	FetchTimeout time.Duration
+	FetchRetries int
+	FetchBackoff time.Duration
+
+	// FetchJitter, if set, delays each poll by a random extra duration of
+	// up to this much, so that many displays started together (e.g. after
+	// a power cut) don't poll the servers in lockstep
+	FetchJitter time.Duration
+
+	// Push subscribes to updates from the primary server over a WebSocket,
+	// rather than only polling it every FetchInterval; polling resumes
+	// while disconnected
+	Push bool
+
+	// AlignUpdates ticks the display at wall-clock multiples of
+	// UpdateInterval (e.g. on the second), keeping a displayed clock in
+	// step; new state is shown as soon as it arrives regardless
+	AlignUpdates bool
+
+	// SourceLabel labels the main page (e.g. garage); if empty, it's
+	// labeled with the location reported by the server, if any
+	SourceLabel string
+
+	// PageInterval is how long each page is shown, when there are several
+	// (default DefaultPageInterval)
+	PageInterval time.Duration
+
+	// UnreachableAfter is how many consecutive fetches must fail (from all
+	// servers) for the servers to count as unreachable (default
+	// DefaultUnreachableAfter)
+	UnreachableAfter int
+
+	// Slowdown, if set, returns how many times less often to fetch and
+	// update, e.g. in a low-power mode
+	Slowdown func() int
+
+	// ShutdownTimeout bounds waiting for any fetch or update in progress
+	// when stopping (default DefaultShutdownTimeout)
+	ShutdownTimeout time.Duration
+
+	// Fetch, if set, replaces fetching state over HTTP, and disables Push
+	Fetch Fetcher
+
+	// Local, if set, reads a local sensor, which is shown instead of stale
+	// state while the servers are unreachable
+	Local func(ctx context.Context) (*state.State, error)
+
+	// Outdoor, if set, adds a page after the main page comparing it with
+	// outdoors: the room of that name, or the forecast's outdoor
+	// temperature for OutdoorForecast
+	Outdoor string
+
+	// Forecast, if set, fetches the weather forecast every
+	// ForecastInterval (default DefaultForecastInterval), which is shown on
+	// a page of its own after the rooms
+	Forecast         func(ctx context.Context) (Weather, error)
+	ForecastInterval time.Duration
+
+	// Sun, if set, returns the sunrise and sunset on the day of a time,
+	// which are shown on a page of their own after the rooms (and the
+	// forecast page)
+	Sun func(time.Time) Daylight
+
+	// WebURL, if set, returns the URL of the web UI, or "" while unknown,
+	// which is shown on a page of its own after the sun page: as a QR code,
+	// on displays which can show one
+	WebURL func() string
+
+	// System, if set, reads stats about the display's host, which are shown
+	// on a page of their own after the rooms (and the forecast, sun and web
+	// pages)
+	System func() (state.System, error)
+}
+
+// fetch fetches the state from server, reporting whether it changed since
+// the last fetch
+var fetch = fetchFrom
+
+// Run fetches the state every opts.FetchInterval, and runs opts.Update
+// whenever it changes and every opts.UpdateInterval. With opts.Push,
+// updates from the primary server are received as they happen, and polling
+// only takes over while that connection is down. It does so until the
+// context is cancelled or opts.Update fails, and then waits (up to
+// opts.ShutdownTimeout) for any fetch or update in progress, so that the
+// display can safely be cleaned up afterwards. It returns the first error
+// that stopped it, if any.
+//
+// The pages shown (see Current) are the package's, so only one Run may be
+// active at a time; each starts afresh.
+func Run(ctx context.Context, opts Options) error {
+	if len(opts.Servers) == 0 {
+		return errors.New("no servers")
+	}
+	if opts.Update == nil {
+		return errors.New("no update function")
+	}
+	opts, err := withDefaults(opts)
+	if err != nil {
+		return err
+	}
+	if err := setOutdoor(opts); err != nil {
+		return err
+	}
+	applyOptions(opts)
+
+	workers, ctx := sync.WithContext(ctx)
+
+	workers.Supervise(ctx, "fetch", func() {
+		sync.RepeatUntilCancelled(ctx, func() {
+			fetchState(ctx, opts.Servers)
+			fetchRooms(ctx, opts.Rooms)
+			if opts.Local != nil && Unreachable() {
+				readLocal(ctx, opts.Local)
+			}
+		}, opts.FetchInterval, append(schedule(), sync.WithJitter(fetchJitter))...)
+	})
+	if opts.Forecast != nil {
+		workers.Supervise(ctx, "forecast", func() {
+			sync.RepeatUntilCancelled(ctx, func() { readForecast(ctx, opts.Forecast) }, opts.ForecastInterval)
+		})
+	}
+	if opts.System != nil {
+		workers.Supervise(ctx, "system", func() {
+			sync.RepeatUntilCancelled(ctx, func() { readSystem(opts.System) }, pageInterval)
+		})
+	}
+	if opts.Push && opts.Fetch == nil {
+		workers.Supervise(ctx, "push", func() { subscribe(ctx, opts.Servers[0]) })
+	}
+	changes := make(chan struct{}, 1)
+	workers.Go(func() { forwardChanges(ctx, changes) })
+	workers.Run("display", func() error {
+		ctx, stop := context.WithCancel(ctx)
+		defer stop()
+		var err error
+		sync.Supervise(ctx, "display", func() {
+			sync.RepeatUntilCancelled(ctx, func() {
+				if err = opts.Update(); err != nil {
+					stop()
+				}
+			}, opts.UpdateInterval, append(updateSchedule(opts.AlignUpdates), sync.WithTrigger(changes))...)
+		})
+		return err
+	})
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+	if err := workers.Wait(shutdownCtx); err != nil {
+		return fmt.Errorf("gave up waiting for client to stop: %w", err)
+	}
+	return workers.Err()
+}
+
+// forwardChanges signals changes whenever the state changes or Refresh is
+// called, until ctx is done, so that new readings are shown immediately
+// rather than on the next update
+func forwardChanges(ctx context.Context, changes chan<- struct{}) {
+	states, cancel := state.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-states:
+		case <-refresh:
+		}
+		select {
+		case changes <- struct{}{}:
+		default:
+			// An update is already pending
+		}
+	}
+}
+
+// withDefaults returns opts with the defaults for those not set, or an error
+// if any is invalid
+func withDefaults(opts Options) (Options, error) {
+	durations := []struct {
+		name string
+		d    *time.Duration
+		def  time.Duration
+	}{
+		{"fetch interval", &opts.FetchInterval, DefaultFetchInterval},
+		{"update interval", &opts.UpdateInterval, DefaultUpdateInterval},
+		{"forecast interval", &opts.ForecastInterval, DefaultForecastInterval},
+		{"fetch timeout", &opts.FetchTimeout, DefaultFetchTimeout},
+		{"fetch backoff", &opts.FetchBackoff, DefaultFetchBackoff},
+		{"page interval", &opts.PageInterval, DefaultPageInterval},
+		{"shutdown timeout", &opts.ShutdownTimeout, DefaultShutdownTimeout},
+	}
+	for _, o := range durations {
+		switch {
+		case *o.d < 0:
+			return opts, fmt.Errorf("%s must not be negative, got %v", o.name, *o.d)
+		case *o.d == 0:
+			*o.d = o.def
+		}
+	}
+	if opts.FetchJitter < 0 {
+		return opts, fmt.Errorf("fetch jitter must not be negative, got %v", opts.FetchJitter)
+	}
+	switch {
+	case opts.FetchRetries < 0:
+		opts.FetchRetries = 0
+	case opts.FetchRetries == 0:
+		opts.FetchRetries = DefaultFetchRetries
+	}
+	switch {
+	case opts.UnreachableAfter < 0:
+		return opts, fmt.Errorf("unreachable after must not be negative, got %v", opts.UnreachableAfter)
+	case opts.UnreachableAfter == 0:
+		opts.UnreachableAfter = DefaultUnreachableAfter
+	}
+	return opts, nil
+}
+
+// applyOptions sets the package's settings from opts, which has the
+// defaults applied, resetting what was left by any previous Run
+func applyOptions(opts Options) {
+	token = opts.Token
+	fetchTimeout = opts.FetchTimeout
+	fetchBackoff = opts.FetchBackoff
+	fetchRetries = opts.FetchRetries
+	fetchJitter = opts.FetchJitter
+	pageInterval = opts.PageInterval
+	unreachableAfter = opts.UnreachableAfter
+	sourceLabel = opts.SourceLabel
+	slowdown = opts.Slowdown
+
+	httpClient = opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: fetchTimeout}
+	}
+	fetch = fetchFrom
+	if opts.Fetch != nil {
+		fetch = func(ctx context.Context, server string) (*state.State, bool, error) {
+			s, err := opts.Fetch(ctx, server)
+			return s, true, err
+		}
+	}
+	cache = map[string]*cachedResponse{}
+
+	mainServers = opts.Servers
+	rooms = nil
+	for _, r := range opts.Rooms {
+		rooms = append(rooms, r.Name)
+	}
+	showForecast = opts.Forecast != nil
+	showSystem = opts.System != nil
+	sun, webURL = opts.Sun, opts.WebURL
+
+	atomic.StoreInt32(&source, 0)
+	atomic.StoreInt32(&failures, 0)
+	locations.Store(map[string]string{})
+	local.Store((*state.State)(nil))
+	weather.Store((*Weather)(nil))
+	system.Store((*state.System)(nil))
+}
+
+// updateSchedule returns the options for scheduling updates
+func updateSchedule(aligned bool) []sync.RepeatOption {
+	opts := schedule()
+	if aligned {
+		opts = append(opts, sync.Aligned())
+	}
+	return opts
+}
+
+func fetchState(ctx context.Context, servers []string) {
+	if subscribed() {
+		slog.Debug("Not fetching state, receiving updates from server")
+		return
+	}
+
+	slog.Debug("Fetching state")
+	for i, server := range servers {
+		s, changed, err := fetchWithRetry(ctx, server)
+		if err != nil {
+			slog.Error("Failed to fetch state", "server", server, "err", err)
+			continue
+		}
+		received(i, server, s, changed)
+		return
+	}
+
+	if n := atomic.AddInt32(&failures, 1); int(n) == unreachableAfter {
+		slog.Warn("Servers unreachable, showing state as out of date", "failures", n)
+	}
+}
+
+// fetchRooms fetches the state of each room; rooms that can't be reached
+// keep showing their last state, which eventually shows as stale.
+func fetchRooms(ctx context.Context, rooms []Room) {
+	for _, r := range rooms {
+		s, changed, err := fetchWithRetry(ctx, r.URL)
+		if err != nil {
+			slog.Error("Failed to fetch room state", "room", r.Name, "server", r.URL, "err", err)
+			continue
+		}
+		if changed {
+			state.SetSource(r.Name, *s)
+		}
+	}
+}
+
+// readSystem reads the stats for the system page using read
+func readSystem(read func() (state.System, error)) {
+	s, err := read()
+	if err != nil {
+		slog.Error("Failed to read system stats", "err", err)
+		return
+	}
+	system.Store(&s)
+}
+
+// readLocal reads the local sensor using read
+func readLocal(ctx context.Context, read func(ctx context.Context) (*state.State, error)) {
+	s, err := read(ctx)
+	if err != nil {
+		slog.Error("Failed to read local sensor", "err", err)
+		return
+	}
+	slog.Debug("Read local sensor", "temperature", s.Temperature, "humidity", s.Humidity)
+	copied := *s
+	local.Store(&copied)
+}
+
+// received shows state s received from the i'th server (0 being the
+// primary), if it changed since it was last received.
+func received(i int, server string, s *state.State, changed bool) {
+	if prev := atomic.SwapInt32(&source, int32(i+1)); prev != int32(i+1) {
+		slog.Info("Now showing state", "server", server)
+		changed = true
+	}
+	if prev := atomic.SwapInt32(&failures, 0); int(prev) >= unreachableAfter {
+		slog.Info("Servers reachable again", "failures", prev)
+	}
+	if changed {
+		state.Set(s)
+	}
+}
+
+// fetchWithRetry fetches state from server, retrying failed attempts with
+// exponential backoff up to Options.FetchRetries times.
+func fetchWithRetry(ctx context.Context, server string) (*state.State, bool, error) {
+	backoff := fetchBackoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		start := time.Now()
+		s, changed, err := fetch(attemptCtx, server)
+		cancel()
+		observeFetch(server, start, changed, err)
+		if err == nil || attempt >= fetchRetries || ctx.Err() != nil {
+			return s, changed, err
+		}
+
+		slog.Debug("Retrying fetch", "server", server, "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// cachedResponse is the last state fetched from a server, along with the
+// validators for conditional requests.
+type cachedResponse struct {
+	etag, lastModified string
+	state              state.State
+}
+
+// cache holds the last response from each server. It is only accessed by
+// fetchState and fetchRooms, which run in turn on a single goroutine.
+var cache = map[string]*cachedResponse{}
+
+// fetchFrom fetches state from server, reporting whether it changed since the
+// last fetch from that server. Requests are conditional, so that unchanged
+// state costs neither a response body nor decoding it.
+func fetchFrom(ctx context.Context, server string) (*state.State, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	cached := cache[server]
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		s := cached.state
+		return &s, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var w wire.State
+	if err := json.NewDecoder(resp.Body).Decode(&w); err != nil {
+		return nil, false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	checkSchema(server, w.SchemaVersion)
+	setLocation(server, w.Location)
+	s := w.State()
+	cache[server] = &cachedResponse{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		state:        s,
+	}
+	return &s, true, nil
+}
+
+// warnedSchema is 1 once a newer schema was warned about
+var warnedSchema int32
+
+// checkSchema warns (once) if server uses a newer schema than this client
+// knows, in which case the state may be incomplete.
+func checkSchema(server string, version int) {
+	if version > wire.SchemaVersion && atomic.CompareAndSwapInt32(&warnedSchema, 0, 1) {
+		slog.Warn("Server uses a newer schema, consider upgrading", "server", server, "schema_version", version, "supported", wire.SchemaVersion)
+	}
+}
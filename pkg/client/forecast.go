@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Weather is the outdoor weather and tomorrow's forecast, as shown on the
+// forecast page. Temperatures are in °C.
+type Weather struct {
+	// Temperature is the current (or, depending on the provider, nearest
+	// forecast) outdoor temperature
+	Temperature float64
+
+	// High and Low are tomorrow's highest and lowest temperatures
+	High, Low float64
+
+	// RainChance is the chance of precipitation tomorrow, in percent
+	RainChance float64
+
+	// FetchedAt is when the forecast was fetched
+	FetchedAt time.Time
+}
+
+// readForecast fetches the weather for the forecast page using fetch
+func readForecast(ctx context.Context, fetch func(ctx context.Context) (Weather, error)) {
+	w, err := fetch(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch forecast", "err", err)
+		return
+	}
+	weather.Store(&w)
+}
+
+// OutdoorForecast is the Options.Outdoor for comparing the main page with
+// the forecast's outdoor temperature
+const OutdoorForecast = "forecast"
+
+// setOutdoor checks and sets opts.Outdoor
+func setOutdoor(opts Options) error {
+	switch {
+	case opts.Outdoor == "":
+	case opts.Outdoor == OutdoorForecast:
+		if opts.Forecast == nil {
+			return errors.New("comparing with the forecast's outdoor temperature requires a forecast")
+		}
+	default:
+		found := false
+		for _, r := range opts.Rooms {
+			found = found || r.Name == opts.Outdoor
+		}
+		if !found {
+			return fmt.Errorf("no room %q to compare with", opts.Outdoor)
+		}
+	}
+	outdoor = opts.Outdoor
+	return nil
+}
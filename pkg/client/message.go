@@ -0,0 +1,38 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Message is a custom message shown on every page, e.g. a note pushed by a
+// home automation system
+type Message struct {
+	Text string
+
+	// Line is the 1-based line of the display replaced by the message;
+	// displays with fewer lines show it on their last one
+	Line int
+
+	// Expires is when the message stops being shown, or zero to show it
+	// until it's replaced
+	Expires time.Time
+}
+
+// message holds the Message set by SetMessage
+var message atomic.Value
+
+// SetMessage shows m on every page, replacing any previous message; a
+// Message with empty Text clears it. Call Refresh to show it immediately.
+func SetMessage(m Message) {
+	message.Store(m)
+}
+
+// currentMessage returns the message to show at now, if any
+func currentMessage(now time.Time) Message {
+	m, _ := message.Load().(Message)
+	if !m.Expires.IsZero() && !now.Before(m.Expires) {
+		return Message{}
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/internal/sync"
+)
+
+var (
+	fetchesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pitemp_client_fetches_total",
+		Help: "Attempts to fetch state (including retries), by server and result",
+	}, []string{"server", "result"})
+	fetchDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pitemp_client_fetch_duration_seconds",
+		Help:    "Latency of attempts to fetch state, by server",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"server"})
+	lastFetchGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pitemp_client_last_fetch_timestamp_seconds",
+		Help: "Time state was last successfully fetched or received, by server",
+	}, []string{"server"})
+	renderErrorsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pitemp_client_render_errors_total",
+		Help: "Failures to update the display",
+	})
+)
+
+// RegisterMetrics registers the client's metrics with r
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{fetchesCounter, fetchDurationHistogram, lastFetchGauge, renderErrorsCounter} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	r = prometheus.WrapRegistererWithPrefix("pitemp_client_", r)
+	if err := r.Register(clock.SyncedGauge); err != nil {
+		return err
+	}
+	return r.Register(sync.RestartsCounter)
+}
+
+// RenderFailed counts a failure to update the display
+func RenderFailed() {
+	renderErrorsCounter.Inc()
+}
+
+// observeFetch records an attempt to fetch state from server
+func observeFetch(server string, start time.Time, changed bool, err error) {
+	server = serverLabel(server)
+	fetchDurationHistogram.WithLabelValues(server).Observe(time.Since(start).Seconds())
+	result := "success"
+	switch {
+	case err != nil:
+		result = "failure"
+	case !changed:
+		result = "not_modified"
+	}
+	fetchesCounter.WithLabelValues(server, result).Inc()
+	if err == nil {
+		lastFetchGauge.WithLabelValues(server).SetToCurrentTime()
+	}
+}
+
+// serverLabel returns server as a metric label, without any password
+func serverLabel(server string) string {
+	u, err := url.Parse(server)
+	if err != nil {
+		return server
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+// pingTimeout is how long to wait for the server's next ping (which it sends
+// every 30 seconds) before considering the connection dead.
+const pingTimeout = 75 * time.Second
+
+// subscribedFlag is 1 while receiving updates from the primary server
+var subscribedFlag int32
+
+// subscribed returns true while receiving updates from the primary server,
+// so there's no need to poll it.
+func subscribed() bool {
+	return atomic.LoadInt32(&subscribedFlag) == 1
+}
+
+// subscribe receives state pushed by server over its WebSocket (/ws), until
+// the context is cancelled. Dropped connections are retried with exponential
+// backoff, and polling takes over in the meantime.
+func subscribe(ctx context.Context, server string) {
+	u, err := wsURL(server)
+	if err != nil {
+		slog.Error("Not subscribing to updates", "server", server, "err", err)
+		return
+	}
+
+	backoff := fetchBackoff
+	for {
+		start := time.Now()
+		err := receive(ctx, u, server)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Not receiving updates, polling instead", "server", server, "err", err)
+
+		// Only back off further if the connection didn't last
+		if time.Since(start) > maxBackoff {
+			backoff = fetchBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// receive connects to the WebSocket at u and shows the state it pushes, until
+// the connection fails or the context is cancelled.
+func receive(ctx context.Context, u, server string) error {
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: fetchTimeout,
+	}
+	if t, ok := httpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = t.TLSClientConfig
+	}
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, u, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("%w (%s)", err, resp.Status)
+		}
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock ReadJSON when cancelled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(pingTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(pingTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(fetchTimeout))
+	})
+
+	slog.Info("Receiving updates", "server", server)
+	atomic.StoreInt32(&subscribedFlag, 1)
+	defer atomic.StoreInt32(&subscribedFlag, 0)
+
+	for {
+		var w wire.State
+		if err := conn.ReadJSON(&w); err != nil {
+			return err
+		}
+		checkSchema(server, w.SchemaVersion)
+		s := w.State()
+		conn.SetReadDeadline(time.Now().Add(pingTimeout))
+		lastFetchGauge.WithLabelValues(serverLabel(server)).SetToCurrentTime()
+		received(0, server, &s, true)
+	}
+}
+
+// wsURL returns the URL of the WebSocket alongside the API at server (e.g.
+// ws://host:8080/ws for http://host:8080/api)
+func wsURL(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api") + "/ws"
+	u.RawQuery = ""
+	return u.String(), nil
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"time"
+
+	"github.com/lutzky/pitemp/internal/clock"
+)
+
+// Daylight is the sunrise and sunset on a day, as shown on the sun page
+type Daylight struct {
+	// Sunrise and Sunset are zero if the sun doesn't rise or set that day,
+	// e.g. during a polar night
+	Sunrise, Sunset time.Time
+
+	// Length is how long the sun is up that day, which is 24h for a
+	// midnight sun
+	Length time.Duration
+}
+
+// sunPage returns the page showing today's sunrise and sunset, which are
+// only known once this host's clock is valid
+func sunPage() Page {
+	p := Page{Label: "sun", Sun: true}
+	if clock.Valid() {
+		d := sun(time.Now())
+		p.Daylight = &d
+	}
+	return p
+}
@@ -0,0 +1,264 @@
+// Package display defines the interface for display drivers, and a registry
+// of them by name. Drivers show the page selected by client.Current on
+// every update; they register themselves when their package is imported, so
+// out-of-tree drivers (e.g. for a VFD or flip-dot display) can be compiled
+// into pitemp by adding a blank import, e.g. in a new file in cmd/pitemp:
+//
+//	import _ "example.com/pitemp-vfd"
+//
+// and then used with --display=DRIVER[:CONFIG].
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Display is an open display
+type Display interface {
+	// Update shows the current state; errors are returned to client.Run,
+	// which stops, so transient errors should only be logged.
+	Update() error
+
+	// Close releases the hardware, e.g. clearing the screen
+	Close() error
+}
+
+// Blanker is implemented by displays which can be blanked, e.g. at night
+type Blanker interface {
+	// SetBlank blanks the display, or restores it; Update isn't called
+	// while it is blank
+	SetBlank(blank bool) error
+}
+
+// Redrawer is implemented by displays which only write what changed since
+// the last update, so that a full redraw can be forced, e.g. to recover
+// from a garbled screen
+type Redrawer interface {
+	// Invalidate makes the next Update redraw everything
+	Invalidate()
+}
+
+// Backlighter is implemented by displays with a backlight, which can be
+// switched without changing what they show
+type Backlighter interface {
+	// SetBacklight turns the backlight on or off
+	SetBacklight(on bool) error
+}
+
+// Settings are common to all kinds of display; drivers ignore those which
+// don't apply to them
+type Settings struct {
+	// Location is the time zone for the clock
+	Location *time.Location
+
+	// ClockLayout is the time.Format layout for the clock
+	ClockLayout string
+
+	// IP selects the IP addresses shown, if any, along with the signal
+	// level of their interface if it's wireless
+	IP IPSelection
+}
+
+// Driver is a kind of display
+type Driver struct {
+	// Open opens the display with the given configuration, a driver-specific
+	// string which may be empty, and settings
+	Open func(config string, settings Settings) (Display, error)
+
+	// UpdateInterval is how often Update should be called (e.g. to tick the
+	// clock), unless overridden by the user; it's also called whenever the
+	// state changes
+	UpdateInterval time.Duration
+}
+
+var registry = struct {
+	mu      sync.RWMutex
+	drivers map[string]Driver
+}{drivers: map[string]Driver{}}
+
+// Register makes a driver available by name; it is meant to be called from
+// the init function of the driver's package, and panics if name is already
+// registered.
+func Register(name string, driver Driver) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if driver.Open == nil {
+		panic("display: Register driver has no Open")
+	}
+	if _, ok := registry.drivers[name]; ok {
+		panic("display: Register called twice for driver " + name)
+	}
+	if driver.UpdateInterval <= 0 {
+		driver.UpdateInterval = time.Second
+	}
+	registry.drivers[name] = driver
+}
+
+// Lookup returns the driver for spec, given as DRIVER[:CONFIG], and the
+// configuration to open it with
+func Lookup(spec string) (Driver, string, error) {
+	name, config := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, config = spec[:i], spec[i+1:]
+	}
+	if name == "" {
+		return Driver{}, "", errors.New("no display driver given")
+	}
+
+	registry.mu.RLock()
+	driver, ok := registry.drivers[name]
+	registry.mu.RUnlock()
+	if !ok {
+		return Driver{}, "", fmt.Errorf("unknown display %q (available: %s)", name, strings.Join(Drivers(), ", "))
+	}
+	return driver, config, nil
+}
+
+// RelayLabels returns short labels for the relays which are on in s (e.g.
+// HEAT for a heater), separated by spaces
+func RelayLabels(s state.State) string {
+	var labels []string
+	for _, name := range s.RelaysOn() {
+		if len(name) > 4 {
+			name = name[:4]
+		}
+		labels = append(labels, strings.ToUpper(name))
+	}
+	return strings.Join(labels, " ")
+}
+
+// PowerLabel returns a short description of the node's power supply in s,
+// if monitored: the battery charge (e.g. BAT 85%), or failing that the
+// supply voltage (e.g. 4.9V)
+func PowerLabel(s state.State) string {
+	if r, ok := s.Readings["battery"]; ok {
+		return fmt.Sprintf("BAT %.0f%%", r.Value)
+	}
+	if r, ok := s.Readings["voltage"]; ok {
+		return fmt.Sprintf("%.1fV", r.Value)
+	}
+	return ""
+}
+
+// PowerWarning returns a warning for the display if the node's Raspberry Pi
+// is undervolted (!UV) or throttled (!THR), which often explains flaky
+// readings
+func PowerWarning(s state.State) string {
+	for _, c := range s.Throttled {
+		if c == "undervoltage" {
+			return "!UV"
+		}
+	}
+	if len(s.Throttled) > 0 {
+		return "!THR"
+	}
+	return ""
+}
+
+// AlertLabel lists the active alerts of the node s is from, e.g. "ALERT
+// hot,stale", or returns "" if there are none
+func AlertLabel(s state.State) string {
+	if len(s.ActiveAlerts) == 0 {
+		return ""
+	}
+	return "ALERT " + strings.Join(s.ActiveAlerts, ",")
+}
+
+// TimesTrusted returns false if the clock of this host, or of the node s is
+// from, isn't valid (e.g. not yet NTP-synced after boot), in which case ages
+// computed from s.LastSensorUpdate are meaningless
+func TimesTrusted(s state.State) bool {
+	return clock.Valid() && !s.ClockInvalid
+}
+
+// Fresh returns true if s has readings which aren't known to be stale
+func Fresh(s state.State) bool {
+	return !s.LastSensorUpdate.IsZero() && !(TimesTrusted(s) && s.IsStale(state.StaleAfter()))
+}
+
+// Difference describes how much warmer or colder outside is than inside,
+// in whole degrees, e.g. "14° colder" with degree "°"
+func Difference(inside, outside float32, degree string) string {
+	d := math.Round(float64(outside - inside))
+	switch {
+	case d < 0:
+		return fmt.Sprintf("%.0f%s colder", -d, degree)
+	case d > 0:
+		return fmt.Sprintf("%.0f%s warmer", d, degree)
+	}
+	return "Same as inside"
+}
+
+// Trend arrows shown next to readings, which displays map to their own
+// character sets
+const (
+	Rising  = "↑"
+	Falling = "↓"
+	Steady  = "→"
+)
+
+// steadyRates are the rates of change per hour, by reading, below which
+// readings count as steady
+var steadyRates = map[string]float32{"temperature": 0.5, "humidity": 2}
+
+// TrendArrow returns the arrow for the trend of the reading name in s
+// (Rising, Falling or Steady), or "" if its trend isn't known
+func TrendArrow(s state.State, name string) string {
+	rate, ok := s.Trends[name]
+	if !ok {
+		return ""
+	}
+	switch steady := steadyRates[name]; {
+	case rate >= steady && rate > 0:
+		return Rising
+	case rate <= -steady && rate < 0:
+		return Falling
+	}
+	return Steady
+}
+
+// Clock formats now using layout for the clock line, or returns a
+// placeholder while this host's clock isn't valid, rather than showing e.g.
+// a bogus date in 1970
+func Clock(now time.Time, layout string) string {
+	if !clock.Valid() {
+		return "Time not synced"
+	}
+	return now.Format(layout)
+}
+
+// TimeOfDay formats t as hours and minutes, in 12-hour time if the clock's
+// layout is
+func TimeOfDay(t time.Time, clockLayout string) string {
+	if strings.Contains(clockLayout, "PM") {
+		return t.Format("3:04PM")
+	}
+	return t.Format("15:04")
+}
+
+// DayLength formats the length of a day (or less), e.g. 11h36m
+func DayLength(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	names := make([]string, 0, len(registry.drivers))
+	for name := range registry.drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
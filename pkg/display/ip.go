@@ -0,0 +1,163 @@
+package display
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IP address preferences, for IPSelection.Prefer
+const (
+	PreferIPv4 = "ipv4"
+	PreferIPv6 = "ipv6"
+	PreferAny  = "any"
+)
+
+// ipCycle is how long each address is shown, when cycling through them
+const ipCycle = 5 * time.Second
+
+// IPSelection selects the IP addresses shown by a display
+type IPSelection struct {
+	// Ifaces is a comma-separated list of network interfaces, or "*" for all
+	// of them (except loopback)
+	Ifaces string
+
+	// Prefer orders the addresses: PreferIPv4 (the default) puts global
+	// IPv4 addresses first, PreferIPv6 global IPv6 ones, and PreferAny
+	// keeps them in the order the system lists them. Link-local addresses
+	// are skipped, as they're of no use for reaching the host from another
+	// network.
+	Prefer string
+
+	// All cycles through all addresses, rather than only showing the
+	// preferred one
+	All bool
+
+	// PrefixLength keeps the CIDR prefix length (e.g. /24) of addresses
+	PrefixLength bool
+}
+
+// Address is an IP address of a network interface
+type Address struct {
+	Iface string
+
+	// Addr is the address as shown, e.g. 192.168.1.2 or 192.168.1.2/24
+	Addr string
+}
+
+// Interfaces returns the names of the selected network interfaces
+func (sel IPSelection) Interfaces() ([]string, error) {
+	if sel.Ifaces != "*" {
+		var names []string
+		for _, name := range strings.Split(sel.Ifaces, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interfaces: %w", err)
+	}
+	var names []string
+	for _, i := range ifaces {
+		if i.Flags&net.FlagUp != 0 && i.Flags&net.FlagLoopback == 0 {
+			names = append(names, i.Name)
+		}
+	}
+	return names, nil
+}
+
+// Addresses returns the addresses of the selected interfaces, preferred
+// ones first, across all of them. Interfaces which don't exist (e.g. eth0 on
+// a host with only WiFi) are skipped, unless none of them do.
+func (sel IPSelection) Addresses() ([]Address, error) {
+	names, err := sel.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	type ranked struct {
+		Address
+		rank int
+	}
+	var addrs []ranked
+	var missing []string
+	for _, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			missing = append(missing, name)
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addrs for %q: %w", name, err)
+		}
+		for _, a := range ifaceAddrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			shown := ipnet.IP.String()
+			if sel.PrefixLength {
+				shown = ipnet.String()
+			}
+			addrs = append(addrs, ranked{Address{name, shown}, sel.rank(ipnet.IP)})
+		}
+	}
+	if len(names) > 0 && len(missing) == len(names) {
+		return nil, fmt.Errorf("no such interface: %s", strings.Join(missing, ", "))
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return addrs[i].rank < addrs[j].rank })
+
+	result := make([]Address, len(addrs))
+	for i, a := range addrs {
+		result[i] = a.Address
+	}
+	return result, nil
+}
+
+// rank orders ip by preference, lowest first
+func (sel IPSelection) rank(ip net.IP) int {
+	if !ip.IsGlobalUnicast() {
+		return 2
+	}
+	v4 := ip.To4() != nil
+	switch sel.Prefer {
+	case PreferAny:
+		return 0
+	case PreferIPv6:
+		v4 = !v4
+	}
+	if v4 {
+		return 0
+	}
+	return 1
+}
+
+// At returns the address to show at t: the preferred one or, with All, each
+// in turn for a few seconds
+func (sel IPSelection) At(t time.Time) (Address, error) {
+	addrs, err := sel.Addresses()
+	if err != nil {
+		return Address{}, err
+	}
+	if len(addrs) == 0 {
+		return Address{}, fmt.Errorf("no IP address on %s", sel.Ifaces)
+	}
+	if !sel.All {
+		return addrs[0], nil
+	}
+	return addrs[int(t.UnixNano()/int64(ipCycle))%len(addrs)], nil
+}
+
+// CheckPrefer returns an error if prefer isn't a valid IPSelection.Prefer
+func CheckPrefer(prefer string) error {
+	switch prefer {
+	case "", PreferIPv4, PreferIPv6, PreferAny:
+		return nil
+	}
+	return fmt.Errorf("invalid IP preference %q, expected %s, %s or %s", prefer, PreferIPv4, PreferIPv6, PreferAny)
+}
@@ -0,0 +1,42 @@
+package lcd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/display"
+)
+
+func init() {
+	display.Register("lcd", display.Driver{Open: open, UpdateInterval: 2 * time.Second})
+}
+
+// open opens an LCD for the display registry
+func open(config string, settings display.Settings) (display.Display, error) {
+	if config != "" {
+		return nil, fmt.Errorf("lcd takes no configuration, got %q", config)
+	}
+	opts := []Option{WithIP(settings.IP)}
+	if settings.Location != nil {
+		opts = append(opts, WithLocation(settings.Location))
+	}
+	if settings.ClockLayout != "" {
+		opts = append(opts, WithClockLayout(settings.ClockLayout))
+	}
+	l, err := Initialize(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return displayer{l}, nil
+}
+
+// displayer adapts an LCD to display.Display; LCD errors are transient, so
+// they're only logged
+type displayer struct {
+	*LCD
+}
+
+func (d displayer) Update() error {
+	d.Display()
+	return nil
+}
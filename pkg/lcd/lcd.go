@@ -0,0 +1,477 @@
+// Package lcd shows the state on an HD44780 character LCD over I2C.
+package lcd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/d2r2/go-hd44780"
+	"github.com/d2r2/go-i2c"
+	"github.com/lutzky/pitemp/internal/wifi"
+	"github.com/lutzky/pitemp/pkg/client"
+	"github.com/lutzky/pitemp/pkg/display"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// DegreeSymbol is the character code used for displaying the degrees
+// symbol (normally "°"). We're using the Japanese handakuten (゜).
+const DegreeSymbol = 0xdf
+
+// Character codes of the trend arrows: up and down are custom characters,
+// defined if the Device is a CharDefiner (and otherwise shown as ^ and v),
+// while the right arrow is built in
+const (
+	ArrowUp    = 0x08
+	ArrowDown  = 0x09
+	ArrowRight = 0x7e
+)
+
+// arrowPatterns are the custom characters for the up and down arrows, by
+// character code; each byte is a row of 5 pixels
+var arrowPatterns = map[byte][8]byte{
+	ArrowUp:   {0x04, 0x0e, 0x15, 0x04, 0x04, 0x04, 0x04, 0x00},
+	ArrowDown: {0x04, 0x04, 0x04, 0x04, 0x15, 0x0e, 0x04, 0x00},
+}
+
+// width is the number of characters per line of the 20x4 LCD
+const width = 20
+
+// Option configures an LCD
+type Option func(*options)
+
+type options struct {
+	addr        uint8
+	bus         int
+	ip          display.IPSelection
+	location    *time.Location
+	clockLayout string
+}
+
+// WithI2C sets the I²C address and bus of the LCD (default 0x27 on bus 1)
+func WithI2C(addr uint8, bus int) Option {
+	return func(o *options) { o.addr, o.bus = addr, bus }
+}
+
+// WithIPIface sets the network interface whose IP address (and signal
+// level, if wireless) is shown on the second line; by default, no address is
+// shown
+func WithIPIface(iface string) Option {
+	return func(o *options) { o.ip.Ifaces = iface }
+}
+
+// WithIP selects the IP addresses shown on the second line, like
+// WithIPIface but with more control
+func WithIP(sel display.IPSelection) Option {
+	return func(o *options) { o.ip = sel }
+}
+
+// WithLocation sets the time zone for the clock (default: local time)
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) { o.location = loc }
+}
+
+// WithClockLayout sets the time.Format layout for the clock
+func WithClockLayout(layout string) Option {
+	return func(o *options) { o.clockLayout = layout }
+}
+
+// Device is the part of an HD44780 LCD used by LCD. Initialize opens one
+// over I²C; tests can use a fake, such as lcdtest.Device.
+type Device interface {
+	SetPosition(line, pos int) error
+	Write(buf []byte) (int, error)
+	Clear() error
+	BacklightOn() error
+	BacklightOff() error
+	Close() error
+}
+
+// hardware is an HD44780 LCD on an I²C expander
+type hardware struct {
+	*hd44780.Lcd
+	i2c *i2c.I2C
+}
+
+// CharDefiner is implemented by Devices which can define custom characters
+type CharDefiner interface {
+	// DefineChar defines the character code (0-7, or their aliases 8-15)
+	// as pattern, a row of 5 pixels per byte
+	DefineChar(code byte, pattern [8]byte) error
+}
+
+func (h hardware) Close() error {
+	return h.i2c.Close()
+}
+
+func (h hardware) DefineChar(code byte, pattern [8]byte) error {
+	if err := h.Command(hd44780.CMD_CGRAM_Set | (code&7)<<3); err != nil {
+		return err
+	}
+	_, err := h.Write(pattern[:])
+	return err
+}
+
+// LCD is an open HD44780 LCD
+type LCD struct {
+	opts options
+	dev  Device
+
+	// shown holds what each line shows, where known[i]; only characters
+	// which changed are rewritten, as every character takes several I²C
+	// writes through the expander
+	shown [4][width]byte
+	known [4]bool
+
+	// arrows maps the trend arrows to the LCD's character set; it's nil
+	// until they're defined
+	arrows *strings.Replacer
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		addr:        0x27,
+		bus:         1,
+		location:    time.Local,
+		clockLayout: "Mon Jan 2 15:04:05",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Initialize the HD44780 LCD
+func Initialize(opts ...Option) (*LCD, error) {
+	o := newOptions(opts)
+
+	bus, err := i2c.NewI2C(o.addr, o.bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize I2C: %w", err)
+	}
+
+	lcd, err := hd44780.NewLcd(bus, hd44780.LCD_20x4)
+	if err != nil {
+		bus.Close()
+		return nil, fmt.Errorf("failed to initialize LCD: %w", err)
+	}
+
+	err = lcd.BacklightOn()
+	if err != nil {
+		bus.Close()
+		return nil, fmt.Errorf("failed to turn backlight on: %w", err)
+	}
+
+	return &LCD{opts: o, dev: hardware{lcd, bus}}, nil
+}
+
+// New returns an LCD showing the state on dev, which should already have
+// its backlight on. The I²C options are ignored.
+func New(dev Device, opts ...Option) *LCD {
+	return &LCD{opts: newOptions(opts), dev: dev}
+}
+
+// Display updates the LCD with the latest state. Only the changed part of
+// each line is written, in one go, so e.g. a ticking clock only rewrites its
+// last digit rather than the whole screen.
+func (l *LCD) Display() {
+	if l.arrows == nil {
+		l.defineArrows()
+	}
+	lines := l.lines(client.Current(), time.Now())
+	for i, line := range lines {
+		next := cells(l.arrows.Replace(line))
+		first, last := 0, width-1
+		if l.known[i] {
+			for first < width && l.shown[i][first] == next[first] {
+				first++
+			}
+			if first == width {
+				continue
+			}
+			for l.shown[i][last] == next[last] {
+				last--
+			}
+		}
+
+		l.known[i] = false
+		if err := l.dev.SetPosition(i, first); err != nil {
+			slog.Error("Failed to show message", "line", i+1, "err", err)
+			client.RenderFailed()
+			continue
+		}
+		if _, err := l.dev.Write(next[first : last+1]); err != nil {
+			slog.Error("Failed to show message", "line", i+1, "err", err)
+			client.RenderFailed()
+			continue
+		}
+		l.shown[i], l.known[i] = next, true
+	}
+}
+
+// defineArrows defines the custom characters for the trend arrows, if the
+// device supports them
+func (l *LCD) defineArrows() {
+	l.arrows = strings.NewReplacer(display.Rising, "^", display.Falling, "v", display.Steady, string(rune(ArrowRight)))
+	d, ok := l.dev.(CharDefiner)
+	if !ok {
+		return
+	}
+	for code, pattern := range arrowPatterns {
+		if err := d.DefineChar(code, pattern); err != nil {
+			slog.Error("Failed to define trend arrows", "err", err)
+			return
+		}
+	}
+	l.arrows = strings.NewReplacer(display.Rising, string(rune(ArrowUp)), display.Falling, string(rune(ArrowDown)), display.Steady, string(rune(ArrowRight)))
+}
+
+// cells returns line as shown on the LCD: a byte per character (in the
+// LCD's character set, e.g. DegreeSymbol), truncated or padded with spaces
+// to the width of the LCD. Besides ASCII and the character codes used here,
+// "°" is shown as DegreeSymbol; the LCD has no other characters in common
+// with Unicode, so others (e.g. in messages) are shown as "?".
+func cells(line string) [width]byte {
+	var b [width]byte
+	i := 0
+	for _, c := range line {
+		if i == width {
+			break
+		}
+		switch {
+		case c < 0x80, c == DegreeSymbol:
+			b[i] = byte(c)
+		case c == '°':
+			b[i] = DegreeSymbol
+		default:
+			b[i] = '?'
+		}
+		i++
+	}
+	for ; i < width; i++ {
+		b[i] = ' '
+	}
+	return b
+}
+
+// lines returns the four lines to show for page at now; the second one, the
+// IP address, is empty without an IP interface (or power readings)
+func (l *LCD) lines(page client.Page, now time.Time) [4]string {
+	var lines [4]string
+	s := page.State
+
+	if page.System {
+		lines[0] = "[reading system]"
+		if sys := s.System; sys != nil {
+			lines[0] = fmt.Sprintf("Load %.2f %.2f %.2f", sys.Load1, sys.Load5, sys.Load15)
+			lines[1] = fmt.Sprintf("Mem %.0f%% Disk %.0f%%", sys.MemoryUsedPercent, sys.DiskUsedPercent)
+			if sys.SoCTemperature != 0 {
+				lines[2] = fmt.Sprintf("SoC %.1f%cC", sys.SoCTemperature, DegreeSymbol)
+			}
+		}
+		lines[3] = display.Clock(now.In(l.opts.location), l.opts.clockLayout)
+		return withMessage(lines, page.Message)
+	}
+
+	if page.Compare {
+		inside, outside := "?", "?"
+		if display.Fresh(s) {
+			inside = fmt.Sprintf("%.0f%cC", s.Temperature, DegreeSymbol)
+		}
+		if display.Fresh(page.Outside) {
+			outside = fmt.Sprintf("%.0f%cC", page.Outside.Temperature, DegreeSymbol)
+		}
+		lines[0] = "Inside " + inside
+		lines[1] = "Outside " + outside
+		if display.Fresh(s) && display.Fresh(page.Outside) {
+			lines[2] = display.Difference(s.Temperature, page.Outside.Temperature, string(rune(DegreeSymbol)))
+		}
+		lines[3] = display.Clock(now.In(l.opts.location), l.opts.clockLayout)
+		return withMessage(lines, page.Message)
+	}
+
+	if page.Forecast {
+		lines[0] = "[fetching forecast]"
+		if w := page.Weather; w != nil {
+			lines[0] = fmt.Sprintf("Outside %.0f%cC", w.Temperature, DegreeSymbol)
+			lines[1] = fmt.Sprintf("Tmrw %.0f/%.0f%cC rain %.0f%%", w.High, w.Low, DegreeSymbol, w.RainChance)
+		}
+		if display.Fresh(s) {
+			lines[2] = fmt.Sprintf("Inside %.0f%cC, %.0f%%", s.Temperature, DegreeSymbol, s.Humidity)
+		}
+		lines[3] = display.Clock(now.In(l.opts.location), l.opts.clockLayout)
+		return withMessage(lines, page.Message)
+	}
+
+	if page.Sun {
+		lines[0] = "[waiting for clock]"
+		if d := page.Daylight; d != nil {
+			switch {
+			case !d.Sunrise.IsZero():
+				lines[0] = "Sunrise " + display.TimeOfDay(d.Sunrise.In(l.opts.location), l.opts.clockLayout)
+				lines[1] = "Sunset  " + display.TimeOfDay(d.Sunset.In(l.opts.location), l.opts.clockLayout)
+			case d.Length > 0:
+				lines[0] = "Sun up all day"
+			default:
+				lines[0] = "Sun down all day"
+			}
+			lines[2] = "Daylight " + display.DayLength(d.Length)
+		}
+		lines[3] = display.Clock(now.In(l.opts.location), l.opts.clockLayout)
+		return withMessage(lines, page.Message)
+	}
+
+	if page.Web {
+		// There's no drawing a QR code with the LCD's characters, but the
+		// address is short enough to type
+		lines[0] = "Web UI:"
+		lines[1] = "[no IP address]"
+		if page.URL != "" {
+			lines[1] = strings.TrimSuffix(strings.TrimPrefix(page.URL, "http://"), "/")
+		}
+		lines[3] = display.Clock(now.In(l.opts.location), l.opts.clockLayout)
+		return withMessage(lines, page.Message)
+	}
+
+	message := "[LCD live]"
+	if page.Label != "" {
+		message = page.Label
+	}
+
+	if !s.LastSensorUpdate.IsZero() {
+		freshness := now.Sub(s.LastSensorUpdate).Round(time.Second).String()
+		if !display.TimesTrusted(s) {
+			freshness = "?"
+		}
+		if page.Label != "" {
+			message = fmt.Sprintf("%s %s", page.Label, freshness)
+		} else {
+			message = fmt.Sprintf("Freshness: %s", freshness)
+		}
+	}
+
+	if page.Main {
+		// Indicate when showing a fallback server
+		if source := client.Source(); source > 1 {
+			message += fmt.Sprintf(" #%d", source)
+		}
+
+		if page.Local {
+			message = "Local, server down"
+		} else if client.Unreachable() {
+			message = "Server unreachable"
+		}
+	}
+	if relays := display.RelayLabels(s); relays != "" {
+		message += " " + relays
+	}
+	lines[0] = message
+
+	if l.opts.ip.Ifaces != "" {
+		addr, err := l.opts.ip.At(now)
+		if err != nil {
+			lines[1] = err.Error()
+		} else {
+			lines[1] = addr.Addr
+			if level, err := wifi.Signal(addr.Iface); err == nil {
+				lines[1] += fmt.Sprintf(" %.0fdBm", level)
+			}
+		}
+	}
+	for _, power := range []string{display.PowerLabel(s), display.PowerWarning(s)} {
+		if power != "" {
+			lines[1] = strings.TrimSpace(lines[1] + " " + power)
+		}
+	}
+	// Active alerts matter more than the IP address
+	if alert := display.AlertLabel(s); alert != "" {
+		lines[1] = alert
+	}
+
+	dhtMessage := "[waiting for dht11]"
+	if !s.LastSensorUpdate.IsZero() {
+		dhtMessage = fmt.Sprintf("%.0f%cC, %.0f%% humid",
+			s.Temperature, DegreeSymbol, s.Humidity)
+		if c, ok := s.Comfort(); ok {
+			dhtMessage = climateLine(s, c.Label)
+		}
+	}
+	if !s.LastSensorUpdate.IsZero() && display.TimesTrusted(s) && s.IsStale(state.StaleAfter()) {
+		// Don't confidently show an old temperature
+		age := s.Age()
+		dhtMessage = fmt.Sprintf("STALE %02d:%02d", int(age.Hours()), int(age.Minutes())%60)
+	}
+	lines[2] = dhtMessage
+
+	lines[3] = display.Clock(now.In(l.opts.location), l.opts.clockLayout)
+	return withMessage(lines, page.Message)
+}
+
+// climateLine returns the temperature and humidity in s, with their trend
+// arrows, and comfort as there's room for it
+func climateLine(s state.State, comfort string) string {
+	temperature, humidity := display.TrendArrow(s, "temperature"), display.TrendArrow(s, "humidity")
+	line := fmt.Sprintf("%.0f%cC %.0f%%", s.Temperature, DegreeSymbol, s.Humidity)
+	if temperature != "" || humidity != "" {
+		// The arrows take the place of the C, leaving room for most labels
+		line = fmt.Sprintf("%.0f%c%s %.0f%%%s", s.Temperature, DegreeSymbol, temperature, s.Humidity, humidity)
+	}
+	// Only "comfortable", the least interesting label, may not fit
+	if utf8.RuneCountInString(line)+1+len(comfort) <= width {
+		line += " " + comfort
+	}
+	return line
+}
+
+// withMessage returns lines with m, if any, replacing its line
+func withMessage(lines [4]string, m client.Message) [4]string {
+	if m.Text == "" {
+		return lines
+	}
+	i := m.Line - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(lines) {
+		i = len(lines) - 1
+	}
+	lines[i] = m.Text
+	return lines
+}
+
+// SetBlank clears the LCD and turns off its backlight, or turns the
+// backlight back on
+func (l *LCD) SetBlank(blank bool) error {
+	if !blank {
+		return l.dev.BacklightOn()
+	}
+	l.known = [4]bool{}
+	if err := l.dev.Clear(); err != nil {
+		return err
+	}
+	return l.dev.BacklightOff()
+}
+
+// Invalidate makes the next Display rewrite every line and redefine the
+// trend arrows, e.g. in case the LCD was garbled by noise on the I²C bus
+func (l *LCD) Invalidate() {
+	l.known = [4]bool{}
+	l.arrows = nil
+}
+
+// SetBacklight turns the backlight on or off, keeping what the LCD shows
+func (l *LCD) SetBacklight(on bool) error {
+	if on {
+		return l.dev.BacklightOn()
+	}
+	return l.dev.BacklightOff()
+}
+
+// Close turns off the backlight and closes the device
+func (l *LCD) Close() error {
+	if err := l.dev.BacklightOff(); err != nil {
+		slog.Error("Failed to turn off backlight", "err", err)
+	}
+	return l.dev.Close()
+}
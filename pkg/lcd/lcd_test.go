@@ -0,0 +1,170 @@
+package lcd_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/client"
+	"github.com/lutzky/pitemp/pkg/lcd"
+	"github.com/lutzky/pitemp/pkg/lcd/lcdtest"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// setState sets the state shown on the LCD; the clock is marked invalid, so
+// that the freshness shown doesn't depend on timing
+func setState(temperature, humidity float32) {
+	s := state.State{ClockInvalid: true}
+	now := time.Now()
+	s.SetReading("temperature", state.Reading{Value: temperature, Unit: "celsius", MeasuredAt: now})
+	s.SetReading("humidity", state.Reading{Value: humidity, Unit: "percent", MeasuredAt: now})
+	state.Set(&s)
+}
+
+func newLCD() (*lcd.LCD, *lcdtest.Device) {
+	dev := &lcdtest.Device{Backlight: true}
+	// A layout without any time in it keeps the clock line fixed
+	return lcd.New(dev, lcd.WithClockLayout("clock")), dev
+}
+
+func TestDisplay(t *testing.T) {
+	setState(21, 40)
+	l, dev := newLCD()
+	l.Display()
+
+	if got, want := dev.Line(0), "Freshness: ?"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 1 = %q, want %q", got, want)
+	}
+	if got, want := dev.Line(2), "21\xdfC 40% comfortable"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q, want %q", got, want)
+	}
+	if dev.Written != lcdtest.Width*lcdtest.Height {
+		t.Errorf("Wrote %d characters, want the whole screen (%d)", dev.Written, lcdtest.Width*lcdtest.Height)
+	}
+}
+
+func TestDisplayMessage(t *testing.T) {
+	setState(21, 40)
+	l, dev := newLCD()
+	defer client.SetMessage(client.Message{})
+
+	client.SetMessage(client.Message{Text: "Washing done", Line: 3})
+	l.Display()
+	if got, want := dev.Line(2), "Washing done"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q, want %q", got, want)
+	}
+
+	client.SetMessage(client.Message{Text: "Tea 2€ at 90°C, 日本", Line: 3})
+	l.Display()
+	if got, want := dev.Line(2), "Tea 2? at 90\xdfC, ??"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q, want %q", got, want)
+	}
+
+	client.SetMessage(client.Message{Text: "Expired", Line: 3, Expires: time.Now().Add(-time.Second)})
+	l.Display()
+	if got, want := dev.Line(2), "21\xdfC 40% comfortable"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q after the message expired, want %q", got, want)
+	}
+}
+
+func TestDisplayTrends(t *testing.T) {
+	setState(21, 25)
+	state.Update(func(s *state.State) {
+		s.Trends = map[string]float32{"temperature": 1.2, "humidity": -0.5}
+	})
+	l, dev := newLCD()
+	l.Display()
+
+	if got, want := dev.Line(2), "21\xdf\x08 25%\x7e dry"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q, want %q", got, want)
+	}
+	if dev.Chars[lcd.ArrowUp&7][0] == 0 {
+		t.Error("Up arrow not defined")
+	}
+}
+
+func TestDisplayOnlyRewritesChanges(t *testing.T) {
+	setState(21, 40)
+	l, dev := newLCD()
+	l.Display()
+	written := dev.Written
+
+	l.Display()
+	if n := dev.Written - written; n != 0 {
+		t.Errorf("Rewrote %d characters of an unchanged screen, want 0", n)
+	}
+
+	setState(22, 40)
+	l.Display()
+	if n := dev.Written - written; n != 1 {
+		t.Errorf("Rewrote %d characters for a changed digit, want 1", n)
+	}
+	if got, want := dev.Line(2), "22\xdfC 40% comfortable"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q, want %q", got, want)
+	}
+}
+
+func TestDisplayRewritesAfterError(t *testing.T) {
+	setState(21, 40)
+	l, dev := newLCD()
+	dev.Err = errors.New("I²C failure")
+	l.Display()
+	if dev.Written != 0 {
+		t.Fatalf("Wrote %d characters to a failing LCD", dev.Written)
+	}
+
+	dev.Err = nil
+	l.Display()
+	if dev.Written != lcdtest.Width*lcdtest.Height {
+		t.Errorf("Wrote %d characters after a failure, want the whole screen (%d)", dev.Written, lcdtest.Width*lcdtest.Height)
+	}
+}
+
+func TestSetBlank(t *testing.T) {
+	setState(21, 40)
+	l, dev := newLCD()
+	l.Display()
+
+	if err := l.SetBlank(true); err != nil {
+		t.Fatalf("SetBlank(true) failed: %v", err)
+	}
+	if dev.Backlight {
+		t.Error("Backlight still on after SetBlank(true)")
+	}
+	if got := strings.TrimSpace(dev.Line(2)); got != "" {
+		t.Errorf("line 3 = %q after SetBlank(true), want it cleared", got)
+	}
+
+	if err := l.SetBlank(false); err != nil {
+		t.Fatalf("SetBlank(false) failed: %v", err)
+	}
+	if !dev.Backlight {
+		t.Error("Backlight still off after SetBlank(false)")
+	}
+	l.Display()
+	if got, want := dev.Line(2), "21\xdfC 40% comfortable"; strings.TrimRight(got, " ") != want {
+		t.Errorf("line 3 = %q after unblanking, want %q", got, want)
+	}
+}
+
+func TestClose(t *testing.T) {
+	l, dev := newLCD()
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if dev.Backlight || !dev.Closed {
+		t.Errorf("After Close, backlight = %v and closed = %v, want false and true", dev.Backlight, dev.Closed)
+	}
+}
+
+func BenchmarkDisplay(b *testing.B) {
+	setState(21, 40)
+	l, _ := newLCD()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Formatting and comparing the lines, with nothing to rewrite
+		l.Display()
+	}
+}
@@ -0,0 +1,141 @@
+// Package lcdtest provides an in-memory HD44780 LCD, for testing package lcd
+// without hardware.
+package lcdtest
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Size of the 20x4 LCD
+const (
+	Width  = 20
+	Height = 4
+)
+
+// ErrClosed is returned by a Device used after Close
+var ErrClosed = errors.New("lcdtest: device closed")
+
+// Device is an in-memory LCD, implementing lcd.Device and lcd.CharDefiner.
+// Its zero value is a cleared LCD with the backlight off.
+type Device struct {
+	// Err, if set, is returned by every call instead of doing anything, to
+	// simulate I²C failures
+	Err error
+
+	// Backlight is whether the backlight is on
+	Backlight bool
+
+	// Written is how many characters have been written, e.g. to check that
+	// only changes are rewritten
+	Written int
+
+	// Closed is set by Close
+	Closed bool
+
+	// Chars holds the custom characters defined by DefineChar, by code
+	Chars [8][8]byte
+
+	screen    [Height][Width]byte
+	line, pos int
+}
+
+func (d *Device) check() error {
+	if d.Err != nil {
+		return d.Err
+	}
+	if d.Closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// SetPosition moves the cursor to pos on line, both 0-based
+func (d *Device) SetPosition(line, pos int) error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	if line < 0 || line >= Height || pos < 0 || pos >= Width {
+		return fmt.Errorf("lcdtest: position %d,%d out of range", line, pos)
+	}
+	d.line, d.pos = line, pos
+	return nil
+}
+
+// Write writes buf at the cursor, which advances; like the real LCD,
+// characters past the end of the line are lost
+func (d *Device) Write(buf []byte) (int, error) {
+	if err := d.check(); err != nil {
+		return 0, err
+	}
+	for _, b := range buf {
+		if d.pos < Width {
+			d.screen[d.line][d.pos] = b
+		}
+		d.pos++
+	}
+	d.Written += len(buf)
+	return len(buf), nil
+}
+
+// Clear clears the screen, and moves the cursor home
+func (d *Device) Clear() error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	d.screen = [Height][Width]byte{}
+	d.line, d.pos = 0, 0
+	return nil
+}
+
+// BacklightOn turns the backlight on
+func (d *Device) BacklightOn() error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	d.Backlight = true
+	return nil
+}
+
+// BacklightOff turns the backlight off
+func (d *Device) BacklightOff() error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	d.Backlight = false
+	return nil
+}
+
+// DefineChar defines the custom character code, 0-7 or their aliases 8-15
+func (d *Device) DefineChar(code byte, pattern [8]byte) error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	if code > 15 {
+		return fmt.Errorf("lcdtest: character code %d out of range", code)
+	}
+	d.Chars[code&7] = pattern
+	return nil
+}
+
+// Close marks the device as closed
+func (d *Device) Close() error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	d.Closed = true
+	return nil
+}
+
+// Line returns what line i (0-based) shows, with cleared characters as
+// spaces
+func (d *Device) Line(i int) string {
+	b := make([]byte, Width)
+	for j, c := range d.screen[i] {
+		if c == 0 {
+			c = ' '
+		}
+		b[j] = c
+	}
+	return string(b)
+}
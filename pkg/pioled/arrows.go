@@ -0,0 +1,102 @@
+package pioled
+
+import (
+	"image"
+	"image/color"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/lutzky/pitemp/pkg/display"
+)
+
+// arrowFace wraps a font.Face lacking the trend arrows (display.Rising,
+// Falling and Steady), such as basicfont's, drawing them as wide as its
+// digits
+type arrowFace struct {
+	font.Face
+}
+
+// arrowMasks are the trend arrows, drawn on the baseline, by rune
+var arrowMasks = map[rune]*image.Alpha{
+	arrowRune(display.Rising): newMask(
+		"..#..",
+		".###.",
+		"#.#.#",
+		"..#..",
+		"..#..",
+		"..#..",
+		"..#..",
+	),
+	arrowRune(display.Falling): newMask(
+		"..#..",
+		"..#..",
+		"..#..",
+		"..#..",
+		"#.#.#",
+		".###.",
+		"..#..",
+	),
+	arrowRune(display.Steady): newMask(
+		".....",
+		"..#..",
+		"...#.",
+		"#####",
+		"...#.",
+		"..#..",
+		".....",
+	),
+}
+
+func arrowRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+// newMask returns a mask with the pixels marked # in rows
+func newMask(rows ...string) *image.Alpha {
+	m := image.NewAlpha(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				m.SetAlpha(x, y, color.Alpha{A: 0xff})
+			}
+		}
+	}
+	return m
+}
+
+// arrowBounds returns the bounds of mask relative to the dot, leaving a
+// column of space on its left
+func arrowBounds(mask *image.Alpha) image.Rectangle {
+	size := mask.Bounds().Size()
+	return image.Rect(1, -size.Y, 1+size.X, 0)
+}
+
+func (f arrowFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	mask, ok := arrowMasks[r]
+	if !ok {
+		return f.Face.Glyph(dot, r)
+	}
+	advance, _ := f.Face.GlyphAdvance('0')
+	dr := arrowBounds(mask).Add(image.Pt(dot.X.Round(), dot.Y.Round()))
+	return dr, mask, image.Point{}, advance, true
+}
+
+func (f arrowFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	mask, ok := arrowMasks[r]
+	if !ok {
+		return f.Face.GlyphBounds(r)
+	}
+	b := arrowBounds(mask)
+	advance, _ := f.Face.GlyphAdvance('0')
+	return fixed.R(b.Min.X, b.Min.Y, b.Max.X, b.Max.Y), advance, true
+}
+
+func (f arrowFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	if _, ok := arrowMasks[r]; ok {
+		r = '0'
+	}
+	return f.Face.GlyphAdvance(r)
+}
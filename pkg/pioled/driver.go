@@ -0,0 +1,63 @@
+package pioled
+
+import (
+	"time"
+
+	"github.com/lutzky/pitemp/internal/wifi"
+	"github.com/lutzky/pitemp/pkg/display"
+)
+
+func init() {
+	display.Register("pioled", display.Driver{Open: open, UpdateInterval: time.Second})
+}
+
+// open opens a PiOLED for the display registry; config is the name of its
+// I²C bus, if not the first one
+func open(config string, settings display.Settings) (display.Display, error) {
+	p, err := Initialize(append(settingsOptions(settings), WithBus(config))...)
+	if err != nil {
+		return nil, err
+	}
+	return displayer{p}, nil
+}
+
+// settingsOptions returns the options corresponding to settings
+func settingsOptions(settings display.Settings) []Option {
+	var opts []Option
+	if settings.Location != nil {
+		opts = append(opts, WithLocation(settings.Location))
+	}
+	if settings.ClockLayout != "" {
+		opts = append(opts, WithClockLayout(settings.ClockLayout))
+	}
+	if iface := wifiIface(settings.IP); iface != "" {
+		opts = append(opts, WithWiFiIface(iface))
+	}
+	return opts
+}
+
+// wifiIface returns the interface whose signal level to show for sel: the
+// first selected one which is currently wireless (and up) or, failing that,
+// the first one given
+func wifiIface(sel display.IPSelection) string {
+	names, err := sel.Interfaces()
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	for _, name := range names {
+		if _, err := wifi.Signal(name); err == nil {
+			return name
+		}
+	}
+	if sel.Ifaces == "*" {
+		return ""
+	}
+	return names[0]
+}
+
+// displayer adapts a PiOLED to display.Display
+type displayer struct {
+	*PiOLED
+}
+
+func (d displayer) Update() error { return d.Display() }
@@ -0,0 +1,91 @@
+package pioled
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxCachedGlyphs bounds a glyphCache; the display only ever shows a few
+// dozen distinct characters, but each may be drawn at several sub-pixel
+// offsets
+const maxCachedGlyphs = 1024
+
+// glyphCache wraps a font.Face, keeping a copy of every glyph it rasterizes,
+// so that each frame doesn't go through freetype again for the same
+// characters. The wrapped face is only used under mu, which also makes it
+// safe to render concurrently (e.g. the display and HTTP previews).
+type glyphCache struct {
+	font.Face
+
+	mu     sync.Mutex
+	glyphs map[glyphKey]glyph
+}
+
+// glyphKey identifies a glyph by its rune and the sub-pixel part of the dot
+// it's drawn at
+type glyphKey struct {
+	r    rune
+	x, y fixed.Int26_6
+}
+
+// glyph is a rasterized glyph, drawn at the integer part of the dot
+type glyph struct {
+	dr      image.Rectangle
+	mask    *image.Alpha
+	advance fixed.Int26_6
+	ok      bool
+}
+
+func newGlyphCache(face font.Face) *glyphCache {
+	return &glyphCache{Face: face, glyphs: map[glyphKey]glyph{}}
+}
+
+func (c *glyphCache) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	offset := image.Pt(dot.X.Floor(), dot.Y.Floor())
+	key := glyphKey{r, dot.X - fixed.I(offset.X), dot.Y - fixed.I(offset.Y)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.glyphs[key]
+	if !ok {
+		var mask image.Image
+		var maskp image.Point
+		g.dr, mask, maskp, g.advance, g.ok = c.Face.Glyph(fixed.Point26_6{X: key.x, Y: key.y}, r)
+		if g.ok {
+			// The face reuses its mask, so keep a copy
+			g.mask = image.NewAlpha(image.Rectangle{Max: g.dr.Size()})
+			draw.Draw(g.mask, g.mask.Bounds(), mask, maskp, draw.Src)
+		}
+		if len(c.glyphs) >= maxCachedGlyphs {
+			c.glyphs = map[glyphKey]glyph{}
+		}
+		c.glyphs[key] = g
+	}
+	if !g.ok {
+		return image.Rectangle{}, nil, image.Point{}, g.advance, false
+	}
+	return g.dr.Add(offset), g.mask, image.Point{}, g.advance, true
+}
+
+func (c *glyphCache) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Face.GlyphBounds(r)
+}
+
+func (c *glyphCache) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Face.GlyphAdvance(r)
+}
+
+func (c *glyphCache) Kern(r0, r1 rune) fixed.Int26_6 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Face.Kern(r0, r1)
+}
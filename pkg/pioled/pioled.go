@@ -0,0 +1,455 @@
+// Package pioled shows the state on an Adafruit PiOLED (SSD1306) display,
+// or renders it as a PNG image.
+package pioled
+
+import (
+	"bytes"
+	_ "embed" // For embedding font TTF file
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/wifi"
+	"github.com/lutzky/pitemp/pkg/client"
+	"github.com/lutzky/pitemp/pkg/display"
+	"github.com/lutzky/pitemp/pkg/state"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/devices/ssd1306"
+	"periph.io/x/periph/devices/ssd1306/image1bit"
+	"periph.io/x/periph/host"
+)
+
+// Option configures a PiOLED, or the preview served by HTTPHandler
+type Option func(*options)
+
+type options struct {
+	clearOnClose bool
+	bus          string
+	location     *time.Location
+	clockLayout  string
+	wifiIface    string
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		clearOnClose: true,
+		location:     time.Local,
+		clockLayout:  "Mon Jan 2 15:04:05",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClearOnClose determines if the display is cleared by Close (default
+// true)
+func WithClearOnClose(clear bool) Option {
+	return func(o *options) { o.clearOnClose = clear }
+}
+
+// WithBus sets the name of the I²C bus the display is on (default: the
+// first one)
+func WithBus(name string) Option {
+	return func(o *options) { o.bus = name }
+}
+
+// WithLocation sets the time zone for the clock (default: local time)
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) { o.location = loc }
+}
+
+// WithClockLayout sets the time.Format layout for the clock
+func WithClockLayout(layout string) Option {
+	return func(o *options) { o.clockLayout = layout }
+}
+
+// WithWiFiIface sets the wireless interface whose signal level is shown
+// next to the clock; by default, none is shown
+func WithWiFiIface(iface string) Option {
+	return func(o *options) { o.wifiIface = iface }
+}
+
+// HTTPHandler serves a PNG image of what would be rendered on a PiOLED
+// display configured with opts.
+func HTTPHandler(opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+	return func(w http.ResponseWriter, _ *http.Request) {
+		img := previewPool.Get().(*image.Paletted)
+		defer previewPool.Put(img)
+		for i := range img.Pix {
+			img.Pix[i] = 0
+		}
+		render(img, color.White, &o, client.Current(), time.Now())
+
+		buf := pngBufferPool.Get().(*bytes.Buffer)
+		defer pngBufferPool.Put(buf)
+		buf.Reset()
+		if err := pngEncoder.Encode(buf, img); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}
+}
+
+// previewPool, pngBufferPool and pngEncoder let previews reuse their images
+// and buffers, rather than allocating new ones for every request
+var (
+	previewPool = sync.Pool{
+		New: func() interface{} {
+			return image.NewPaletted(image.Rect(0, 0, 128, 32), color.Palette{color.Black, color.White})
+		},
+	}
+	pngBufferPool = sync.Pool{
+		New: func() interface{} { return new(bytes.Buffer) },
+	}
+	pngEncoder = png.Encoder{BufferPool: &encoderBufferPool{}}
+)
+
+// encoderBufferPool is a png.EncoderBufferPool backed by a sync.Pool
+type encoderBufferPool struct{ pool sync.Pool }
+
+func (p *encoderBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *encoderBufferPool) Put(b *png.EncoderBuffer) { p.pool.Put(b) }
+
+// Device is the part of an SSD1306 display used by PiOLED. Initialize
+// opens one over I²C; tests can use a fake, such as pioledtest.Device.
+type Device interface {
+	Bounds() image.Rectangle
+	Draw(r image.Rectangle, src image.Image, sp image.Point) error
+	Halt() error
+}
+
+// PiOLED is an open PiOLED display
+type PiOLED struct {
+	opts options
+
+	dev Device
+	// busCloser is nil for a Device passed to New
+	busCloser i2c.BusCloser
+
+	// frame is reused for every Display call, to avoid allocating a new
+	// image for each update.
+	frame *image1bit.VerticalLSB
+
+	// shown is a copy of the frame last drawn, or nil if the display may
+	// not be showing it (initially, or after blanking or a failed draw).
+	// Unchanged frames aren't sent again, which at the default update
+	// interval saves about half the I²C traffic, with only the clock
+	// changing.
+	shown []byte
+}
+
+// Initialize initializes the pioled hardware
+func Initialize(opts ...Option) (*PiOLED, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, fmt.Errorf("host init failed: %w", err)
+	}
+
+	p := &PiOLED{opts: newOptions(opts)}
+	var err error
+	p.busCloser, err = i2creg.Open(p.opts.bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I²C: %w", err)
+	}
+	devOpts := ssd1306.Opts{
+		W: 128,
+		H: 32,
+
+		Sequential: true,
+		Rotated:    true,
+	}
+	p.dev, err = ssd1306.NewI2C(p.busCloser, &devOpts)
+	if err != nil {
+		p.busCloser.Close()
+		return nil, fmt.Errorf("failed to initialize ssd1306: %w", err)
+	}
+	p.frame = image1bit.NewVerticalLSB(p.dev.Bounds())
+	return p, nil
+}
+
+// New returns a PiOLED showing the state on dev. The bus option is
+// ignored, and Close leaves closing dev to the caller.
+func New(dev Device, opts ...Option) *PiOLED {
+	return &PiOLED{
+		opts:  newOptions(opts),
+		dev:   dev,
+		frame: image1bit.NewVerticalLSB(dev.Bounds()),
+	}
+}
+
+// Display updates the display according to current state
+func (p *PiOLED) Display() error {
+	for i := range p.frame.Pix {
+		p.frame.Pix[i] = 0
+	}
+	render(p.frame, image1bit.On, &p.opts, client.Current(), time.Now())
+	if p.shown != nil && bytes.Equal(p.frame.Pix, p.shown) {
+		return nil
+	}
+	if err := p.dev.Draw(p.dev.Bounds(), p.frame, image.Point{}); err != nil {
+		p.shown = nil
+		client.RenderFailed()
+		return fmt.Errorf("failed to draw: %w", err)
+	}
+	if p.shown == nil {
+		p.shown = make([]byte, len(p.frame.Pix))
+	}
+	copy(p.shown, p.frame.Pix)
+	return nil
+}
+
+// Invalidate makes the next Display draw the frame even if it's unchanged
+func (p *PiOLED) Invalidate() {
+	p.shown = nil
+}
+
+// SetBlank turns the display off; it is turned back on by the next call to
+// Display
+func (p *PiOLED) SetBlank(blank bool) error {
+	if !blank {
+		return nil
+	}
+	p.shown = nil
+	return p.dev.Halt()
+}
+
+// Font is Silkscreen: https://kottke.org/plus/type/silkscreen/
+//go:embed slkscr.ttf
+var silkscreenTTF []byte
+var silkscreenFace font.Face
+
+// basicFace is for the two main lines
+var basicFace font.Face = newGlyphCache(arrowFace{basicfont.Face7x13})
+
+func init() {
+	font, err := truetype.Parse(silkscreenTTF)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse embedded font TTF: %v", err))
+	}
+	silkscreenFace = newGlyphCache(truetype.NewFace(font, &truetype.Options{
+		Size:    8,
+		Hinting: 1,
+	}))
+}
+
+// render draws page onto dst (which should be blank) in color, with the
+// clock showing now
+func render(dst draw.Image, color color.Color, o *options, page client.Page, now time.Time) {
+	drawer := font.Drawer{
+		Dst:  dst,
+		Src:  &image.Uniform{color},
+		Face: basicFace,
+	}
+
+	// Manual adjustment to keep top-text flush with top of screen.
+	// Every pixel counts.
+	baseY := -2
+
+	lines := [...]string{
+		"waiting for",
+		"sensor data",
+	}
+
+	s := page.State
+
+	if page.System {
+		lines = [...]string{"reading", "system stats"}
+		if sys := s.System; sys != nil {
+			lines[0] = fmt.Sprintf("Load %.2f", sys.Load1)
+			if sys.SoCTemperature != 0 {
+				lines[0] += fmt.Sprintf(" %.0fC", sys.SoCTemperature)
+			}
+			lines[1] = fmt.Sprintf("Mem %.0f%% Disk %.0f%%", sys.MemoryUsedPercent, sys.DiskUsedPercent)
+		}
+	} else if page.Compare {
+		lines = [...]string{"Out ?", ""}
+		if display.Fresh(page.Outside) {
+			lines[0] = fmt.Sprintf("Out %.0fC", page.Outside.Temperature)
+		}
+		if display.Fresh(s) {
+			lines[0] += fmt.Sprintf("  In %.0fC", s.Temperature)
+			if display.Fresh(page.Outside) {
+				lines[1] = display.Difference(s.Temperature, page.Outside.Temperature, "C")
+			}
+		}
+	} else if page.Forecast {
+		lines = [...]string{"fetching", "forecast"}
+		if w := page.Weather; w != nil {
+			lines[0] = fmt.Sprintf("Out %.0fC", w.Temperature)
+			if display.Fresh(s) {
+				lines[0] += fmt.Sprintf("  In %.0fC", s.Temperature)
+			}
+			// There's only room for the chance of rain when it's likely
+			lines[1] = fmt.Sprintf("Tmrw %.0f/%.0fC", w.High, w.Low)
+			if w.RainChance >= 50 {
+				lines[1] += " rain"
+			}
+		}
+	} else if page.Sun {
+		lines = [...]string{"waiting for", "clock"}
+		if d := page.Daylight; d != nil {
+			switch {
+			case !d.Sunrise.IsZero():
+				lines[0] = fmt.Sprintf("Sun %s-%s",
+					display.TimeOfDay(d.Sunrise.In(o.location), o.clockLayout),
+					display.TimeOfDay(d.Sunset.In(o.location), o.clockLayout))
+			case d.Length > 0:
+				lines[0] = "Sun up all day"
+			default:
+				lines[0] = "Sun down all day"
+			}
+			lines[1] = "Daylight " + display.DayLength(d.Length)
+		}
+	} else if page.Web {
+		lines = [...]string{"Web UI:", "no IP address"}
+		if u, err := url.Parse(page.URL); err == nil && page.URL != "" {
+			lines = [...]string{u.Hostname(), "web UI"}
+			if port := u.Port(); port != "" {
+				lines[1] = "port " + port
+			}
+		}
+	} else if !s.LastSensorUpdate.IsZero() {
+		lines = [...]string{
+			// TODO: Use degree symbol °C,
+			fmt.Sprintf("Temp: %.0fC%s", s.Temperature, display.TrendArrow(s, "temperature")),
+			fmt.Sprintf("Humid: %.0f%%%s", s.Humidity, display.TrendArrow(s, "humidity")),
+		}
+		// Only call out uncomfortable climates, as there's little room
+		if c, ok := s.Comfort(); ok && c.Label != state.ComfortComfortable {
+			lines[1] = fmt.Sprintf("%s%s: %.0f%%%s", strings.ToUpper(c.Label[:1]), c.Label[1:], s.Humidity, display.TrendArrow(s, "humidity"))
+		}
+
+		if !display.TimesTrusted(s) {
+			lines[0] += " ?"
+		} else if s.IsStale(state.StaleAfter()) {
+			lines[0] += " STALE!"
+		} else if relays := display.RelayLabels(s); relays != "" {
+			lines[0] += " " + relays
+		}
+
+		// Indicate when showing a fallback server
+		if source := client.Source(); source > 1 && page.Main && !page.Local {
+			lines[1] += fmt.Sprintf(" #%d", source)
+		}
+	}
+
+	switch {
+	case page.System, page.Forecast, page.Compare, page.Sun, page.Web:
+		// The stats speak for themselves
+	case page.Local:
+		lines[1] += " LOCAL"
+	case page.Main && client.Unreachable():
+		// Don't silently show old numbers
+		lines[1] = "server unreachable"
+	case page.Label != "" && s.LastSensorUpdate.IsZero():
+		lines[1] = page.Label
+	case page.Label != "":
+		lines[1] += " " + page.Label
+	}
+
+	// The message, if any, replaces one of the main lines or the clock line
+	var message string
+	if m := page.Message; m.Text != "" {
+		switch {
+		case m.Line < 1:
+			lines[0] = m.Text
+		case m.Line <= len(lines):
+			lines[m.Line-1] = m.Text
+		default:
+			message = m.Text
+		}
+	}
+
+	// The web page's QR code takes the left of the display, and everything
+	// else is drawn to its right
+	left := 0
+	if page.Web && page.URL != "" {
+		if left = drawQR(dst, color, page.URL); left > 0 {
+			left += 2
+		}
+	}
+
+	for _, line := range lines {
+		baseY += drawer.Face.Metrics().Ascent.Ceil()
+		drawer.Dot = fixed.P(left, baseY)
+		drawer.DrawString(line)
+	}
+
+	clockMsg := display.Clock(now.In(o.location), o.clockLayout)
+	if o.wifiIface != "" {
+		if level, err := wifi.Signal(o.wifiIface); err == nil {
+			clockMsg += fmt.Sprintf("  %.0fdBm", level)
+		}
+	}
+	for _, power := range []string{display.PowerLabel(s), display.PowerWarning(s)} {
+		if power != "" {
+			clockMsg += "  " + power
+		}
+	}
+	if left > 0 {
+		// There's no room for the clock beside the QR code
+		clockMsg = "Scan for web UI"
+	}
+	// Active alerts replace the clock line of pages showing a node's state,
+	// with a banner flashing between inverted and normal every second
+	var banner bool
+	if alert := display.AlertLabel(s); alert != "" && !(page.System || page.Forecast || page.Compare || page.Sun || page.Web) {
+		clockMsg = alert
+		banner = now.Second()%2 == 1
+	}
+	if message != "" {
+		clockMsg, banner = message, false
+	}
+	drawer.Face = silkscreenFace
+
+	separator := dst.Bounds().Max.Y - drawer.Face.Metrics().Ascent.Ceil() - 1
+	if banner {
+		draw.Draw(dst, image.Rect(dst.Bounds().Min.X+left, separator, dst.Bounds().Max.X, dst.Bounds().Max.Y), &image.Uniform{color}, image.Point{}, draw.Src)
+		drawer.Src = image.Black
+	}
+	drawer.Dot = fixed.P(left, dst.Bounds().Dy())
+	drawer.DrawString(clockMsg)
+
+	for x := dst.Bounds().Min.X + left; x < dst.Bounds().Max.X; x++ {
+		dst.Set(x, separator, color)
+	}
+}
+
+// Close clears the display (unless disabled with WithClearOnClose) and
+// closes the I²C bus, if opened by Initialize
+func (p *PiOLED) Close() error {
+	slog.Info("Cleaning up pioled")
+	if p.opts.clearOnClose {
+		for i := range p.frame.Pix {
+			p.frame.Pix[i] = 0
+		}
+		if err := p.dev.Draw(p.dev.Bounds(), p.frame, image.Point{}); err != nil {
+			slog.Error("Failed to clear display", "err", err)
+		}
+	}
+	if p.busCloser == nil {
+		return nil
+	}
+	return p.busCloser.Close()
+}
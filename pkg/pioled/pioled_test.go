@@ -0,0 +1,102 @@
+package pioled_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/pioled"
+	"github.com/lutzky/pitemp/pkg/pioled/pioledtest"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// setState sets the state shown on the display; the clock is marked
+// invalid, so that the freshness shown doesn't depend on timing
+func setState(temperature float32) {
+	s := state.State{ClockInvalid: true}
+	s.SetReading("temperature", state.Reading{Value: temperature, Unit: "celsius", MeasuredAt: time.Now()})
+	state.Set(&s)
+}
+
+func newPiOLED() (*pioled.PiOLED, *pioledtest.Device) {
+	dev := &pioledtest.Device{}
+	// A layout without any time in it keeps the clock line fixed
+	return pioled.New(dev, pioled.WithClockLayout("clock")), dev
+}
+
+func TestDisplaySkipsUnchangedFrames(t *testing.T) {
+	setState(21)
+	p, dev := newPiOLED()
+	for i := 0; i < 3; i++ {
+		if err := p.Display(); err != nil {
+			t.Fatalf("Display failed: %v", err)
+		}
+	}
+	if dev.Draws != 1 {
+		t.Errorf("Drew %d frames of an unchanged state, want 1", dev.Draws)
+	}
+	if dev.Lit() == 0 {
+		t.Error("Nothing shown")
+	}
+
+	setState(22)
+	if err := p.Display(); err != nil {
+		t.Fatalf("Display failed: %v", err)
+	}
+	if dev.Draws != 2 {
+		t.Errorf("Drew %d frames after a change, want 2", dev.Draws)
+	}
+}
+
+func TestDisplayRedrawsAfterError(t *testing.T) {
+	setState(21)
+	p, dev := newPiOLED()
+	dev.Err = errors.New("I²C failure")
+	if err := p.Display(); err == nil {
+		t.Fatal("Display succeeded on a failing device")
+	}
+
+	dev.Err = nil
+	if err := p.Display(); err != nil {
+		t.Fatalf("Display failed: %v", err)
+	}
+	if dev.Draws != 1 {
+		t.Errorf("Drew %d frames after a failure, want 1", dev.Draws)
+	}
+}
+
+func TestSetBlank(t *testing.T) {
+	setState(21)
+	p, dev := newPiOLED()
+	if err := p.Display(); err != nil {
+		t.Fatalf("Display failed: %v", err)
+	}
+	if err := p.SetBlank(true); err != nil {
+		t.Fatalf("SetBlank(true) failed: %v", err)
+	}
+	if !dev.Halted {
+		t.Error("Not halted by SetBlank(true)")
+	}
+
+	// The same frame must be drawn again, to turn the display back on
+	if err := p.Display(); err != nil {
+		t.Fatalf("Display failed: %v", err)
+	}
+	if dev.Halted || dev.Draws != 2 {
+		t.Errorf("After Display, halted = %v with %d frames drawn, want false and 2", dev.Halted, dev.Draws)
+	}
+}
+
+func TestCloseClears(t *testing.T) {
+	setState(21)
+	p, dev := newPiOLED()
+	if err := p.Display(); err != nil {
+		t.Fatalf("Display failed: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := dev.Lit(); n != 0 {
+		t.Errorf("%d pixels lit after Close, want 0", n)
+	}
+}
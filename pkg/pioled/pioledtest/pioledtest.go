@@ -0,0 +1,68 @@
+// Package pioledtest provides an in-memory SSD1306 display, for testing
+// package pioled without hardware.
+package pioledtest
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Device is an in-memory 128x32 display, implementing pioled.Device. Its
+// zero value is ready to use.
+type Device struct {
+	// Err, if set, is returned by Draw and Halt instead of doing anything,
+	// to simulate I²C failures
+	Err error
+
+	// Frame is what the display shows, or nil before the first Draw
+	Frame *image.Gray
+
+	// Draws is how many times Draw succeeded
+	Draws int
+
+	// Halted is set by Halt, and cleared by Draw, which turns the display
+	// back on
+	Halted bool
+}
+
+// Bounds returns the size of the display
+func (d *Device) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 128, 32)
+}
+
+// Draw copies the r part of src, starting at sp, to the display
+func (d *Device) Draw(r image.Rectangle, src image.Image, sp image.Point) error {
+	if d.Err != nil {
+		return d.Err
+	}
+	if d.Frame == nil {
+		d.Frame = image.NewGray(d.Bounds())
+	}
+	draw.Draw(d.Frame, r, src, sp, draw.Src)
+	d.Draws++
+	d.Halted = false
+	return nil
+}
+
+// Halt turns the display off
+func (d *Device) Halt() error {
+	if d.Err != nil {
+		return d.Err
+	}
+	d.Halted = true
+	return nil
+}
+
+// Lit returns how many pixels are lit
+func (d *Device) Lit() int {
+	if d.Frame == nil {
+		return 0
+	}
+	n := 0
+	for _, p := range d.Frame.Pix {
+		if p != 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package pioled
+
+import (
+	"image/color"
+	"image/draw"
+	"net/url"
+	"strings"
+	"sync"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// lastQR caches the QR code last drawn, as the URL rarely changes while its
+// page is shown, and encoding it on every update would be wasteful
+var lastQR struct {
+	sync.Mutex
+	url     string
+	modules [][]bool
+}
+
+// qrModules returns the modules of the QR code for rawURL, dark ones true,
+// without a quiet zone
+func qrModules(rawURL string) ([][]bool, error) {
+	lastQR.Lock()
+	defer lastQR.Unlock()
+	if rawURL == lastQR.url && lastQR.modules != nil {
+		return lastQR.modules, nil
+	}
+	// The scheme and host are case-insensitive, and in upper case a URL
+	// such as HTTP://192.168.1.2:8080/ can be encoded in the alphanumeric
+	// mode, whose smaller codes fit larger modules into the display's height
+	content := rawURL
+	if u, err := url.Parse(rawURL); err == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == "" {
+		content = strings.ToUpper(rawURL)
+	}
+	q, err := qrcode.New(content, qrcode.Low)
+	if err != nil {
+		return nil, err
+	}
+	q.DisableBorder = true
+	lastQR.url, lastQR.modules = rawURL, q.Bitmap()
+	return lastQR.modules, nil
+}
+
+// drawQR draws the QR code for rawURL onto dst (which should be blank) in a
+// square at its left, as tall as dst, with one pixel per module. As the
+// display is lit on black, the square is lit around the code's dark
+// modules, leaving as much of a quiet zone as fits. It returns the width of
+// the square, or 0 if the code doesn't fit.
+func drawQR(dst draw.Image, c color.Color, rawURL string) int {
+	modules, err := qrModules(rawURL)
+	b := dst.Bounds()
+	size := b.Dy()
+	// Scanners need at least some quiet zone around the code
+	if err != nil || len(modules)+2 > size {
+		return 0
+	}
+	margin := (size - len(modules)) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			my, mx := y-margin, x-margin
+			if my >= 0 && my < len(modules) && mx >= 0 && mx < len(modules) && modules[my][mx] {
+				continue
+			}
+			dst.Set(b.Min.X+x, b.Min.Y+y, c)
+		}
+	}
+	return size
+}
@@ -0,0 +1,187 @@
+package pioled
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/internal/clock"
+	"github.com/lutzky/pitemp/pkg/client"
+	"github.com/lutzky/pitemp/pkg/state"
+
+	"periph.io/x/periph/devices/ssd1306/image1bit"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden images in testdata with the current rendering")
+
+// goldenNow is the time shown on the clock of the golden images
+var goldenNow = time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+
+// climate returns a state with temperature and humidity readings measured
+// at t, and optionally further readings
+func climate(temperature, humidity float32, t time.Time, more map[string]state.Reading) state.State {
+	var s state.State
+	s.SetReading("temperature", state.Reading{Value: temperature, Unit: "celsius", MeasuredAt: t})
+	s.SetReading("humidity", state.Reading{Value: humidity, Unit: "percent", MeasuredAt: t})
+	for name, r := range more {
+		r.MeasuredAt = t
+		s.SetReading(name, r)
+	}
+	return s
+}
+
+func TestRenderGolden(t *testing.T) {
+	// Staleness is relative to the real time, so fresh readings are taken
+	// just now, and stale ones long ago
+	fresh, stale := time.Now(), time.Now().Add(-24*time.Hour)
+	clock.MarkValid()
+
+	untrusted := climate(21, 40, fresh, nil)
+	untrusted.ClockInvalid = true
+
+	long := climate(-12.4, 100, fresh, map[string]state.Reading{
+		"battery": {Value: 100, Unit: "percent"},
+	})
+	long.SetRelay("heater", true)
+	long.SetRelay("fan", true)
+	long.Throttled = []string{"undervoltage", "throttled"}
+
+	alerting := climate(31, 40, fresh, nil)
+	alerting.ActiveAlerts = []string{"hot", "stale"}
+
+	trending := climate(21, 40, fresh, nil)
+	trending.Trends = map[string]float32{"temperature": 1.2, "humidity": -0.5}
+
+	tests := []struct {
+		name string
+		page client.Page
+	}{
+		{"waiting", client.Page{Main: true}},
+		{"fresh", client.Page{Main: true, State: climate(21, 40, fresh, nil)}},
+		{"stale", client.Page{Main: true, State: climate(21, 40, stale, nil)}},
+		{"untrusted", client.Page{Main: true, State: untrusted}},
+		{"long", client.Page{Main: true, State: long}},
+		{"trends", client.Page{Main: true, State: trending}},
+		{"alerts", client.Page{Main: true, State: alerting}},
+		{"local", client.Page{Main: true, Local: true, State: climate(21, 40, fresh, nil)}},
+		{"room", client.Page{Label: "garage", State: climate(8, 65, fresh, nil)}},
+		{"room_waiting", client.Page{Label: "garage"}},
+		{"message", client.Page{Main: true, State: climate(21, 40, fresh, nil), Message: client.Message{Text: "Washing done", Line: 2}}},
+		{"message_clock", client.Page{Main: true, State: climate(21, 40, fresh, nil), Message: client.Message{Text: "Washing done", Line: 3}}},
+		{"compare", client.Page{Label: "garage", Compare: true, State: climate(21, 40, fresh, nil), Outside: climate(8, 80, fresh, nil)}},
+		{"forecast", client.Page{Label: "forecast", Forecast: true, State: climate(21, 40, fresh, nil), Weather: &client.Weather{
+			Temperature: 8, High: 12, Low: -4, RainChance: 60,
+		}}},
+		{"sun", client.Page{Label: "sun", Sun: true, Daylight: &client.Daylight{
+			Sunrise: time.Date(2024, 3, 5, 6, 16, 0, 0, time.UTC),
+			Sunset:  time.Date(2024, 3, 5, 17, 52, 0, 0, time.UTC),
+			Length:  11*time.Hour + 36*time.Minute,
+		}}},
+		{"web", client.Page{Label: "web", Web: true, URL: "http://192.168.1.23:8080/"}},
+		{"web_no_ip", client.Page{Label: "web", Web: true}},
+		{"system", client.Page{Label: "system", System: true, State: state.State{System: &state.System{
+			Load1: 0.52, MemoryUsedPercent: 41.2, DiskUsedPercent: 73.9, SoCTemperature: 48.3,
+		}}}},
+	}
+	o := newOptions([]Option{WithLocation(time.UTC)})
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := image.NewPaletted(image.Rect(0, 0, 128, 32), color.Palette{color.Black, color.White})
+			render(got, color.White, &o, tc.page, goldenNow)
+
+			path := filepath.Join("testdata", tc.name+".png")
+			if *update {
+				if err := writePNG(path, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := readPNG(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if diff := diffPixels(got, want); diff > 0 {
+				actual := filepath.Join(t.TempDir(), tc.name+".png")
+				if err := writePNG(actual, got); err != nil {
+					t.Error(err)
+				}
+				t.Errorf("%d pixels differ from %s; got %s (run with -update to accept it)", diff, path, actual)
+			}
+		})
+	}
+}
+
+// diffPixels returns how many pixels of got and want differ in whether
+// they're lit, counting all pixels if their sizes differ
+func diffPixels(got, want image.Image) int {
+	b := got.Bounds()
+	if b != want.Bounds() {
+		return b.Dx() * b.Dy()
+	}
+	lit := func(img image.Image, x, y int) bool {
+		return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y >= 0x80
+	}
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if lit(got, x, y) != lit(want, x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func BenchmarkRender(b *testing.B) {
+	clock.MarkValid()
+	page := client.Page{Main: true, State: climate(21, 40, time.Now(), nil)}
+	o := newOptions(nil)
+	img := image1bit.NewVerticalLSB(image.Rect(0, 0, 128, 32))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range img.Pix {
+			img.Pix[j] = 0
+		}
+		render(img, image1bit.On, &o, page, goldenNow)
+	}
+}
+
+func BenchmarkHTTPHandler(b *testing.B) {
+	h := HTTPHandler()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,82 @@
+// Package dht11 registers the "dht11" sensor driver, for additional DHT11
+// sensors beyond the one read by pitemp's main loop. Its configuration
+// options are pin (the GPIO pin, required) and retries (default 10).
+package dht11
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/d2r2/go-dht"
+
+	"github.com/lutzky/pitemp/pkg/sensor"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func init() {
+	sensor.Register("dht11", New)
+}
+
+// Reader reads a DHT11 on a GPIO pin, retrying up to retries times, and
+// returns how many retries it took. Hardware reads a real one; tests can
+// use a fake, such as sensortest.DHT11.
+type Reader interface {
+	ReadDHT11(ctx context.Context, pin int, realtime bool, retries int) (temperature, humidity float32, retried int, err error)
+}
+
+// hardware reads DHT11s through go-dht; realtime raises the reading
+// thread's scheduling priority
+type hardware struct{}
+
+func (hardware) ReadDHT11(ctx context.Context, pin int, realtime bool, retries int) (float32, float32, int, error) {
+	return dht.ReadDHTxxWithContextAndRetry(ctx, dht.DHT11, pin, realtime, retries)
+}
+
+// Hardware is the Reader for real DHT11s
+var Hardware Reader = hardware{}
+
+type dht11 struct {
+	reader       Reader
+	pin, retries int
+}
+
+// New creates a DHT11 sensor from config, e.g. "pin=17,retries=5"
+func New(config string) (sensor.Sensor, error) {
+	options, err := sensor.ParseConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	d := &dht11{reader: Hardware, retries: 10}
+	for key, value := range options {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		switch key {
+		case "pin":
+			d.pin = n
+		case "retries":
+			d.retries = n
+		default:
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	if _, ok := options["pin"]; !ok {
+		return nil, fmt.Errorf("missing pin")
+	}
+	return d, nil
+}
+
+func (d *dht11) Read(ctx context.Context) (map[string]state.Reading, error) {
+	temperature, humidity, _, err := d.reader.ReadDHT11(ctx, d.pin, false, d.retries)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Now()
+	return map[string]state.Reading{
+		"temperature": {Value: temperature, Unit: "celsius", Sensor: "dht11", MeasuredAt: t},
+		"humidity":    {Value: humidity, Unit: "percent", Sensor: "dht11", MeasuredAt: t},
+	}, nil
+}
@@ -0,0 +1,132 @@
+// Package ina219 reads supply voltage and current from an INA219 power
+// monitor on the I²C bus, so that battery- or solar-powered nodes can report
+// their own power state. It also registers the "ina219" sensor driver, whose
+// configuration options are bus (default 1), addr (default 0x40) and shunt
+// (the shunt resistance in ohms, default 0.1 as on most breakout boards).
+package ina219
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/d2r2/go-i2c"
+
+	"github.com/lutzky/pitemp/pkg/sensor"
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+func init() {
+	sensor.Register("ina219", newSensor)
+}
+
+// Registers
+const (
+	regConfig       = 0x00
+	regShuntVoltage = 0x01
+	regBusVoltage   = 0x02
+)
+
+// configContinuous is the power-on default configuration: 32V bus range,
+// ±320mV shunt range, 12-bit conversions of both, continuously. It's written
+// on every read, in case a brownout reset the chip or something powered it
+// down.
+const configContinuous = 0x399f
+
+// INA219 is an open INA219
+type INA219 struct {
+	mu    sync.Mutex
+	i2c   *i2c.I2C
+	shunt float64
+}
+
+// Open opens the INA219 at addr on I²C bus, with a shunt resistor of shunt
+// ohms
+func Open(bus int, addr uint8, shunt float64) (*INA219, error) {
+	if shunt <= 0 {
+		return nil, fmt.Errorf("invalid shunt resistance %v", shunt)
+	}
+	c, err := i2c.NewI2C(addr, bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C: %w", err)
+	}
+	return &INA219{i2c: c, shunt: shunt}, nil
+}
+
+// Measure returns the bus voltage (in volts) and the current through the
+// shunt (in amperes, negative when flowing backwards, e.g. when charging)
+func (d *INA219) Measure() (voltage, current float64, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.i2c.WriteRegU16BE(regConfig, configContinuous); err != nil {
+		return 0, 0, fmt.Errorf("failed to configure INA219: %w", err)
+	}
+
+	bus, err := d.i2c.ReadRegU16BE(regBusVoltage)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read bus voltage: %w", err)
+	}
+	if bus&0x1 != 0 {
+		return 0, 0, fmt.Errorf("INA219 overflow, current exceeds the shunt range")
+	}
+	shunt, err := d.i2c.ReadRegS16BE(regShuntVoltage)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read shunt voltage: %w", err)
+	}
+
+	// The bus voltage is in bits 15-3, in 4mV units; the shunt voltage is in
+	// 10µV units
+	voltage = float64(bus>>3) * 0.004
+	current = float64(shunt) * 0.00001 / d.shunt
+	return voltage, current, nil
+}
+
+// Read implements sensor.Sensor, returning voltage, current and power
+// readings
+func (d *INA219) Read(ctx context.Context) (map[string]state.Reading, error) {
+	voltage, current, err := d.Measure()
+	if err != nil {
+		return nil, err
+	}
+	t := time.Now()
+	return map[string]state.Reading{
+		"voltage": {Value: float32(voltage), Unit: "volt", Sensor: "ina219", MeasuredAt: t},
+		"current": {Value: float32(current), Unit: "ampere", Sensor: "ina219", MeasuredAt: t},
+		"power":   {Value: float32(voltage * current), Unit: "watt", Sensor: "ina219", MeasuredAt: t},
+	}, nil
+}
+
+// Close closes the I²C channel
+func (d *INA219) Close() error {
+	return d.i2c.Close()
+}
+
+// newSensor creates an INA219 sensor from config, e.g. "addr=0x41,shunt=0.01"
+func newSensor(config string) (sensor.Sensor, error) {
+	options, err := sensor.ParseConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	bus, addr, shunt := 1, uint8(0x40), 0.1
+	for key, value := range options {
+		switch key {
+		case "bus":
+			bus, err = strconv.Atoi(value)
+		case "addr":
+			var n uint64
+			n, err = strconv.ParseUint(value, 0, 7)
+			addr = uint8(n)
+		case "shunt":
+			shunt, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	return Open(bus, addr, shunt)
+}
@@ -0,0 +1,94 @@
+// Package sensor defines the interface for sensor drivers, and a registry
+// of them by name. Drivers register themselves when their package is
+// imported, so out-of-tree drivers can be compiled into pitemp by adding a
+// blank import, e.g. in a new file in cmd/pitemp:
+//
+//	import _ "example.com/pitemp-bme280"
+//
+// and then used with --sensor=NAME=DRIVER:CONFIG.
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Sensor is an instance of a driver, e.g. a DHT11 on a particular pin.
+// Sensors which are also an io.Closer are closed when no longer needed.
+type Sensor interface {
+	// Read returns the current readings, by name (e.g. "temperature"). The
+	// readings' Node is ignored.
+	Read(ctx context.Context) (map[string]state.Reading, error)
+}
+
+// Factory creates a Sensor from its configuration, a comma-separated list
+// of KEY=VALUE options (see ParseConfig), which may be empty
+type Factory func(config string) (Sensor, error)
+
+var registry = struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}{factories: map[string]Factory{}}
+
+// Register makes a driver available by name; it is meant to be called from
+// the init function of the driver's package, and panics if name is already
+// registered.
+func Register(name string, factory Factory) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if factory == nil {
+		panic("sensor: Register factory is nil")
+	}
+	if _, ok := registry.factories[name]; ok {
+		panic("sensor: Register called twice for driver " + name)
+	}
+	registry.factories[name] = factory
+}
+
+// New creates a sensor using the driver registered as name
+func New(name, config string) (Sensor, error) {
+	registry.mu.RLock()
+	factory, ok := registry.factories[name]
+	registry.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sensor driver %q (available: %s)", name, strings.Join(Drivers(), ", "))
+	}
+	s, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s sensor: %w", name, err)
+	}
+	return s, nil
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseConfig parses a driver configuration such as "pin=4,retries=10"
+func ParseConfig(config string) (map[string]string, error) {
+	options := map[string]string{}
+	for _, entry := range strings.Split(config, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid option %q, expected KEY=VALUE", entry)
+		}
+		options[parts[0]] = parts[1]
+	}
+	return options, nil
+}
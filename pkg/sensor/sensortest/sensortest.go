@@ -0,0 +1,110 @@
+// Package sensortest provides fake sensors, for testing the sensor loops
+// without hardware.
+package sensortest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// Sensor is a fake sensor.Sensor, returning whatever readings it was last
+// given. It is safe for concurrent use.
+type Sensor struct {
+	mu       sync.Mutex
+	readings map[string]state.Reading
+	err      error
+	reads    int
+}
+
+// Set sets the readings returned by Read, by name (e.g. "temperature"),
+// and clears any error; their MeasuredAt is set when read, if unset
+func (s *Sensor) Set(readings map[string]state.Reading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readings, s.err = readings, nil
+}
+
+// Fail makes Read return err
+func (s *Sensor) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Read returns the readings given to Set, or the error given to Fail
+func (s *Sensor) Read(ctx context.Context) (map[string]state.Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	readings := make(map[string]state.Reading, len(s.readings))
+	for name, r := range s.readings {
+		if r.MeasuredAt.IsZero() {
+			r.MeasuredAt = now
+		}
+		readings[name] = r
+	}
+	return readings, nil
+}
+
+// Reads returns how many times Read was called
+func (s *Sensor) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// DHT11 is a fake dht11.Reader. It is safe for concurrent use.
+type DHT11 struct {
+	mu                    sync.Mutex
+	temperature, humidity float32
+	retried               int
+	err                   error
+	reads                 int
+}
+
+// Set sets the temperature and humidity returned by ReadDHT11, and how many
+// retries it reports, clearing any error
+func (d *DHT11) Set(temperature, humidity float32, retried int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.temperature, d.humidity, d.retried, d.err = temperature, humidity, retried, nil
+}
+
+// Fail makes ReadDHT11 return err, after all retries
+func (d *DHT11) Fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+// ReadDHT11 returns the values given to Set, or the error given to Fail,
+// regardless of the pin
+func (d *DHT11) ReadDHT11(ctx context.Context, pin int, realtime bool, retries int) (float32, float32, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reads++
+	if d.err != nil {
+		return 0, 0, retries, d.err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, 0, err
+	}
+	return d.temperature, d.humidity, d.retried, nil
+}
+
+// Reads returns how many times ReadDHT11 was called
+func (d *DHT11) Reads() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reads
+}
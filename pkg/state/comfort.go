@@ -0,0 +1,65 @@
+package state
+
+import "math"
+
+// Labels for Comfort, from the humidex and the humidity
+const (
+	ComfortCold        = "cold"
+	ComfortDry         = "dry"
+	ComfortComfortable = "comfortable"
+	ComfortDamp        = "damp"
+	ComfortHot         = "hot"
+	ComfortMuggy       = "muggy"
+	ComfortOppressive  = "oppressive"
+)
+
+// Comfort is how the climate of a state feels, for people who don't know
+// what to make of a relative humidity
+type Comfort struct {
+	// Humidex is the temperature as felt given the humidity, in °C, as
+	// defined by Environment Canada
+	Humidex float64
+
+	// Label describes how the climate feels: one of the Comfort*
+	// constants, e.g. ComfortMuggy
+	Label string
+}
+
+// Comfort returns how the climate of s feels, or false without both
+// temperature and humidity readings
+func (s State) Comfort() (Comfort, bool) {
+	t, ok := s.Reading("temperature")
+	if !ok {
+		return Comfort{}, false
+	}
+	h, ok := s.Reading("humidity")
+	if !ok {
+		return Comfort{}, false
+	}
+	celsius, humidity := float64(t.Value), float64(h.Value)
+
+	// The vapour pressure in hPa, using the Magnus formula
+	vapour := 6.112 * math.Pow(10, 7.5*celsius/(237.7+celsius)) * humidity / 100
+	c := Comfort{Humidex: celsius + 5.0/9*(vapour-10)}
+
+	// A humidex of 30 causes some discomfort, and 40 great discomfort;
+	// below that, a room is comfortable between 18°C (per the WHO) and a
+	// relative humidity of 30-70%
+	switch {
+	case c.Humidex >= 40:
+		c.Label = ComfortOppressive
+	case c.Humidex >= 30 && humidity >= 50:
+		c.Label = ComfortMuggy
+	case c.Humidex >= 30:
+		c.Label = ComfortHot
+	case celsius < 18:
+		c.Label = ComfortCold
+	case humidity < 30:
+		c.Label = ComfortDry
+	case humidity > 70:
+		c.Label = ComfortDamp
+	default:
+		c.Label = ComfortComfortable
+	}
+	return c, true
+}
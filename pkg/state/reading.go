@@ -0,0 +1,75 @@
+package state
+
+import "time"
+
+// Reading is a single named value, such as a temperature
+type Reading struct {
+	Value float32 `json:"value"`
+	Unit  string  `json:"unit"`
+
+	// Sensor is the type of sensor the reading came from, e.g. dht11
+	Sensor string `json:"sensor,omitempty"`
+
+	// Node is where the reading came from: empty for this node, or e.g. the
+	// URL of a remote pitemp server or the name of a node pushing readings
+	Node string `json:"node,omitempty"`
+
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// SetReading sets the reading called name (e.g. "temperature"), updating
+// the corresponding legacy fields.
+func (s *State) SetReading(name string, r Reading) {
+	readings := make(map[string]Reading, len(s.Readings)+1)
+	for k, v := range s.Readings {
+		readings[k] = v
+	}
+	readings[name] = r
+	s.Readings = readings
+
+	switch name {
+	case "temperature":
+		s.Temperature = r.Value
+	case "humidity":
+		s.Humidity = r.Value
+	}
+	if r.MeasuredAt.After(s.LastSensorUpdate) {
+		s.LastSensorUpdate = r.MeasuredAt
+	}
+}
+
+// Reading returns the reading called name, if any. States built using only
+// the legacy fields (e.g. restored from an old snapshot) have temperature
+// and humidity readings too.
+func (s State) Reading(name string) (Reading, bool) {
+	if s.Readings == nil {
+		s.fillReadings()
+	}
+	r, ok := s.Readings[name]
+	return r, ok
+}
+
+// SetNode sets the node of the readings that don't have one, i.e. were read
+// by the node s was received from.
+func (s *State) SetNode(node string) {
+	s.fillReadings()
+	for name, r := range s.Readings {
+		if r.Node == "" {
+			r.Node = node
+			s.SetReading(name, r)
+		}
+	}
+}
+
+// fillReadings sets the readings of a state built using only the legacy
+// fields, e.g. decoded from an older server.
+func (s *State) fillReadings() {
+	if s.Readings != nil || s.LastSensorUpdate.IsZero() {
+		return
+	}
+	t := s.LastSensorUpdate
+	s.Readings = map[string]Reading{
+		"temperature": {Value: s.Temperature, Unit: "celsius", MeasuredAt: t},
+		"humidity":    {Value: s.Humidity, Unit: "percent", MeasuredAt: t},
+	}
+}
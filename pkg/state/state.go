@@ -0,0 +1,232 @@
+// Package state holds the current readings of a pitemp node, and of any
+// additional sources such as remote nodes, for the various consumers
+// (HTTP handlers, displays, sinks) to share.
+package state
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var state = struct {
+	mu sync.RWMutex
+
+	State
+}{}
+
+// Get the current state; thread-safe
+func Get() State {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	return state.State
+}
+
+// Set the current state, replacing all of it; thread-safe. Writers of only
+// some of the fields (e.g. a single reading) should use Update instead.
+func Set(s *State) {
+	s.fillReadings()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.State = *s
+	notify(*s)
+}
+
+// Update the current state by applying f to it, and return the result;
+// thread-safe. Concurrent updates of different fields don't clobber each
+// other.
+func Update(f func(*State)) State {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	f(&state.State)
+	state.State.fillReadings()
+	s := state.State
+	notify(s)
+	return s
+}
+
+var subscribers = struct {
+	mu sync.Mutex
+
+	chans map[chan State]struct{}
+}{chans: map[chan State]struct{}{}}
+
+// Subscribe returns a channel receiving the new state whenever it is Set, and
+// a function to cancel the subscription. Subscribers that fall behind only
+// receive the latest state.
+func Subscribe() (<-chan State, func()) {
+	ch := make(chan State, 1)
+
+	subscribers.mu.Lock()
+	subscribers.chans[ch] = struct{}{}
+	subscribers.mu.Unlock()
+
+	cancel := func() {
+		subscribers.mu.Lock()
+		delete(subscribers.chans, ch)
+		subscribers.mu.Unlock()
+	}
+	return ch, cancel
+}
+
+// notify sends s to the subscribers. It's called with state.mu held, so
+// that concurrent updates reach subscribers in the order they were made,
+// and none is left with an older state; it doesn't block.
+func notify(s State) {
+	subscribers.mu.Lock()
+	defer subscribers.mu.Unlock()
+
+	for ch := range subscribers.chans {
+		// Replace any state the subscriber hasn't consumed yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- s
+	}
+}
+
+// State represents the global state for pitemp
+type State struct {
+	// Temperature, Humidity and LastSensorUpdate mirror the "temperature"
+	// and "humidity" readings, for consumers (and JSON clients) predating
+	// Readings. Set them using SetReading.
+	Temperature, Humidity float32
+	LastSensorUpdate      time.Time
+
+	// IP is the address of the node
+	IP string
+
+	// Location is where the node is (e.g. bedroom), as configured on it
+	Location string `json:"location,omitempty"`
+
+	// Readings holds the latest readings, by name (e.g. "temperature"). It
+	// is shared between copies of the state, so it must not be modified in
+	// place; use SetReading.
+	Readings map[string]Reading `json:"readings,omitempty"`
+
+	// Trends holds the rate of change of readings, by name, in units per
+	// hour (e.g. °C/h), where there's enough history to tell
+	Trends map[string]float32 `json:"trends,omitempty"`
+
+	// Relays holds whether each relay controlled by the node (e.g. a
+	// thermostat's heater) is on, by name. Like Readings, it must not be
+	// modified in place; use SetRelay.
+	Relays map[string]bool `json:"relays,omitempty"`
+
+	// Throttled lists the undervoltage and throttling conditions currently
+	// reported by the node's Raspberry Pi firmware (e.g. "undervoltage"),
+	// if any
+	Throttled []string `json:"throttled,omitempty"`
+
+	// System holds stats about the node's host, if collected
+	System *System `json:"system,omitempty"`
+
+	// ClockInvalid is true if the node's clock couldn't be trusted (e.g.
+	// not yet NTP-synced after boot), so neither can LastSensorUpdate and
+	// the times of the readings
+	ClockInvalid bool `json:"clock_invalid,omitempty"`
+
+	// ActiveAlerts names the node's active alert rules, as reported by it
+	ActiveAlerts []string `json:"active_alerts,omitempty"`
+}
+
+// System holds stats about a host, such as a Raspberry Pi
+type System struct {
+	// Load1, Load5 and Load15 are the load averages over 1, 5 and 15
+	// minutes
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+
+	MemoryUsedPercent float64 `json:"memory_used_percent"`
+	DiskUsedPercent   float64 `json:"disk_used_percent"`
+
+	// SoCTemperature is the temperature of the system-on-chip in °C, or 0
+	// if unknown
+	SoCTemperature float64 `json:"soc_temperature_celsius,omitempty"`
+}
+
+// RelaysOn returns the names of the relays which are on, sorted
+func (s State) RelaysOn() []string {
+	var names []string
+	for name, on := range s.Relays {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetRelay records whether the relay called name is on
+func (s *State) SetRelay(name string, on bool) {
+	relays := make(map[string]bool, len(s.Relays)+1)
+	for k, v := range s.Relays {
+		relays[k] = v
+	}
+	relays[name] = on
+	s.Relays = relays
+}
+
+var sources = struct {
+	mu sync.RWMutex
+
+	m map[string]State
+}{m: map[string]State{}}
+
+// SetSource sets the state of a named additional source, such as a remote
+// pitemp node; thread-safe
+func SetSource(name string, s State) {
+	s.fillReadings()
+
+	sources.mu.Lock()
+	defer sources.mu.Unlock()
+
+	sources.m[name] = s
+}
+
+// Sources returns a copy of the state of all additional sources, by name;
+// thread-safe
+func Sources() map[string]State {
+	sources.mu.RLock()
+	defer sources.mu.RUnlock()
+
+	result := make(map[string]State, len(sources.m))
+	for name, s := range sources.m {
+		result[name] = s
+	}
+	return result
+}
+
+// staleAfter is the threshold returned by StaleAfter, in nanoseconds
+var staleAfter = int64(5 * time.Minute)
+
+// StaleAfter returns how old state has to be to be considered stale; 5
+// minutes unless changed by SetStaleAfter
+func StaleAfter() time.Duration {
+	return time.Duration(atomic.LoadInt64(&staleAfter))
+}
+
+// SetStaleAfter changes the threshold returned by StaleAfter. It is safe to
+// call while other goroutines check staleness.
+func SetStaleAfter(d time.Duration) {
+	atomic.StoreInt64(&staleAfter, int64(d))
+}
+
+// Age returns how long ago the state was last updated, or 0 if it never was
+func (s State) Age() time.Duration {
+	if s.LastSensorUpdate.IsZero() {
+		return 0
+	}
+	return time.Since(s.LastSensorUpdate)
+}
+
+// IsStale reports whether s has no reading newer than threshold (usually
+// StaleAfter)
+func (s State) IsStale(threshold time.Duration) bool {
+	return s.LastSensorUpdate.IsZero() || s.Age() > threshold
+}
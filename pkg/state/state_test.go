@@ -0,0 +1,30 @@
+package state
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSubscribeConcurrentUpdates checks that subscribers are left with the
+// latest state, however concurrent updates interleave
+func TestSubscribeConcurrentUpdates(t *testing.T) {
+	defer Set(&State{})
+	Set(&State{})
+	ch, cancel := Subscribe()
+	defer cancel()
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Update(func(s *State) { s.Temperature++ })
+		}()
+	}
+	wg.Wait()
+
+	if got := <-ch; got.Temperature != n {
+		t.Errorf("Subscriber got temperature %v, want %v", got.Temperature, n)
+	}
+}
@@ -0,0 +1,154 @@
+// Package wire defines the JSON format of the state served on /api and /ws,
+// decoupled from state.State so that the two can evolve separately.
+//
+// Within a SchemaVersion, fields are only ever added, so that older clients
+// keep working. A renamed field keeps being sent under its old name too,
+// marked as deprecated, and is only removed along with bumping
+// SchemaVersion. Clients ignore fields they don't know, and treat a newer
+// SchemaVersion on a best-effort basis.
+package wire
+
+import (
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+// SchemaVersion is the version of the format
+const SchemaVersion = 1
+
+// Reading is a single named value, such as a temperature
+type Reading struct {
+	Value      float32   `json:"value"`
+	Unit       string    `json:"unit"`
+	Sensor     string    `json:"sensor,omitempty"`
+	Node       string    `json:"node,omitempty"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// System holds stats about the host of a node
+type System struct {
+	Load1             float64 `json:"load1"`
+	Load5             float64 `json:"load5"`
+	Load15            float64 `json:"load15"`
+	MemoryUsedPercent float64 `json:"memory_used_percent"`
+	DiskUsedPercent   float64 `json:"disk_used_percent"`
+	SoCTemperature    float64 `json:"soc_temperature_celsius,omitempty"`
+}
+
+// Comfort is how the climate of a node feels
+type Comfort struct {
+	// Humidex is the temperature as felt given the humidity, in °C
+	Humidex float64 `json:"humidex"`
+
+	// Label is e.g. "dry", "comfortable" or "muggy"
+	Label string `json:"label"`
+}
+
+// Sun holds today's sunrise and sunset where a node is
+type Sun struct {
+	// Sunrise and Sunset are omitted if the sun doesn't rise or set today,
+	// e.g. during a polar night
+	Sunrise *time.Time `json:"sunrise,omitempty"`
+	Sunset  *time.Time `json:"sunset,omitempty"`
+
+	DaylightSeconds int64 `json:"daylight_seconds"`
+}
+
+// State is the state of a node
+type State struct {
+	// SchemaVersion is 0 for servers predating it
+	SchemaVersion int `json:"schema_version"`
+
+	// Deprecated: Temperature, Humidity and LastSensorUpdate are kept for
+	// clients predating Readings.
+	Temperature      float32   `json:"Temperature"`
+	Humidity         float32   `json:"Humidity"`
+	LastSensorUpdate time.Time `json:"LastSensorUpdate"`
+
+	IP        string             `json:"IP"`
+	Location  string             `json:"location,omitempty"`
+	Readings  map[string]Reading `json:"readings,omitempty"`
+	Trends    map[string]float32 `json:"trends,omitempty"`
+	Relays    map[string]bool    `json:"relays,omitempty"`
+	Throttled []string           `json:"throttled,omitempty"`
+	System    *System            `json:"system,omitempty"`
+
+	// Comfort is derived from the temperature and humidity readings, if
+	// both are present
+	Comfort *Comfort `json:"comfort,omitempty"`
+
+	// ClockInvalid is true if the node's clock couldn't be trusted (e.g.
+	// not yet NTP-synced after boot), so neither can the times in the
+	// state
+	ClockInvalid bool `json:"clock_invalid,omitempty"`
+
+	// ActiveAlerts names the node's active alert rules, if any
+	ActiveAlerts []string `json:"active_alerts,omitempty"`
+
+	// Hostname, UptimeSeconds and Version identify the node serving the
+	// state, so they're only set for its own state (and not e.g. for
+	// aggregated sources)
+	Hostname      string `json:"hostname,omitempty"`
+	UptimeSeconds int64  `json:"uptime_seconds,omitempty"`
+	Version       string `json:"version,omitempty"`
+
+	// Sun is only set for the node's own state too, if its latitude and
+	// longitude are configured
+	Sun *Sun `json:"sun,omitempty"`
+}
+
+// FromState returns s in the wire format
+func FromState(s state.State) State {
+	w := State{
+		SchemaVersion:    SchemaVersion,
+		Temperature:      s.Temperature,
+		Humidity:         s.Humidity,
+		LastSensorUpdate: s.LastSensorUpdate,
+		IP:               s.IP,
+		Location:         s.Location,
+		Trends:           s.Trends,
+		Relays:           s.Relays,
+		Throttled:        s.Throttled,
+		ClockInvalid:     s.ClockInvalid,
+		ActiveAlerts:     s.ActiveAlerts,
+	}
+	if s.System != nil {
+		sys := System(*s.System)
+		w.System = &sys
+	}
+	if c, ok := s.Comfort(); ok {
+		w.Comfort = &Comfort{Humidex: c.Humidex, Label: c.Label}
+	}
+	if len(s.Readings) > 0 {
+		w.Readings = make(map[string]Reading, len(s.Readings))
+		for name, r := range s.Readings {
+			w.Readings[name] = Reading(r)
+		}
+	}
+	return w
+}
+
+// State returns w as a state.State
+func (w State) State() state.State {
+	s := state.State{
+		Temperature:      w.Temperature,
+		Humidity:         w.Humidity,
+		LastSensorUpdate: w.LastSensorUpdate,
+		IP:               w.IP,
+		Location:         w.Location,
+		Trends:           w.Trends,
+		Relays:           w.Relays,
+		Throttled:        w.Throttled,
+		ClockInvalid:     w.ClockInvalid,
+		ActiveAlerts:     w.ActiveAlerts,
+	}
+	if w.System != nil {
+		sys := state.System(*w.System)
+		s.System = &sys
+	}
+	for name, r := range w.Readings {
+		s.SetReading(name, state.Reading(r))
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package wire_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+// benchState is a typical state, with a few readings and trends
+func benchState() state.State {
+	var s state.State
+	now := time.Now()
+	s.SetReading("temperature", state.Reading{Value: 21.5, Unit: "celsius", Sensor: "dht11", MeasuredAt: now})
+	s.SetReading("humidity", state.Reading{Value: 40, Unit: "percent", Sensor: "dht11", MeasuredAt: now})
+	s.SetReading("voltage", state.Reading{Value: 4.9, Unit: "volts", Sensor: "ina219", MeasuredAt: now})
+	s.Trends = map[string]float32{"temperature": 0.4, "humidity": -1.2}
+	s.IP = "192.168.1.2"
+	return s
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	s := benchState()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(wire.FromState(s)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	body, err := json.Marshal(wire.FromState(benchState()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var w wire.State
+		if err := json.Unmarshal(body, &w); err != nil {
+			b.Fatal(err)
+		}
+		w.State()
+	}
+}
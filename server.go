@@ -0,0 +1,205 @@
+// Package pitemp serves the state of a pitemp node over HTTP, for embedding
+// pitemp's endpoints in a larger Go service. The readings themselves are
+// set using package github.com/lutzky/pitemp/pkg/state, for example:
+//
+//	srv := pitemp.NewServer(pitemp.WithAddr(":8080"), pitemp.WithPath(pitemp.EndpointState, "/temperature"))
+//	if err := srv.Start(); err != nil {
+//		...
+//	}
+//	defer srv.Shutdown(ctx)
+//
+// or, to mount the endpoints on an existing mux:
+//
+//	mux.Handle("/pitemp/", http.StripPrefix("/pitemp", pitemp.NewServer().Handler()))
+package pitemp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/lutzky/pitemp/pkg/state"
+	"github.com/lutzky/pitemp/pkg/wire"
+)
+
+// Built-in endpoints, which can be moved or disabled with WithPath
+const (
+	// EndpointState serves the state (and sources) as JSON in the wire
+	// format, at /api by default
+	EndpointState = "state"
+
+	// EndpointWS pushes the state in the wire format over a WebSocket
+	// whenever it changes, at /ws by default
+	EndpointWS = "ws"
+)
+
+// Server serves pitemp's endpoints on a mux of its own
+type Server struct {
+	mux     *http.ServeMux
+	handler http.Handler
+	srv     *http.Server
+
+	addr     string
+	mu       sync.Mutex
+	listener net.Listener
+
+	paths      map[string]string
+	handlers   map[string]http.Handler
+	order      []string
+	middleware []func(http.Handler) http.Handler
+	wrap       func(pattern string, h http.Handler) http.Handler
+	wireState  func(state.State) wire.State
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithAddr sets the TCP address Start listens on (default ":8080")
+func WithAddr(addr string) Option {
+	return func(s *Server) { s.addr = addr }
+}
+
+// WithListener makes Start serve on l, rather than listening on an address
+func WithListener(l net.Listener) Option {
+	return func(s *Server) { s.listener = l }
+}
+
+// WithPath serves endpoint (e.g. EndpointState) at path; an empty path
+// disables it
+func WithPath(endpoint, path string) Option {
+	return func(s *Server) { s.paths[endpoint] = path }
+}
+
+// WithHandler serves h at pattern, replacing the built-in endpoint served
+// there, if any
+func WithHandler(pattern string, h http.Handler) Option {
+	return func(s *Server) {
+		if _, ok := s.handlers[pattern]; !ok {
+			s.order = append(s.order, pattern)
+		}
+		s.handlers[pattern] = h
+	}
+}
+
+// WithMiddleware wraps the whole mux with m; the first middleware given is
+// the outermost
+func WithMiddleware(m func(http.Handler) http.Handler) Option {
+	return func(s *Server) { s.middleware = append(s.middleware, m) }
+}
+
+// WithHandlerWrapper wraps each endpoint's handler with wrap, which is
+// given the endpoint's pattern (e.g. for per-endpoint metrics)
+func WithHandlerWrapper(wrap func(pattern string, h http.Handler) http.Handler) Option {
+	return func(s *Server) { s.wrap = wrap }
+}
+
+// WithWireState sets how the state is converted to the wire format for the
+// built-in endpoints (default wire.FromState), e.g. to set its Location
+func WithWireState(f func(state.State) wire.State) Option {
+	return func(s *Server) { s.wireState = f }
+}
+
+// NewServer returns a server for pitemp's endpoints, configured by opts
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		mux:  http.NewServeMux(),
+		addr: ":8080",
+		paths: map[string]string{
+			EndpointState: "/api",
+			EndpointWS:    "/ws",
+		},
+		handlers:  map[string]http.Handler{},
+		wrap:      func(_ string, h http.Handler) http.Handler { return h },
+		wireState: wire.FromState,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	builtin := map[string]http.Handler{
+		EndpointState: http.HandlerFunc(s.serveState),
+		EndpointWS:    http.HandlerFunc(s.serveWS),
+	}
+	for _, endpoint := range []string{EndpointState, EndpointWS} {
+		path := s.paths[endpoint]
+		if _, replaced := s.handlers[path]; path == "" || replaced {
+			continue
+		}
+		s.Handle(path, builtin[endpoint])
+	}
+	for _, pattern := range s.order {
+		s.Handle(pattern, s.handlers[pattern])
+	}
+
+	s.handler = s.mux
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		s.handler = s.middleware[i](s.handler)
+	}
+	s.srv = &http.Server{Handler: s.handler}
+	return s
+}
+
+// Handle serves h at pattern, in addition to the configured endpoints
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, s.wrap(pattern, h))
+}
+
+// Mux returns the server's mux, e.g. for registering debugging handlers
+func (s *Server) Mux() *http.ServeMux {
+	return s.mux
+}
+
+// Handler returns the server's handler, for mounting it in another server
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Start starts serving in the background, logging any failure
+func (s *Server) Start() error {
+	if err := s.listen(); err != nil {
+		return err
+	}
+	go func() {
+		if err := s.Serve(); err != nil {
+			slog.Error("HTTP server failed", "err", err)
+		}
+	}()
+	return nil
+}
+
+// Serve serves until Shutdown (even if called before Serve), when it
+// returns nil
+func (s *Server) Serve() error {
+	if err := s.listen(); err != nil {
+		return err
+	}
+	if err := s.srv.Serve(s.listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listen listens on the configured address, unless already listening
+func (s *Server) listen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return nil
+	}
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	s.listener = l
+	return nil
+}
+
+// Shutdown gracefully stops the server, waiting for active requests until
+// ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
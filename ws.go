@@ -0,0 +1,71 @@
+package pitemp
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lutzky/pitemp/pkg/state"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+var upgrader = websocket.Upgrader{}
+
+// serveWS pushes the state (in the wire format, like EndpointState) to the
+// connected client whenever it changes.
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn("WebSocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	updates, cancel := state.Subscribe()
+	defer cancel()
+
+	// Read (and discard) incoming messages, to process control frames and
+	// notice when the client goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	if err := s.writeWS(conn, state.Get()); err != nil {
+		return
+	}
+	for {
+		select {
+		case st := <-updates:
+			if err := s.writeWS(conn, st); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (s *Server) writeWS(conn *websocket.Conn, st state.State) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(s.wireState(st))
+}